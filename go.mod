@@ -1,3 +1,13 @@
 module github.com/Lin-Jiong-HDU/hold-my-audio
 
 go 1.25.5
+
+require (
+	github.com/ebitengine/oto/v3 v3.4.0
+	github.com/yalue/onnxruntime_go v1.27.0
+)
+
+require (
+	github.com/ebitengine/purego v0.9.0 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+)
@@ -0,0 +1,168 @@
+package aitest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClock_FiresInOrder(t *testing.T) {
+	c := NewClock()
+	late := c.After(30 * time.Millisecond)
+	early := c.After(10 * time.Millisecond)
+	if c.Waiters() != 2 {
+		t.Fatalf("Waiters() = %d, want 2", c.Waiters())
+	}
+
+	c.Advance(20 * time.Millisecond)
+	select {
+	case at := <-early:
+		if d := at.Sub(c.start); d != 10*time.Millisecond {
+			t.Errorf("timer fired at %v, want its own time 10ms", d)
+		}
+	default:
+		t.Fatal("timer due at 10ms did not fire at 20ms")
+	}
+	select {
+	case <-late:
+		t.Fatal("timer due at 30ms fired at 20ms")
+	default:
+	}
+
+	// 取消的等待不再计入
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Sleep(ctx, time.Second); err != context.Canceled {
+		t.Errorf("Sleep() = %v after cancel", err)
+	}
+	if c.Waiters() != 1 {
+		t.Errorf("Waiters() = %d, want the 30ms timer only", c.Waiters())
+	}
+}
+
+func TestClock_AfterFunc(t *testing.T) {
+	c := NewClock()
+	fired := make(chan time.Duration, 2)
+	c.AfterFunc(10*time.Millisecond, func() { fired <- c.Elapsed() })
+	stopped := c.AfterFunc(20*time.Millisecond, func() { fired <- c.Elapsed() })
+
+	// 停止的调用不再发生
+	if !stopped.Stop() {
+		t.Error("Stop() = false before the call")
+	}
+	c.Advance(50 * time.Millisecond)
+	if d := <-fired; d < 10*time.Millisecond {
+		t.Errorf("called at %v, want 10ms or later", d)
+	}
+	select {
+	case d := <-fired:
+		t.Errorf("stopped call made at %v", d)
+	case <-time.After(10 * time.Millisecond):
+	}
+	if stopped.Stop() {
+		t.Error("Stop() = true twice")
+	}
+}
+
+func TestLLM_TokenSize(t *testing.T) {
+	llm := NewLLM(NewClock(), nil)
+	llm.Script = []string{"Hello there.", "Bye."}
+	llm.TokenSize = 4
+
+	var got []string
+	for chunk := range llm.GenerateStream(context.Background(), "topic") {
+		got = append(got, chunk)
+	}
+	want := []string{"Hell", "o th", "ere.", "\n", "Bye.", "\n"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chunks %q, want %q", got, want)
+	}
+}
+
+func TestTTS_WordTiming(t *testing.T) {
+	tts := NewTTS(NewClock(), nil)
+	seg := tts.Segment("Hi  there")
+
+	// 每个字符 50ms，包括空格
+	perRune := int64(DefaultSampleRate / 20)
+	if seg.EndSample != 9*perRune {
+		t.Errorf("EndSample = %d, want 9 runes", seg.EndSample)
+	}
+	var words []string
+	for _, w := range seg.Words {
+		words = append(words, w.Text)
+	}
+	if !reflect.DeepEqual(words, []string{"Hi", "there"}) {
+		t.Fatalf("words %q", words)
+	}
+	if w := seg.Words[1]; w.Start != 4 || w.StartSample != 4*perRune || w.EndSample != 9*perRune {
+		t.Errorf("second word %+v", w)
+	}
+}
+
+func TestPlayer_PlaysOnClock(t *testing.T) {
+	env := NewEnv()
+	const text = "Hello world" // 550ms
+
+	result := make(chan error, 1)
+	go func() {
+		result <- env.Player.PlayStream(context.Background(), env.TTS.SynthesizeStream(context.Background(), text))
+	}()
+
+	// 虚拟时间不前进时播放不会结束
+	waitFor(t, func() bool { _, _, ok := env.Player.Current(); return ok })
+	for i := 0; i < 3; i++ {
+		waitFor(t, func() bool { return env.Clock.Waiters() > 0 })
+		env.Clock.Advance(100 * time.Millisecond)
+	}
+	waitFor(t, func() bool { return env.Player.Position().Samples == 3*DefaultSampleRate/10 })
+	waitFor(t, func() bool { return env.Clock.Waiters() > 0 })
+	env.Clock.Advance(50 * time.Millisecond)
+	name, elapsed, _ := env.Player.Current()
+	if name != text || elapsed != 350*time.Millisecond {
+		t.Errorf("Current() = %q, %v, want %q, 350ms", name, elapsed, text)
+	}
+
+	env.Player.Stop()
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	if pos := env.Player.Position(); pos.Playing || pos.Elapsed() != 350*time.Millisecond {
+		t.Errorf("Position() = %+v after Stop, want 350ms", pos)
+	}
+	if err := env.Trace.Expect("play: "+text, "stopped: "+text); err != nil {
+		t.Errorf("%v:\n%s", err, env.Trace)
+	}
+}
+
+func TestTrace_Expect(t *testing.T) {
+	tr := NewTrace(NewClock())
+	for _, e := range []string{"play", "state", "ask", "play"} {
+		tr.Add(e, "x")
+	}
+	if err := tr.Expect("play: x", "ask: x", "play..."); err != nil {
+		t.Error(err)
+	}
+	if err := tr.Expect("ask: x", "state: x"); err == nil {
+		t.Error("events out of order matched")
+	}
+	if err := tr.Never("stopped..."); err != nil {
+		t.Error(err)
+	}
+	if err := tr.Never("state..."); err == nil {
+		t.Error("Never() missed an event")
+	}
+}
+
+// waitFor 等待其他 goroutine 达到某个状态
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
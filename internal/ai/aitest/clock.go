@@ -0,0 +1,141 @@
+package aitest
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Clock is a virtual clock implementing ai.Clock. Time stands still until
+// Advance moves it, and the timers falling due meanwhile fire in order,
+// each at its own time.
+type Clock struct {
+	mu     sync.Mutex
+	start  time.Time
+	now    time.Time
+	timers []*timer
+}
+
+type timer struct {
+	clock *Clock
+	at    time.Time
+	ch    chan time.Time
+	f     func() // called instead of sending on ch
+}
+
+// NewClock creates a virtual clock starting at the current wall time
+func NewClock() *Clock {
+	now := time.Now()
+	return &Clock{start: now, now: now}
+}
+
+// Now returns the virtual time
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Since returns the virtual time elapsed since t
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Elapsed returns the virtual time elapsed since the clock was created
+func (c *Clock) Elapsed() time.Duration {
+	return c.Since(c.start)
+}
+
+// After returns a channel that receives the virtual time once d has
+// passed on the clock
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	return c.newTimer(d).ch
+}
+
+// AfterFunc calls f in its own goroutine once d has passed on the clock
+func (c *Clock) AfterFunc(d time.Duration, f func()) ai.Timer {
+	return c.schedule(d, f)
+}
+
+// Stop cancels an AfterFunc call, reporting false if it has been made
+func (t *timer) Stop() bool {
+	return t.clock.remove(t)
+}
+
+// Sleep waits until d has passed on the clock or ctx is done
+func (c *Clock) Sleep(ctx context.Context, d time.Duration) error {
+	t := c.newTimer(d)
+	select {
+	case <-t.ch:
+		return nil
+	case <-ctx.Done():
+		c.remove(t)
+		return ctx.Err()
+	}
+}
+
+// Advance moves the clock forward by d, firing the timers that fall due
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].at.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		t.fire()
+	}
+	c.now = end
+}
+
+// Waiters returns how many timers are waiting to fire
+func (c *Clock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+func (c *Clock) newTimer(d time.Duration) *timer {
+	return c.schedule(d, nil)
+}
+
+func (c *Clock) schedule(d time.Duration, f func()) *timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &timer{clock: c, at: c.now.Add(d), ch: make(chan time.Time, 1), f: f}
+	if d <= 0 {
+		t.fire()
+		return t
+	}
+	// Timers due at the same time fire in the order they were set
+	i := sort.Search(len(c.timers), func(i int) bool { return c.timers[i].at.After(t.at) })
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = t
+	return t
+}
+
+func (c *Clock) remove(t *timer) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (t *timer) fire() {
+	if t.f != nil {
+		go t.f()
+		return
+	}
+	t.ch <- t.at
+}
+
+var _ ai.Clock = (*Clock)(nil)
@@ -0,0 +1,31 @@
+// Package aitest provides scriptable fake engines for simulating a
+// podcast session without models, network or sound card. The fakes run
+// on a virtual Clock and record what they do in a Trace, so a test can
+// drive a session step by step and check the sequence of events.
+package aitest
+
+// Env is a set of fakes sharing one clock and trace
+type Env struct {
+	Clock    *Clock
+	Trace    *Trace
+	LLM      *LLM
+	TTS      *TTS
+	VAD      *VAD
+	Player   *Player
+	Recorder *Recorder
+}
+
+// NewEnv creates a set of fakes on a new clock
+func NewEnv() *Env {
+	clock := NewClock()
+	trace := NewTrace(clock)
+	return &Env{
+		Clock:    clock,
+		Trace:    trace,
+		LLM:      NewLLM(clock, trace),
+		TTS:      NewTTS(clock, trace),
+		VAD:      NewVAD(clock, trace),
+		Player:   NewPlayer(clock, trace, DefaultSampleRate),
+		Recorder: NewRecorder(clock, trace),
+	}
+}
@@ -0,0 +1,121 @@
+package aitest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LLM is a scripted ai.LLMEngine. The script and answers are sent chunk
+// by chunk at the pace set by FirstToken and ChunkDelay on the clock,
+// each script paragraph ending in a line break. With TokenSize, chunks
+// are cut into deltas of that many runes like a real LLM stream. Set the
+// fields before use.
+type LLM struct {
+	clock *Clock
+	trace *Trace
+
+	Script     []string            // paragraphs of the podcast script
+	Answers    map[string][]string // chunks answering each question
+	FirstToken time.Duration       // before the first chunk
+	ChunkDelay time.Duration       // between chunks
+	TokenSize  int                 // runes per chunk sent, 0 sends chunks whole
+
+	mu        sync.Mutex
+	questions []string
+	fail      int
+}
+
+// NewLLM creates a fake LLM on clock, recording to trace, which may be nil
+func NewLLM(clock *Clock, trace *Trace) *LLM {
+	return &LLM{clock: clock, trace: trace, Answers: make(map[string][]string)}
+}
+
+// GenerateStream sends the script
+func (l *LLM) GenerateStream(ctx context.Context, prompt string) <-chan string {
+	l.trace.Add("script", prompt)
+	chunks := make([]string, len(l.Script))
+	for i, p := range l.Script {
+		chunks[i] = p + "\n"
+	}
+	return l.send(ctx, chunks)
+}
+
+// GenerateResponse sends the answer to question, or "Answer: " followed
+// by the question when it has none
+func (l *LLM) GenerateResponse(ctx context.Context, question, context string) <-chan string {
+	l.mu.Lock()
+	l.questions = append(l.questions, question)
+	l.mu.Unlock()
+	l.trace.Add("ask", question)
+
+	answer, ok := l.Answers[question]
+	if !ok {
+		answer = []string{"Answer: " + question}
+	}
+	return l.send(ctx, answer)
+}
+
+// FailNext makes the next stream end without any text, like a dropped
+// connection
+func (l *LLM) FailNext() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fail++
+}
+
+// Questions returns the questions asked so far
+func (l *LLM) Questions() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.questions...)
+}
+
+func (l *LLM) send(ctx context.Context, chunks []string) <-chan string {
+	l.mu.Lock()
+	failed := l.fail > 0
+	if failed {
+		l.fail--
+	}
+	l.mu.Unlock()
+
+	if l.TokenSize > 0 {
+		chunks = splitTokens(chunks, l.TokenSize)
+	}
+
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		if failed {
+			l.trace.Add("llm failed", "")
+			return
+		}
+		for i, chunk := range chunks {
+			delay := l.ChunkDelay
+			if i == 0 {
+				delay = l.FirstToken
+			}
+			if l.clock.Sleep(ctx, delay) != nil {
+				return
+			}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// splitTokens cuts chunks into pieces of n runes
+func splitTokens(chunks []string, n int) []string {
+	var tokens []string
+	for _, chunk := range chunks {
+		runes := []rune(chunk)
+		for i := 0; i < len(runes); i += n {
+			tokens = append(tokens, string(runes[i:min(i+n, len(runes))]))
+		}
+	}
+	return tokens
+}
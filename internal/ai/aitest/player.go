@@ -0,0 +1,204 @@
+package aitest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Player is a fake ai.PositionedPlayer and ai.DuckingPlayer that plays
+// PCM16 mono audio in real time on the clock. It records "play", "played"
+// and "stopped" events named after the text the TTS synthesized.
+type Player struct {
+	clock      *Clock
+	trace      *Trace
+	sampleRate int
+
+	mu       sync.Mutex
+	stop     chan struct{}
+	streamID uint64
+	playing  bool
+	name     string
+	samples  int64     // played before the current chunk
+	chunk    int64     // samples in the current chunk
+	since    time.Time // when the current chunk started
+	gain     float64
+	fail     error
+
+	onPosition func(ai.PlaybackPosition)
+}
+
+// NewPlayer creates a fake player on clock for audio at sampleRate,
+// recording to trace, which may be nil
+func NewPlayer(clock *Clock, trace *Trace, sampleRate int) *Player {
+	return &Player{clock: clock, trace: trace, sampleRate: sampleRate, gain: 1}
+}
+
+// PlayStream plays the stream until it ends, Stop is called or ctx is done
+func (p *Player) PlayStream(ctx context.Context, audioStream <-chan []byte) error {
+	name := p.trace.streamName(audioStream)
+
+	p.mu.Lock()
+	if err := p.fail; err != nil {
+		p.fail = nil
+		p.mu.Unlock()
+		p.trace.Add("play failed", name)
+		go func() {
+			for range audioStream {
+			}
+		}()
+		return err
+	}
+	stop := make(chan struct{})
+	p.stop = stop
+	p.streamID++
+	p.playing = true
+	p.name = name
+	p.samples, p.chunk = 0, 0
+	p.mu.Unlock()
+	p.trace.Add("play", name)
+
+	for {
+		select {
+		case pcm, ok := <-audioStream:
+			if !ok {
+				p.finish()
+				p.trace.Add("played", name)
+				return nil
+			}
+			n := int64(len(pcm) / 2)
+			d := time.Duration(n) * time.Second / time.Duration(p.sampleRate)
+			p.mu.Lock()
+			p.chunk = n
+			p.since = p.clock.Now()
+			p.mu.Unlock()
+
+			select {
+			case <-p.clock.After(d):
+				p.mu.Lock()
+				p.samples += n
+				p.chunk = 0
+				p.mu.Unlock()
+				p.report()
+				continue
+			case <-stop:
+				p.halt(name, audioStream)
+				return nil
+			case <-ctx.Done():
+				p.halt(name, audioStream)
+				return ctx.Err()
+			}
+
+		case <-stop:
+			p.halt(name, audioStream)
+			return nil
+		case <-ctx.Done():
+			p.halt(name, audioStream)
+			return ctx.Err()
+		}
+	}
+}
+
+// Stop stops the stream being played
+func (p *Player) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	return nil
+}
+
+// Position returns how much of the current or last stream was played
+func (p *Player) Position() ai.PlaybackPosition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+// SetPositionCallback calls fn after every chunk played and when a stream
+// ends; interval is ignored
+func (p *Player) SetPositionCallback(interval time.Duration, fn func(ai.PlaybackPosition)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onPosition = fn
+}
+
+// Duck sets the volume at once and records it
+func (p *Player) Duck(gain float64, ramp time.Duration) {
+	p.mu.Lock()
+	p.gain = gain
+	p.mu.Unlock()
+	p.trace.Add("volume", fmt.Sprintf("%.2f", gain))
+}
+
+// Gain returns the volume set by Duck
+func (p *Player) Gain() float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.gain
+}
+
+// Current returns the text of the stream being played and how long it
+// has played, or false when nothing plays
+func (p *Player) Current() (name string, elapsed time.Duration, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.playing {
+		return "", 0, false
+	}
+	pos := p.position()
+	return p.name, pos.Elapsed(), true
+}
+
+// FailNext makes the next PlayStream fail with err without playing
+func (p *Player) FailNext(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail = err
+}
+
+// position must be called with mu held
+func (p *Player) position() ai.PlaybackPosition {
+	samples := p.samples
+	if p.playing && p.chunk > 0 {
+		in := int64(p.clock.Since(p.since).Seconds() * float64(p.sampleRate))
+		samples += min(in, p.chunk)
+	}
+	return ai.PlaybackPosition{StreamID: p.streamID, Samples: samples, SampleRate: p.sampleRate, Playing: p.playing}
+}
+
+// halt ends the stream early, keeping the part of the chunk that was heard
+func (p *Player) halt(name string, audioStream <-chan []byte) {
+	p.mu.Lock()
+	p.samples = p.position().Samples
+	p.chunk = 0
+	p.mu.Unlock()
+	p.finish()
+	p.trace.Add("stopped", name)
+
+	go func() {
+		for range audioStream {
+		}
+	}()
+}
+
+func (p *Player) finish() {
+	p.mu.Lock()
+	p.playing = false
+	p.mu.Unlock()
+	p.report()
+}
+
+func (p *Player) report() {
+	p.mu.Lock()
+	fn, pos := p.onPosition, p.position()
+	p.mu.Unlock()
+	if fn != nil {
+		fn(pos)
+	}
+}
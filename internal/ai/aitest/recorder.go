@@ -0,0 +1,87 @@
+package aitest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Recorder is a fake ai.AudioRecorder. Say queues what the listener says;
+// Record returns it once the listener has finished saying it, or capture
+// has been stopped, and Latency has passed for transcription. With nothing
+// queued it records silence until capture stops. Set the fields before
+// use.
+//
+// With WaitForStop, capture goes on after the speech until it is stopped,
+// as when a turn detector decides where the question ends.
+type Recorder struct {
+	clock *Clock
+	trace *Trace
+
+	Latency     time.Duration // transcription time after the speech ends
+	WaitForStop bool          // capture until stopped, not until the speech ends
+
+	mu    sync.Mutex
+	queue []utterance
+}
+
+type utterance struct {
+	text string
+	ends time.Time
+	err  error
+}
+
+// NewRecorder creates a fake recorder on clock, recording to trace,
+// which may be nil
+func NewRecorder(clock *Clock, trace *Trace) *Recorder {
+	return &Recorder{clock: clock, trace: trace}
+}
+
+// Say queues text, which the listener starts saying now and takes d to say
+func (r *Recorder) Say(text string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queue = append(r.queue, utterance{text: text, ends: r.clock.Now().Add(d)})
+}
+
+// FailNext makes the next recording fail with err
+func (r *Recorder) FailNext(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queue = append(r.queue, utterance{err: err})
+}
+
+// Record returns the next thing the listener says
+func (r *Recorder) Record(ctx context.Context) (string, error) {
+	capture := ai.CaptureContext(ctx)
+	r.mu.Lock()
+	if len(r.queue) == 0 {
+		r.mu.Unlock()
+		<-capture.Done()
+		return "", ctx.Err()
+	}
+	u := r.queue[0]
+	r.queue = r.queue[1:]
+	r.mu.Unlock()
+
+	if u.err != nil {
+		r.trace.Add("record failed", u.err.Error())
+		return "", u.err
+	}
+	// Stopped early, the whole utterance still counts as said
+	r.clock.Sleep(capture, u.ends.Sub(r.clock.Now()))
+	if r.WaitForStop {
+		select {
+		case <-capture.Done():
+		case <-ctx.Done():
+		}
+	}
+	if err := r.clock.Sleep(ctx, r.Latency); err != nil {
+		r.trace.Add("record abandoned", u.text)
+		return "", err
+	}
+	r.trace.Add("record", u.text)
+	return u.text, nil
+}
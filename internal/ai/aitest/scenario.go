@@ -0,0 +1,176 @@
+package aitest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"testing/synctest"
+	"time"
+	"unicode/utf8"
+)
+
+// Scenario defaults
+const (
+	defaultStep    = 10 * time.Millisecond
+	defaultTimeout = 10 * time.Minute
+
+	// speakingRate is how long the listener takes per rune of what they say
+	speakingRate = 60 * time.Millisecond
+)
+
+// Scenario is a scripted session: the podcast script, what the listener
+// does while it plays and the events that must follow, e.g.
+//
+//	Scenario{
+//		Script:  []string{"Welcome.", "Today: qubits."},
+//		Actions: []Action{Interrupt(2, 3*time.Second, "What is a qubit?")},
+//		Want:    []string{"stopped: Today: qubits.", "ask: What is a qubit?", "state: IDLE"},
+//	}
+type Scenario struct {
+	Topic   string
+	Script  []string            // segments of the podcast script
+	Answers map[string][]string // answer chunks by question
+	Actions []Action
+
+	// Want are events expected in this order, with any others in
+	// between; one ending in "..." matches events starting with the rest.
+	// Never are events that must not happen.
+	Want  []string
+	Never []string
+
+	Step    time.Duration // virtual time per step, 10ms by default
+	Timeout time.Duration // virtual time before giving up, 10m by default
+}
+
+// Action is something done once a script segment has played for a while
+type Action struct {
+	Segment int           // from 1
+	At      time.Duration // into the segment
+	Name    string
+	Do      func(env *Env)
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("%s %v into segment %d", a.Name, a.At, a.Segment)
+}
+
+// At returns an action running do at into segment, for example to
+// inject an error
+func At(segment int, at time.Duration, name string, do func(env *Env)) Action {
+	return Action{Segment: segment, At: at, Name: name, Do: do}
+}
+
+// Interrupt returns an action where the listener starts speaking at into
+// segment and says question
+func Interrupt(segment int, at time.Duration, question string) Action {
+	return At(segment, at, fmt.Sprintf("interrupt with %q", question), func(env *Env) {
+		d := time.Duration(utf8.RuneCountInString(question)) * speakingRate
+		env.Recorder.Say(question, d)
+		env.VAD.SpeechStart(1)
+		go func() {
+			<-env.Clock.After(d)
+			env.VAD.SpeechEnd()
+		}()
+	})
+}
+
+// Runner is the system a scenario runs, such as an orchestrator built on
+// the fakes of its Env
+type Runner interface {
+	Run(ctx context.Context, topic string) error
+}
+
+// Env returns fakes loaded with the script and answers
+func (s Scenario) Env() *Env {
+	env := NewEnv()
+	env.LLM.Script = s.Script
+	for q, a := range s.Answers {
+		env.LLM.Answers[q] = a
+	}
+	return env
+}
+
+// Run runs r on env until it returns, advancing the clock step by step
+// and acting as scripted, then checks the events. Before each step it
+// waits for the system to react to the last one, so r must time
+// everything on env.Clock.
+//
+// Run must be called in a synctest bubble, with env and r created in it:
+//
+//	synctest.Test(t, func(t *testing.T) {
+//		env := s.Env()
+//		s.Run(t, env, newRunner(env))
+//	})
+func (s Scenario) Run(t testing.TB, env *Env, r Runner) {
+	t.Helper()
+	step, timeout := s.Step, s.Timeout
+	if step <= 0 {
+		step = defaultStep
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Run(ctx, s.Topic)
+	}()
+
+	pending := append([]Action(nil), s.Actions...)
+	for running := true; running; {
+		synctest.Wait()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Run() = %v", err)
+			}
+			running = false
+			continue
+		default:
+		}
+		if env.Clock.Elapsed() > timeout {
+			cancel()
+			<-done
+			t.Fatalf("still running after %v:\n%s", timeout, env.Trace)
+		}
+
+		pending = s.act(env, pending)
+		env.Clock.Advance(step)
+	}
+	// Let what is still waiting on the clock finish, so the bubble ends
+	cancel()
+	for start := env.Clock.Elapsed(); env.Clock.Waiters() > 0 && env.Clock.Elapsed()-start < timeout; {
+		env.Clock.Advance(step)
+		synctest.Wait()
+	}
+
+	for _, a := range pending {
+		t.Errorf("never got to %s", a)
+	}
+	if err := env.Trace.Expect(s.Want...); err != nil {
+		t.Errorf("%v, trace:\n%s", err, env.Trace)
+	}
+	if err := env.Trace.Never(s.Never...); err != nil {
+		t.Errorf("%v, trace:\n%s", err, env.Trace)
+	}
+}
+
+// act runs the actions that are due and returns the others
+func (s Scenario) act(env *Env, actions []Action) []Action {
+	name, elapsed, ok := env.Player.Current()
+	if !ok {
+		return actions
+	}
+	var pending []Action
+	for _, a := range actions {
+		if a.Segment >= 1 && a.Segment <= len(s.Script) && name == s.Script[a.Segment-1] && elapsed >= a.At {
+			env.Trace.Add("action", a.Name)
+			a.Do(env)
+			continue
+		}
+		pending = append(pending, a)
+	}
+	return pending
+}
@@ -0,0 +1,127 @@
+package aitest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is something a fake did, at a time on the virtual clock
+type Event struct {
+	At   time.Duration // since the clock was created
+	Kind string        // e.g. "play", "stopped", "ask", "state"
+	Text string
+}
+
+func (e Event) String() string {
+	if e.Text == "" {
+		return e.Kind
+	}
+	return e.Kind + ": " + e.Text
+}
+
+// Trace records the events of a simulation in order. It also remembers
+// the text of each synthesized stream, so the player can tell what it
+// plays.
+type Trace struct {
+	clock *Clock
+
+	mu      sync.Mutex
+	events  []Event
+	streams map[<-chan []byte]string
+}
+
+// NewTrace creates a trace timed by clock
+func NewTrace(clock *Clock) *Trace {
+	return &Trace{clock: clock, streams: make(map[<-chan []byte]string)}
+}
+
+// Add records an event. It does nothing on a nil trace, so fakes can be
+// used without one.
+func (t *Trace) Add(kind, text string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, Event{At: t.clock.Elapsed(), Kind: kind, Text: text})
+}
+
+// Events returns the events recorded so far
+func (t *Trace) Events() []Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Event(nil), t.events...)
+}
+
+// String lists the events one per line with their times
+func (t *Trace) String() string {
+	var b strings.Builder
+	for _, e := range t.Events() {
+		fmt.Fprintf(&b, "%8v  %s\n", e.At.Round(time.Millisecond), e)
+	}
+	return b.String()
+}
+
+// Expect checks that the wanted events were recorded in this order, with
+// any others in between. A wanted event ending in "..." matches events
+// starting with the rest of it.
+func (t *Trace) Expect(want ...string) error {
+	events := t.Events()
+	i := 0
+	for _, w := range want {
+		for i < len(events) && !matches(w, events[i].String()) {
+			i++
+		}
+		if i == len(events) {
+			return fmt.Errorf("aitest: missing %q in order", w)
+		}
+		i++
+	}
+	return nil
+}
+
+// Never checks that none of the events were recorded
+func (t *Trace) Never(unwanted ...string) error {
+	for _, e := range t.Events() {
+		for _, u := range unwanted {
+			if matches(u, e.String()) {
+				return fmt.Errorf("aitest: unexpected %q at %v", e.String(), e.At)
+			}
+		}
+	}
+	return nil
+}
+
+func matches(want, event string) bool {
+	if prefix, ok := strings.CutSuffix(want, "..."); ok {
+		return strings.HasPrefix(event, prefix)
+	}
+	return want == event
+}
+
+// nameStream remembers that audio is the speech of text
+func (t *Trace) nameStream(audio <-chan []byte, text string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.streams[audio] = text
+}
+
+// streamName returns the text of a synthesized stream, or "audio" for
+// audio from elsewhere
+func (t *Trace) streamName(audio <-chan []byte) string {
+	if t == nil {
+		return "audio"
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if text, ok := t.streams[audio]; ok {
+		delete(t.streams, audio)
+		return text
+	}
+	return "audio"
+}
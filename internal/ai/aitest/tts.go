@@ -0,0 +1,135 @@
+package aitest
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// TTS defaults
+const (
+	DefaultSampleRate   = 24000
+	DefaultRuneDuration = 50 * time.Millisecond
+	defaultChunk        = 100 * time.Millisecond
+)
+
+// TTS is a fake ai.AlignedTTSEngine. Every rune of text takes RuneDuration
+// of silent PCM16 mono audio, and each word is reported at exactly its
+// place in it. Synthesis is faster than real time: after Latency all
+// audio is ready. Like GLM, the segment is sent once the last audio has
+// been taken; with Estimate it is also sent as an estimate before the
+// audio. Set the fields before use.
+type TTS struct {
+	clock *Clock
+	trace *Trace
+
+	SampleRate   int
+	RuneDuration time.Duration
+	Latency      time.Duration // before the audio is ready
+	Estimate     bool          // send an estimated segment before the audio
+
+	mu   sync.Mutex
+	fail int
+}
+
+// NewTTS creates a fake TTS on clock, recording to trace, which may be nil
+func NewTTS(clock *Clock, trace *Trace) *TTS {
+	return &TTS{
+		clock:        clock,
+		trace:        trace,
+		SampleRate:   DefaultSampleRate,
+		RuneDuration: DefaultRuneDuration,
+	}
+}
+
+// SynthesizeStream synthesizes text
+func (t *TTS) SynthesizeStream(ctx context.Context, text string) <-chan []byte {
+	audio, _ := t.SynthesizeAligned(ctx, text)
+	return audio
+}
+
+// SynthesizeAligned synthesizes text and reports it as one segment with
+// the timing of every word
+func (t *TTS) SynthesizeAligned(ctx context.Context, text string) (<-chan []byte, <-chan ai.Segment) {
+	t.mu.Lock()
+	failed := t.fail > 0
+	if failed {
+		t.fail--
+	}
+	t.mu.Unlock()
+
+	seg := t.Segment(text)
+	chunk := int(defaultChunk.Seconds()*float64(t.SampleRate)) * 2
+	total := int(seg.EndSample) * 2
+
+	audioCh := make(chan []byte)
+	segments := make(chan ai.Segment, 2)
+	t.trace.nameStream(audioCh, text)
+	go func() {
+		defer close(segments)
+		defer close(audioCh)
+		if failed {
+			t.trace.Add("tts failed", text)
+			return
+		}
+		if t.clock.Sleep(ctx, t.Latency) != nil {
+			return
+		}
+		if t.Estimate {
+			estimate := seg
+			estimate.Estimated = true
+			segments <- estimate
+		}
+		for sent := 0; sent < total; sent += chunk {
+			select {
+			case audioCh <- make([]byte, min(chunk, total-sent)):
+			case <-ctx.Done():
+				return
+			}
+		}
+		segments <- seg
+	}()
+	return audioCh, segments
+}
+
+// Segment returns the timing the TTS reports for text
+func (t *TTS) Segment(text string) ai.Segment {
+	perRune := int64(t.RuneDuration.Seconds() * float64(t.SampleRate))
+	seg := ai.Segment{
+		Text:       text,
+		End:        len(text),
+		EndSample:  int64(utf8.RuneCountInString(text)) * perRune,
+		SampleRate: t.SampleRate,
+	}
+
+	runes, start, startRune := 0, -1, 0
+	for i, r := range text + " " {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				seg.Words = append(seg.Words, ai.WordTiming{
+					Text:        text[start:i],
+					Start:       start,
+					End:         i,
+					StartSample: int64(startRune) * perRune,
+					EndSample:   int64(runes) * perRune,
+				})
+				start = -1
+			}
+		} else if start < 0 {
+			start, startRune = i, runes
+		}
+		runes++
+	}
+	return seg
+}
+
+// FailNext makes the next synthesis produce no audio
+func (t *TTS) FailNext() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fail++
+}
@@ -0,0 +1,98 @@
+package aitest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// vadRate is the sample rate event offsets count in
+const vadRate = 16000
+
+// VAD is a fake ai.VADMonitor. The test decides when the listener speaks
+// with SpeechStart and SpeechEnd. It can be started again after Stop.
+type VAD struct {
+	clock *Clock
+	trace *Trace
+
+	mu     sync.Mutex
+	events chan ai.VADEvent
+	closed bool
+}
+
+// NewVAD creates a fake VAD on clock, recording to trace, which may be nil
+func NewVAD(clock *Clock, trace *Trace) *VAD {
+	return &VAD{clock: clock, trace: trace}
+}
+
+// Start begins monitoring until Stop is called or ctx is done. Starting
+// again ends the previous monitoring.
+func (v *VAD) Start(ctx context.Context) <-chan ai.VADEvent {
+	events := make(chan ai.VADEvent, 16)
+	v.mu.Lock()
+	v.closeLocked()
+	v.events, v.closed = events, false
+	v.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if v.events == events {
+			v.closeLocked()
+		}
+	}()
+	return events
+}
+
+// Stop ends monitoring; it may be called more than once
+func (v *VAD) Stop() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.closeLocked()
+	return nil
+}
+
+// SpeechStart reports that the listener started speaking, detected with
+// probability p
+func (v *VAD) SpeechStart(p float32) {
+	v.send(ai.SpeechStart, p, "speech start")
+}
+
+// SpeechEnd reports that the listener stopped speaking
+func (v *VAD) SpeechEnd() {
+	v.send(ai.SpeechEnd, 0, "speech end")
+}
+
+func (v *VAD) send(typ ai.VADEventType, p float32, what string) {
+	offset := int64(v.clock.Elapsed().Seconds() * vadRate)
+	ev := ai.VADEvent{
+		Type:        typ,
+		Time:        v.clock.Now(),
+		Offset:      offset,
+		Detected:    offset,
+		SampleRate:  vadRate,
+		Probability: p,
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.events == nil || v.closed {
+		v.trace.Add("vad off", what)
+		return
+	}
+	select {
+	case v.events <- ev:
+		v.trace.Add(what, "")
+	default:
+		v.trace.Add("vad dropped", what)
+	}
+}
+
+func (v *VAD) closeLocked() {
+	if v.events != nil && !v.closed {
+		close(v.events)
+		v.closed = true
+	}
+}
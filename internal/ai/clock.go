@@ -0,0 +1,38 @@
+package ai
+
+import "time"
+
+// Clock tells the time and runs timers. Components use SystemClock unless
+// given another, e.g. a virtual clock in tests.
+type Clock interface {
+	Now() time.Time
+
+	// After returns a channel that receives the time once d has passed
+	After(d time.Duration) <-chan time.Time
+
+	// AfterFunc calls f in its own goroutine once d has passed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a call scheduled with Clock.AfterFunc
+type Timer interface {
+	// Stop cancels the call, reporting false if it has already been made
+	Stop() bool
+}
+
+// SystemClock is the wall clock
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
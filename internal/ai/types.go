@@ -1,6 +1,9 @@
 package ai
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // LLMEngine defines the interface for LLM providers
 type LLMEngine interface {
@@ -21,13 +24,52 @@ type TTSEngine interface {
 // VADMonitor defines the interface for voice activity detection
 type VADMonitor interface {
 	// Start begins monitoring for voice activity
-	// Sends a signal when voice is detected
-	Start(ctx context.Context) <-chan struct{}
+	// Sends a SpeechStart event when voice is detected and a SpeechEnd
+	// event when it stops; the channel is closed when monitoring ends
+	Start(ctx context.Context) <-chan VADEvent
 
 	// Stop stops the monitoring process
 	Stop() error
 }
 
+// VADEventType distinguishes the start and end of speech
+type VADEventType int
+
+const (
+	SpeechStart VADEventType = iota
+	SpeechEnd
+)
+
+func (t VADEventType) String() string {
+	switch t {
+	case SpeechStart:
+		return "SpeechStart"
+	case SpeechEnd:
+		return "SpeechEnd"
+	default:
+		return "Unknown"
+	}
+}
+
+// VADEvent reports a speech boundary found by a VADMonitor. Offsets count
+// samples of the monitored audio from the start of monitoring.
+type VADEvent struct {
+	Type        VADEventType
+	Time        time.Time // when the event was emitted, with a monotonic reading
+	Offset      int64     // where speech started or ended
+	Detected    int64     // where the detector made the decision
+	SampleRate  int
+	Probability float32 // detector confidence that the audio at Detected is speech
+}
+
+// Delay returns how long after the boundary the detector made its decision
+func (e VADEvent) Delay() time.Duration {
+	if e.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(e.Detected-e.Offset) * time.Second / time.Duration(e.SampleRate)
+}
+
 // AudioPlayer defines the interface for audio playback
 type AudioPlayer interface {
 	// PlayStream plays audio from a stream channel
@@ -38,9 +80,146 @@ type AudioPlayer interface {
 	Stop() error
 }
 
+// PlaybackPosition is how far playback of one stream has got
+type PlaybackPosition struct {
+	StreamID   uint64 // increases with every PlayStream call
+	Samples    int64  // samples of the stream played so far
+	SampleRate int
+	Playing    bool // the stream is still being played
+}
+
+// Elapsed returns how long the stream has played
+func (p PlaybackPosition) Elapsed() time.Duration {
+	if p.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(p.Samples) * time.Second / time.Duration(p.SampleRate)
+}
+
+// PositionedPlayer is an AudioPlayer that knows how much of a stream has
+// actually been heard, as opposed to handed to the device
+type PositionedPlayer interface {
+	AudioPlayer
+
+	// Position returns the position in the current stream, or the final
+	// position of the last one
+	Position() PlaybackPosition
+
+	// SetPositionCallback calls fn about every interval while a stream
+	// plays and once when it ends; nil removes the callback
+	SetPositionCallback(interval time.Duration, fn func(PlaybackPosition))
+}
+
+// DuckingPlayer is an AudioPlayer that can lower its volume smoothly
+// while it keeps playing, e.g. while the listener speaks
+type DuckingPlayer interface {
+	AudioPlayer
+
+	// Duck ramps the volume to gain over ramp; 1 is full volume
+	Duck(gain float64, ramp time.Duration)
+}
+
 // AudioRecorder defines the interface for audio recording and STT
 type AudioRecorder interface {
 	// Record captures audio and returns transcribed text
-	// Blocks until recording is complete or context is cancelled
+	// Capture ends when the context set with WithCapture is done; the
+	// whole call, transcription included, is abandoned when ctx is
 	Record(ctx context.Context) (string, error)
 }
+
+type captureKey struct{}
+
+// WithCapture returns a copy of ctx telling an AudioRecorder to stop
+// capturing when capture is done and transcribe what it has. Cancelling
+// ctx itself abandons the recording.
+func WithCapture(ctx, capture context.Context) context.Context {
+	return context.WithValue(ctx, captureKey{}, capture)
+}
+
+// CaptureContext returns the context ending capture for a recording made
+// with ctx, which is ctx itself when none was set with WithCapture
+func CaptureContext(ctx context.Context) context.Context {
+	if capture, ok := ctx.Value(captureKey{}).(context.Context); ok {
+		return capture
+	}
+	return ctx
+}
+
+// StreamingRecorder is an AudioRecorder that also reports what it has
+// recognized while the user is still speaking
+type StreamingRecorder interface {
+	AudioRecorder
+
+	// RecordStream captures audio like Record, sending partial hypotheses
+	// as recognition progresses and one final hypothesis when recording
+	// ends. The channel is closed after the final hypothesis and must be
+	// drained.
+	RecordStream(ctx context.Context) <-chan Hypothesis
+}
+
+// Hypothesis is the text recognized so far in a recording
+type Hypothesis struct {
+	Text  string
+	Final bool  // recording has ended and Text is the full transcription
+	Err   error // set on the final hypothesis when recognition failed
+}
+
+// Transcriber converts recorded speech to text
+type Transcriber interface {
+	// Transcribe converts PCM16 mono audio at sampleRate to text
+	Transcribe(ctx context.Context, pcm []byte, sampleRate int) (*Transcription, error)
+}
+
+// TranscriptSegment is a timed span of transcribed speech
+type TranscriptSegment struct {
+	Text  string        `json:"text"`
+	Start time.Duration `json:"start"` // from the start of the audio
+	End   time.Duration `json:"end"`
+}
+
+// Transcription is the text recognized in a recording
+type Transcription struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language,omitempty"` // as reported by the engine, empty if unknown
+	Duration time.Duration       `json:"duration"`
+	Segments []TranscriptSegment `json:"segments,omitempty"`
+}
+
+// WordTiming is the audio span of one word of a synthesized segment
+type WordTiming struct {
+	Text        string `json:"text"`
+	Start       int    `json:"start"` // byte offset in the segment text
+	End         int    `json:"end"`
+	StartSample int64  `json:"start_sample"`
+	EndSample   int64  `json:"end_sample"` // exclusive
+}
+
+// Segment ties a span of synthesized text to its position in the audio
+// stream. Offsets refer to the text as spoken, i.e. after normalization.
+type Segment struct {
+	Text        string       `json:"text"`
+	Start       int          `json:"start"` // byte offset in the synthesized text
+	End         int          `json:"end"`
+	StartSample int64        `json:"start_sample"`
+	EndSample   int64        `json:"end_sample"` // exclusive
+	SampleRate  int          `json:"sample_rate"`
+	Words       []WordTiming `json:"words,omitempty"` // engine timestamps, nil if the engine has none
+
+	// Estimated segments are sent before their audio with guessed timing;
+	// segments for the same text with the final timing follow
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// AlignedTTSEngine is a TTSEngine that can report which text each part of
+// the audio belongs to
+type AlignedTTSEngine interface {
+	TTSEngine
+
+	// SynthesizeAligned works like SynthesizeStream and additionally sends
+	// one Segment per synthesized span once its audio is complete. Spans
+	// may first be sent as Estimated segments, before their audio, so the
+	// audio being played is covered sooner.
+	// The segment channel is buffered for every segment, so the caller may
+	// drain the audio first; both channels are closed when synthesis ends.
+	SynthesizeAligned(ctx context.Context, text string) (<-chan []byte, <-chan Segment)
+}
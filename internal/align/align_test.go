@@ -0,0 +1,202 @@
+package align
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+func spanTexts(text string, spans []Span) []string {
+	out := make([]string, len(spans))
+	for i, s := range spans {
+		out[i] = text[s.Start:s.End]
+	}
+	return out
+}
+
+func TestSplitSentences(t *testing.T) {
+	text := "你好，欢迎收听。Today we talk about AI! It costs 3.5 dollars.\n下一段"
+	got := spanTexts(text, SplitSentences(text))
+	want := []string{"你好，欢迎收听。", "Today we talk about AI!", "It costs 3.5 dollars.", "下一段"}
+
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("SplitSentences\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	text := "我用GLM说 don't stop，好吗？"
+	got := spanTexts(text, SplitWords(text))
+	want := []string{"我", "用", "GLM", "说", "don't", "stop", "好", "吗"}
+
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("SplitWords\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestWeight(t *testing.T) {
+	if w := Weight("你好"); w != 2 {
+		t.Errorf("Weight(你好) = %v, want 2", w)
+	}
+	// "banana" 三个音节
+	if w := Weight("banana"); w != 3 {
+		t.Errorf("Weight(banana) = %v, want 3", w)
+	}
+	if Weight("好。") <= Weight("好") {
+		t.Error("sentence end should add a pause")
+	}
+}
+
+func TestDistribute(t *testing.T) {
+	text := "一二。三四五六。"
+	spans := SplitSentences(text)
+	ranges := Distribute(text, spans, 1000, 2000)
+
+	if len(ranges) != 2 {
+		t.Fatalf("got %d ranges, want 2", len(ranges))
+	}
+	if ranges[0][0] != 1000 || ranges[1][1] != 2000 {
+		t.Errorf("ranges %v do not cover [1000, 2000)", ranges)
+	}
+	if ranges[0][1] != ranges[1][0] {
+		t.Errorf("ranges %v are not contiguous", ranges)
+	}
+	// 第二句字数是第一句的两倍，应该更长
+	if d0, d1 := ranges[0][1]-ranges[0][0], ranges[1][1]-ranges[1][0]; d1 <= d0 {
+		t.Errorf("longer sentence got shorter duration: %d vs %d", d1, d0)
+	}
+}
+
+func TestTranscript(t *testing.T) {
+	tr := NewTranscript()
+	tr.Add(ai.Segment{Text: "你好世界。", StartSample: 0, EndSample: 5000, SampleRate: 1000}, 0)
+	tr.Add(ai.Segment{Text: "Hello there.", StartSample: 0, EndSample: 2000, SampleRate: 1000}, 5000)
+
+	words := tr.Words()
+	if len(words) != 6 {
+		t.Fatalf("got %d words, want 6", len(words))
+	}
+	if words[4].Text != "Hello" || words[4].StartSample < 5000 {
+		t.Errorf("second segment not shifted onto the timeline: %+v", words[4])
+	}
+
+	if got := tr.WordAt(-1); got != -1 {
+		t.Errorf("WordAt before start = %d, want -1", got)
+	}
+	if got := tr.WordAt(words[2].StartSample); got != 2 {
+		t.Errorf("WordAt(%d) = %d, want 2", words[2].StartSample, got)
+	}
+	if got := tr.WordAt(6900); got != 5 {
+		t.Errorf("WordAt(6900) = %d, want 5", got)
+	}
+
+	if got := tr.HeardText(words[1].StartSample); got != "你好" {
+		t.Errorf("HeardText = %q, want %q", got, "你好")
+	}
+	if got := tr.HeardText(words[4].StartSample); got != "你好世界。Hello" {
+		t.Errorf("HeardText = %q", got)
+	}
+
+	// 从正在说的词开始续播，之前的段落不算
+	if got := tr.RemainingText(0, words[4].StartSample+1); got != "Hello there." {
+		t.Errorf("RemainingText = %q, want %q", got, "Hello there.")
+	}
+	if got := tr.RemainingText(0, words[1].StartSample); got != "好世界。Hello there." {
+		t.Errorf("RemainingText = %q", got)
+	}
+	if got := tr.RemainingText(5000, words[1].StartSample); got != "Hello there." {
+		t.Errorf("RemainingText from the second stream = %q", got)
+	}
+	if got := tr.RemainingText(0, 7000); got != "" {
+		t.Errorf("RemainingText after the end = %q, want empty", got)
+	}
+}
+
+func TestTranscript_Estimated(t *testing.T) {
+	tr := NewTranscript()
+	tr.Add(ai.Segment{Text: "One two.", Start: 0, End: 8, StartSample: 0, EndSample: 2000}, 0)
+	tr.Add(ai.Segment{Text: "Three four.", Start: 9, End: 20, StartSample: 2000, EndSample: 4000, Estimated: true}, 0)
+	tr.Add(ai.Segment{Text: "Five.", Start: 21, End: 26, StartSample: 4000, EndSample: 5000, Estimated: true}, 0)
+
+	// 音频到齐前，估计的时间已能定位正在说的词
+	if got := tr.RemainingText(0, 3000); got != "four.Five." {
+		t.Errorf("RemainingText on estimates = %q", got)
+	}
+
+	// 最终时间替换估计，之前的段落保留
+	tr.Add(ai.Segment{Text: "Three four.", Start: 9, End: 20, StartSample: 2000, EndSample: 3000}, 0)
+	tr.Add(ai.Segment{Text: "Five.", Start: 21, End: 26, StartSample: 3000, EndSample: 3500}, 0)
+	segs := tr.Segments()
+	if len(segs) != 3 || segs[1].Estimated || segs[2].EndSample != 3500 {
+		t.Fatalf("segments after the final timing: %+v", segs)
+	}
+	if words := tr.Words(); len(words) != 5 || words[4].Text != "Five" || words[4].StartSample != 3000 {
+		t.Errorf("words after the final timing: %+v", words)
+	}
+}
+
+func TestTranscript_SentenceEnd(t *testing.T) {
+	tr := NewTranscript()
+	tr.Add(ai.Segment{Text: "One two. Three four five.", StartSample: 0, EndSample: 5000, SampleRate: 1000}, 1000)
+	words := tr.Words()
+
+	// 第一句在 "two" 结束，第二句在段落结束
+	if got := tr.SentenceEnd(words[0].StartSample); got != words[1].EndSample {
+		t.Errorf("SentenceEnd in the first sentence = %d, want %d", got, words[1].EndSample)
+	}
+	if got := tr.SentenceEnd(words[3].StartSample); got != words[4].EndSample {
+		t.Errorf("SentenceEnd in the second sentence = %d, want %d", got, words[4].EndSample)
+	}
+	if got := tr.SentenceEnd(500); got != 500 {
+		t.Errorf("SentenceEnd before the segment = %d, want 500", got)
+	}
+}
+
+func TestTranscript_EngineWords(t *testing.T) {
+	tr := NewTranscript()
+	tr.Add(ai.Segment{
+		Text: "a b", StartSample: 0, EndSample: 100, SampleRate: 100,
+		Words: []ai.WordTiming{
+			{Text: "a", Start: 0, End: 1, StartSample: 0, EndSample: 10},
+			{Text: "b", Start: 2, End: 3, StartSample: 90, EndSample: 100},
+		},
+	}, 50)
+
+	// 引擎提供的时间戳优先于估算
+	if got := tr.WordAt(130); got != 0 {
+		t.Errorf("WordAt(130) = %d, want 0", got)
+	}
+	if got := tr.WordAt(140); got != 1 {
+		t.Errorf("WordAt(140) = %d, want 1", got)
+	}
+}
+
+func TestTranscript_Export(t *testing.T) {
+	tr := NewTranscript()
+	tr.Add(ai.Segment{Text: "第一句。", StartSample: 0, EndSample: 36000, SampleRate: 24000}, 0)
+	tr.Add(ai.Segment{Text: "第二句。", StartSample: 0, EndSample: 24000, SampleRate: 24000}, 36000)
+
+	var srt bytes.Buffer
+	if err := tr.WriteSRT(&srt); err != nil {
+		t.Fatalf("WriteSRT failed: %v", err)
+	}
+	want := "1\n00:00:00,000 --> 00:00:01,500\n第一句。\n\n2\n00:00:01,500 --> 00:00:02,500\n第二句。\n\n"
+	if srt.String() != want {
+		t.Errorf("WriteSRT\n got: %q\nwant: %q", srt.String(), want)
+	}
+
+	var js bytes.Buffer
+	if err := tr.WriteJSON(&js); err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	var segments []ai.Segment
+	if err := json.Unmarshal(js.Bytes(), &segments); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(segments) != 2 || len(segments[1].Words) != 3 {
+		t.Errorf("unexpected JSON transcript: %+v", segments)
+	}
+}
@@ -0,0 +1,237 @@
+// Package align maps synthesized text to positions in the audio stream
+package align
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Span is a byte range [Start, End) of a text
+type Span struct {
+	Start, End int
+}
+
+// Relative speaking-time weights. One Han character and one English
+// syllable take roughly the same time; punctuation stands for a pause.
+const (
+	syllableWeight      = 1.0
+	clausePauseWeight   = 0.6
+	sentencePauseWeight = 1.2
+)
+
+// SplitSentences splits text at sentence-ending punctuation and newlines.
+// Each span includes its trailing punctuation; surrounding whitespace is
+// left out.
+func SplitSentences(text string) []Span {
+	var spans []Span
+	start := -1
+	for i, r := range text {
+		if start < 0 {
+			if unicode.IsSpace(r) {
+				continue
+			}
+			start = i
+		}
+
+		end := i + utf8.RuneLen(r)
+		if r == '\n' {
+			spans = appendTrimmed(spans, text, start, i)
+			start = -1
+		} else if isSentenceEnd(r) && !continuesSentence(text, r, end) {
+			spans = appendTrimmed(spans, text, start, end)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = appendTrimmed(spans, text, start, len(text))
+	}
+	return spans
+}
+
+// continuesSentence reports whether the text after the sentence-ending mark
+// r keeps the sentence going, e.g. "?!", a closing quote or the "." in "3.5"
+func continuesSentence(text string, r rune, i int) bool {
+	if i >= len(text) {
+		return false
+	}
+	next, _ := utf8.DecodeRuneInString(text[i:])
+	if isSentenceEnd(next) || next == '"' || next == '”' || next == '」' {
+		return true
+	}
+	return r == '.' && isLatinWordRune(next)
+}
+
+func appendTrimmed(spans []Span, text string, start, end int) []Span {
+	s := text[start:end]
+	trimmed := strings.TrimRightFunc(s, unicode.IsSpace)
+	if trimmed == "" {
+		return spans
+	}
+	return append(spans, Span{start, start + len(trimmed)})
+}
+
+// SplitWords splits text into spoken words: runs of Latin letters and
+// digits, and single CJK characters. Punctuation and spaces are not words.
+func SplitWords(text string) []Span {
+	var spans []Span
+	start := -1
+	for i, r := range text {
+		switch {
+		case isLatinWordRune(r):
+			if start < 0 {
+				start = i
+			}
+			continue
+		case r == '\'' && start >= 0:
+			// 英文缩写里的撇号，例如 don't
+			continue
+		case isCJK(r):
+			if start >= 0 {
+				spans = append(spans, Span{start, i})
+				start = -1
+			}
+			spans = append(spans, Span{i, i + utf8.RuneLen(r)})
+			continue
+		}
+		if start >= 0 {
+			spans = append(spans, Span{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, Span{start, len(text)})
+	}
+	return spans
+}
+
+// Weight estimates how long s takes to speak, in syllable units
+func Weight(s string) float64 {
+	var w float64
+	inWord := false
+	vowelRun := false
+	syllables := 0
+
+	flushWord := func() {
+		if inWord {
+			if syllables == 0 {
+				syllables = 1
+			}
+			w += float64(syllables) * syllableWeight
+		}
+		inWord, vowelRun, syllables = false, false, 0
+	}
+
+	for _, r := range s {
+		switch {
+		case isCJK(r):
+			flushWord()
+			w += syllableWeight
+		case isLatinWordRune(r):
+			inWord = true
+			if unicode.IsDigit(r) {
+				syllables++
+				vowelRun = false
+			} else if strings.ContainsRune("aeiouyAEIOUY", r) {
+				if !vowelRun {
+					syllables++
+				}
+				vowelRun = true
+			} else {
+				vowelRun = false
+			}
+		default:
+			flushWord()
+			if isSentenceEnd(r) {
+				w += sentencePauseWeight
+			} else if isClauseEnd(r) {
+				w += clausePauseWeight
+			}
+		}
+	}
+	flushWord()
+	return w
+}
+
+// Distribute splits the samples [start, end) among the spans of text in
+// proportion to their estimated speaking time. Text between spans (spaces,
+// punctuation pauses) consumes time too, so the spans do not drift.
+// It returns one [startSample, endSample) pair per span.
+func Distribute(text string, spans []Span, start, end int64) [][2]int64 {
+	out := make([][2]int64, len(spans))
+	if len(spans) == 0 {
+		return out
+	}
+
+	total := Weight(text)
+	weigh := Weight
+	if total == 0 {
+		// 没有可读内容时平均分配
+		total = float64(len(spans))
+		weigh = func(s string) float64 {
+			if s == "" {
+				return 0
+			}
+			return 1
+		}
+	}
+
+	pos := func(w float64) int64 {
+		if w >= total {
+			return end
+		}
+		return start + int64(float64(end-start)*w/total)
+	}
+
+	var acc float64
+	prevEnd := 0
+	for i, span := range spans {
+		acc += Weight(text[prevEnd:span.Start])
+		s := pos(acc)
+		acc += weigh(text[span.Start:span.End])
+		out[i] = [2]int64{s, pos(acc)}
+		prevEnd = span.End
+	}
+	return out
+}
+
+// EstimateWords returns the word timings of seg, estimated from the
+// segment's sample range when the engine did not provide any
+func EstimateWords(seg ai.Segment) []ai.WordTiming {
+	if seg.Words != nil {
+		return seg.Words
+	}
+
+	spans := SplitWords(seg.Text)
+	ranges := Distribute(seg.Text, spans, seg.StartSample, seg.EndSample)
+
+	words := make([]ai.WordTiming, len(spans))
+	for i, span := range spans {
+		words[i] = ai.WordTiming{
+			Text:        seg.Text[span.Start:span.End],
+			Start:       span.Start,
+			End:         span.End,
+			StartSample: ranges[i][0],
+			EndSample:   ranges[i][1],
+		}
+	}
+	return words
+}
+
+func isSentenceEnd(r rune) bool {
+	return strings.ContainsRune("。！？.!?…", r)
+}
+
+func isClauseEnd(r rune) bool {
+	return strings.ContainsRune("，、；：,;:—", r)
+}
+
+func isLatinWordRune(r rune) bool {
+	return r < unicode.MaxLatin1 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,252 @@
+package align
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Transcript collects timed segments on one continuous timeline, so the
+// position of any sample can be mapped back to the words being spoken
+type Transcript struct {
+	mu       sync.RWMutex
+	segments []ai.Segment
+	offsets  []int64         // 每段所属音频流的起点
+	words    []ai.WordTiming // 所有段落的词，时间已换算到整条时间线
+}
+
+// NewTranscript creates an empty transcript
+func NewTranscript() *Transcript {
+	return &Transcript{}
+}
+
+// Add appends a segment. offset is the timeline position of sample 0 of
+// the stream the segment came from. A final segment replaces the
+// estimated ones of the same stream from its text on.
+func (t *Transcript) Add(seg ai.Segment, offset int64) {
+	seg.StartSample += offset
+	seg.EndSample += offset
+
+	words := EstimateWords(ai.Segment{
+		Text:        seg.Text,
+		StartSample: seg.StartSample,
+		EndSample:   seg.EndSample,
+		Words:       shiftWords(seg.Words, offset),
+	})
+	seg.Words = words
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !seg.Estimated {
+		t.dropEstimates(offset, seg.Start)
+	}
+	t.segments = append(t.segments, seg)
+	t.offsets = append(t.offsets, offset)
+	t.words = append(t.words, words...)
+}
+
+// dropEstimates removes the trailing estimated segments of the stream at
+// offset that start at text position start or later
+func (t *Transcript) dropEstimates(offset int64, start int) {
+	n := len(t.segments)
+	for n > 0 {
+		last := t.segments[n-1]
+		if !last.Estimated || t.offsets[n-1] != offset || last.Start < start {
+			break
+		}
+		n--
+	}
+	if n == len(t.segments) {
+		return
+	}
+	dropped := 0
+	for _, seg := range t.segments[n:] {
+		dropped += len(seg.Words)
+	}
+	t.segments = t.segments[:n]
+	t.offsets = t.offsets[:n]
+	t.words = t.words[:len(t.words)-dropped]
+}
+
+func shiftWords(words []ai.WordTiming, offset int64) []ai.WordTiming {
+	if words == nil {
+		return nil
+	}
+	shifted := make([]ai.WordTiming, len(words))
+	for i, w := range words {
+		w.StartSample += offset
+		w.EndSample += offset
+		shifted[i] = w
+	}
+	return shifted
+}
+
+// Segments returns a copy of the segments added so far
+func (t *Transcript) Segments() []ai.Segment {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]ai.Segment(nil), t.segments...)
+}
+
+// Words returns all words on the timeline in order
+func (t *Transcript) Words() []ai.WordTiming {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return append([]ai.WordTiming(nil), t.words...)
+}
+
+// WordAt returns the index of the word being spoken at sample, or of the
+// last word before it when sample falls in a pause. It returns -1 when no
+// word has started yet.
+func (t *Transcript) WordAt(sample int64) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	// 第一个开始时间晚于 sample 的词的前一个
+	i := sort.Search(len(t.words), func(i int) bool {
+		return t.words[i].StartSample > sample
+	})
+	return i - 1
+}
+
+// HeardText returns the text of the segments spoken up to sample, cutting
+// the current segment after the word being spoken
+func (t *Transcript) HeardText(sample int64) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var b strings.Builder
+	for _, seg := range t.segments {
+		if seg.StartSample > sample {
+			break
+		}
+		if seg.EndSample <= sample {
+			b.WriteString(seg.Text)
+			continue
+		}
+
+		cut := 0
+		for _, w := range seg.Words {
+			if w.StartSample > sample {
+				break
+			}
+			cut = w.End
+		}
+		b.WriteString(seg.Text[:cut])
+		break
+	}
+	return b.String()
+}
+
+// RemainingText returns the text of the segments from timeline position
+// from onwards that was not heard by sample, starting again at the first
+// word not heard to its end, so an interrupted stream can be resumed
+func (t *Transcript) RemainingText(from, sample int64) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var b strings.Builder
+	for _, seg := range t.segments {
+		if seg.StartSample < from || seg.EndSample <= sample {
+			continue
+		}
+		if seg.StartSample >= sample {
+			b.WriteString(seg.Text)
+			continue
+		}
+
+		cut := len(seg.Text)
+		for _, w := range seg.Words {
+			if w.EndSample > sample {
+				cut = w.Start
+				break
+			}
+		}
+		b.WriteString(seg.Text[cut:])
+	}
+	return b.String()
+}
+
+// SentenceEnd returns the timeline position where the sentence being
+// spoken at sample ends, or sample itself when nothing is being spoken
+// there
+func (t *Transcript) SentenceEnd(sample int64) int64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, seg := range t.segments {
+		if sample < seg.StartSample || sample >= seg.EndSample {
+			continue
+		}
+		if len(seg.Words) == 0 {
+			return seg.EndSample
+		}
+
+		cur := seg.Words[0]
+		for _, w := range seg.Words {
+			if w.StartSample > sample {
+				break
+			}
+			cur = w
+		}
+		for _, sentence := range SplitSentences(seg.Text) {
+			if sentence.End <= cur.Start {
+				continue
+			}
+			end := sample
+			for _, w := range seg.Words {
+				if w.End <= sentence.End {
+					end = max(end, w.EndSample)
+				}
+			}
+			return end
+		}
+		return seg.EndSample
+	}
+	return sample
+}
+
+// WriteJSON writes the segments with their word timings as JSON
+func (t *Transcript) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(t.Segments()); err != nil {
+		return fmt.Errorf("encode transcript: %w", err)
+	}
+	return nil
+}
+
+// WriteSRT writes the segments as SubRip captions
+func (t *Transcript) WriteSRT(w io.Writer) error {
+	for i, seg := range t.Segments() {
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n",
+			i+1,
+			srtTime(SampleTime(seg.StartSample, seg.SampleRate)),
+			srtTime(SampleTime(seg.EndSample, seg.SampleRate)),
+			seg.Text)
+		if err != nil {
+			return fmt.Errorf("write srt: %w", err)
+		}
+	}
+	return nil
+}
+
+// SampleTime converts a sample offset to a duration
+func SampleTime(sample int64, sampleRate int) time.Duration {
+	if sampleRate <= 0 {
+		return 0
+	}
+	return time.Duration(sample) * time.Second / time.Duration(sampleRate)
+}
+
+// srtTime formats d as "HH:MM:SS,mmm"
+func srtTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,414 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// Loudness normalization defaults
+const (
+	defaultTargetLUFS  = -16.0 // common podcast loudness target
+	defaultCeilingDBFS = -1.0
+	maxBoostDB         = 12.0 // avoid pumping up the noise floor
+	maxCutDB           = -20.0
+	lookaheadTime      = 0.005 // limiter lookahead, 5ms
+	gainTimeConstant   = 0.3   // gain smoothing time constant in seconds
+	releaseTime        = 0.08  // limiter release time in seconds
+	blockTime          = 0.1   // 100ms measurement sub-blocks
+	gateBlocks         = 4     // 400ms gating blocks
+	absoluteGateLUFS   = -70.0
+	relativeGateLU     = -10.0
+)
+
+// LoudnessNormalizer levels PCM16 mono streams to a target integrated
+// loudness (BS.1770 K-weighted, gated) and limits peaks with a lookahead
+// limiter. The gain reached for a voice is remembered, so the next
+// segment from the same voice starts at the right level instead of
+// adapting again from unity gain.
+type LoudnessNormalizer struct {
+	sampleRate int
+	target     float64
+	ceiling    float64
+
+	mu        sync.Mutex
+	voiceGain map[string]float64 // dB
+}
+
+// NewLoudnessNormalizer creates a normalizer for the given sample rate
+func NewLoudnessNormalizer(sampleRate int) *LoudnessNormalizer {
+	return &LoudnessNormalizer{
+		sampleRate: sampleRate,
+		target:     defaultTargetLUFS,
+		ceiling:    defaultCeilingDBFS,
+		voiceGain:  make(map[string]float64),
+	}
+}
+
+// SetTarget sets the target integrated loudness in LUFS
+func (n *LoudnessNormalizer) SetTarget(lufs float64) {
+	n.target = lufs
+}
+
+// SetCeiling sets the limiter ceiling in dBFS
+func (n *LoudnessNormalizer) SetCeiling(dbfs float64) {
+	if dbfs > 0 {
+		dbfs = 0
+	}
+	n.ceiling = dbfs
+}
+
+// VoiceGain returns the remembered gain in dB for a voice
+func (n *LoudnessNormalizer) VoiceGain(voice string) (float64, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	gain, ok := n.voiceGain[voice]
+	return gain, ok
+}
+
+// Process levels the audio stream of one segment spoken by voice
+func (n *LoudnessNormalizer) Process(ctx context.Context, voice string, in <-chan []byte) <-chan []byte {
+	out := make(chan []byte)
+
+	go func() {
+		defer close(out)
+
+		startGain, known := n.VoiceGain(voice)
+		p := newLoudnessProcessor(n.sampleRate, n.target, n.ceiling, startGain, known)
+
+		send := func(chunk []byte) bool {
+			if len(chunk) == 0 {
+				return true
+			}
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for {
+			select {
+			case chunk, ok := <-in:
+				if !ok {
+					if p.measured {
+						n.mu.Lock()
+						n.voiceGain[voice] = p.desiredDB
+						n.mu.Unlock()
+					}
+					send(p.flush())
+					return
+				}
+				if !send(p.process(chunk)) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// biquad is a direct form I second-order IIR filter
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+	x1, x2, y1, y2     float64
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+	return y
+}
+
+// kWeighting returns the BS.1770 pre-filter (high shelf) and RLB high-pass
+// filter, with coefficients derived for any sample rate
+func kWeighting(sampleRate int) (biquad, biquad) {
+	fs := float64(sampleRate)
+
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highpass := biquad{
+		b0: 1, b1: -2, b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	return shelf, highpass
+}
+
+// loudnessMeter measures gated integrated loudness incrementally
+type loudnessMeter struct {
+	shelf, highpass biquad
+	blockSize       int
+	blockSum        float64
+	blockCount      int
+	subBlocks       []float64 // mean square of each 100ms sub-block
+	gated           []float64 // mean square of each 400ms gating block
+}
+
+func newLoudnessMeter(sampleRate int) *loudnessMeter {
+	shelf, highpass := kWeighting(sampleRate)
+	return &loudnessMeter{
+		shelf:     shelf,
+		highpass:  highpass,
+		blockSize: int(float64(sampleRate) * blockTime),
+	}
+}
+
+// add feeds one sample in [-1, 1], returning true when a new gating block
+// completed
+func (m *loudnessMeter) add(x float64) bool {
+	y := m.highpass.process(m.shelf.process(x))
+	m.blockSum += y * y
+	m.blockCount++
+	if m.blockCount < m.blockSize {
+		return false
+	}
+
+	m.subBlocks = append(m.subBlocks, m.blockSum/float64(m.blockCount))
+	m.blockSum, m.blockCount = 0, 0
+	if len(m.subBlocks) < gateBlocks {
+		return false
+	}
+
+	// 400ms blocks with 75% overlap
+	var sum float64
+	for _, ms := range m.subBlocks[len(m.subBlocks)-gateBlocks:] {
+		sum += ms
+	}
+	m.gated = append(m.gated, sum/gateBlocks)
+	return true
+}
+
+// integrated returns the gated integrated loudness in LUFS
+func (m *loudnessMeter) integrated() (float64, bool) {
+	absGate := lufsToPower(absoluteGateLUFS)
+
+	var sum float64
+	var count int
+	for _, p := range m.gated {
+		if p > absGate {
+			sum += p
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+
+	relGate := sum / float64(count) * math.Pow(10, relativeGateLU/10)
+	sum, count = 0, 0
+	for _, p := range m.gated {
+		if p > absGate && p > relGate {
+			sum += p
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, false
+	}
+	return powerToLUFS(sum / float64(count)), true
+}
+
+func powerToLUFS(p float64) float64 {
+	return -0.691 + 10*math.Log10(p)
+}
+
+func lufsToPower(lufs float64) float64 {
+	return math.Pow(10, (lufs+0.691)/10)
+}
+
+// MeasureLoudness returns the gated integrated loudness of PCM16 mono
+// samples in LUFS, or -Inf for silence
+func MeasureLoudness(samples []int16, sampleRate int) float64 {
+	m := newLoudnessMeter(sampleRate)
+	for _, s := range samples {
+		m.add(float64(s) / 32768)
+	}
+	lufs, ok := m.integrated()
+	if !ok {
+		return math.Inf(-1)
+	}
+	return lufs
+}
+
+// loudnessProcessor holds the per-stream state: meter, smoothed gain and
+// the limiter delay line
+type loudnessProcessor struct {
+	meter     *loudnessMeter
+	target    float64
+	desiredDB float64
+	measured  bool
+	gain      float64 // current linear gain
+	gainCoef  float64
+
+	ceiling     float64
+	lookahead   int
+	delay       []float64 // delay line, lookahead samples
+	required    []float64 // gain each sample needs, lookahead+1 samples
+	held        []float64 // peak-held gain for the moving average, lookahead samples
+	heldSum     float64
+	pos         int
+	env         float64
+	releaseCoef float64
+	filled      int
+
+	carry []byte // odd byte left over from the previous chunk
+}
+
+func newLoudnessProcessor(sampleRate int, target, ceilingDB, startGainDB float64, known bool) *loudnessProcessor {
+	lookahead := int(float64(sampleRate) * lookaheadTime)
+	if lookahead < 1 {
+		lookahead = 1
+	}
+
+	p := &loudnessProcessor{
+		meter:       newLoudnessMeter(sampleRate),
+		target:      target,
+		desiredDB:   startGainDB,
+		gain:        dbToGain(startGainDB),
+		gainCoef:    1 - math.Exp(-1/(gainTimeConstant*float64(sampleRate))),
+		ceiling:     dbToGain(ceilingDB),
+		lookahead:   lookahead,
+		delay:       make([]float64, lookahead),
+		required:    make([]float64, lookahead+1),
+		held:        make([]float64, lookahead),
+		env:         1,
+		releaseCoef: 1 - math.Exp(-1/(releaseTime*float64(sampleRate))),
+	}
+	if !known {
+		p.desiredDB = 0
+		p.gain = 1
+	}
+	for i := range p.required {
+		p.required[i] = 1
+	}
+	for i := range p.held {
+		p.held[i] = 1
+	}
+	p.heldSum = float64(lookahead)
+	return p
+}
+
+// process levels one chunk of PCM16 little-endian bytes. Output lags the
+// input by the lookahead, so the result may be shorter than the input.
+func (p *loudnessProcessor) process(chunk []byte) []byte {
+	data := chunk
+	if len(p.carry) > 0 {
+		data = append(p.carry, chunk...)
+		p.carry = nil
+	}
+	if len(data)%2 == 1 {
+		p.carry = []byte{data[len(data)-1]}
+		data = data[:len(data)-1]
+	}
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i+1 < len(data); i += 2 {
+		x := float64(int16(binary.LittleEndian.Uint16(data[i:]))) / 32768
+		if p.meter.add(x) {
+			p.updateGain()
+		}
+		p.gain += (dbToGain(p.desiredDB) - p.gain) * p.gainCoef
+
+		if y, ok := p.limit(x * p.gain); ok {
+			out = binary.LittleEndian.AppendUint16(out, uint16(toInt16(y)))
+		}
+	}
+	return out
+}
+
+// flush drains the limiter delay line at the end of the stream
+func (p *loudnessProcessor) flush() []byte {
+	var out []byte
+	for i := 0; i < p.lookahead; i++ {
+		if y, ok := p.limit(0); ok {
+			out = binary.LittleEndian.AppendUint16(out, uint16(toInt16(y)))
+		}
+	}
+	return out
+}
+
+// updateGain recomputes the gain needed to reach the target loudness
+func (p *loudnessProcessor) updateGain() {
+	lufs, ok := p.meter.integrated()
+	if !ok {
+		return
+	}
+	gain := p.target - lufs
+	p.desiredDB = math.Max(maxCutDB, math.Min(maxBoostDB, gain))
+	p.measured = true
+}
+
+// limit pushes one sample through the lookahead limiter. The gain needed
+// by each sample is held for lookahead+1 samples and then averaged over
+// lookahead samples, so the gain is already down when a peak leaves the
+// delay line and the reduction ramps in without clicks.
+func (p *loudnessProcessor) limit(x float64) (float64, bool) {
+	req := 1.0
+	if a := math.Abs(x); a > p.ceiling {
+		req = p.ceiling / a
+	}
+	p.required[p.pos%len(p.required)] = req
+
+	held := 1.0
+	for _, r := range p.required {
+		held = math.Min(held, r)
+	}
+
+	slot := p.pos % p.lookahead
+	p.heldSum += held - p.held[slot]
+	p.held[slot] = held
+	smooth := p.heldSum / float64(p.lookahead)
+
+	if smooth < p.env {
+		p.env = smooth
+	} else {
+		p.env += (smooth - p.env) * p.releaseCoef
+	}
+
+	delayed := p.delay[slot]
+	p.delay[slot] = x
+	p.pos++
+
+	if p.filled < p.lookahead {
+		p.filled++
+		return 0, false
+	}
+	return delayed * p.env, true
+}
+
+func dbToGain(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func toInt16(x float64) int16 {
+	v := math.Round(x * 32768)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
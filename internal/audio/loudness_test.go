@@ -0,0 +1,138 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+const testSampleRate = 24000
+
+// sine generates a 1kHz tone at the given peak level in dBFS
+func sine(seconds, dbfs float64) []int16 {
+	amp := math.Pow(10, dbfs/20) * 32767
+	n := int(seconds * testSampleRate)
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(amp * math.Sin(2*math.Pi*1000*float64(i)/testSampleRate))
+	}
+	return samples
+}
+
+func toBytes(samples []int16) []byte {
+	out := make([]byte, 0, len(samples)*2)
+	for _, s := range samples {
+		out = binary.LittleEndian.AppendUint16(out, uint16(s))
+	}
+	return out
+}
+
+func fromBytes(data []byte) []int16 {
+	samples := make([]int16, len(data)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(data[i*2:]))
+	}
+	return samples
+}
+
+// runNormalizer feeds samples through the normalizer in uneven chunks
+func runNormalizer(n *LoudnessNormalizer, voice string, samples []int16) []int16 {
+	data := toBytes(samples)
+	in := make(chan []byte)
+	go func() {
+		defer close(in)
+		// odd chunk sizes split samples across chunks
+		for len(data) > 0 {
+			size := 4801
+			if size > len(data) {
+				size = len(data)
+			}
+			in <- data[:size]
+			data = data[size:]
+		}
+	}()
+
+	var out []byte
+	for chunk := range n.Process(context.Background(), voice, in) {
+		out = append(out, chunk...)
+	}
+	return fromBytes(out)
+}
+
+func TestMeasureLoudness(t *testing.T) {
+	// A full-scale 1kHz sine measures about -3 LUFS
+	got := MeasureLoudness(sine(2, 0), testSampleRate)
+	if math.Abs(got-(-3.0)) > 0.5 {
+		t.Errorf("full-scale sine measured %.2f LUFS, want about -3", got)
+	}
+
+	quieter := MeasureLoudness(sine(2, -20), testSampleRate)
+	if math.Abs((got-quieter)-20) > 0.1 {
+		t.Errorf("-20dB sine measured %.2f LUFS, want %.2f", quieter, got-20)
+	}
+
+	if !math.IsInf(MeasureLoudness(make([]int16, testSampleRate), testSampleRate), -1) {
+		t.Error("silence should measure -Inf")
+	}
+}
+
+func TestLoudnessNormalizer_ReachesTarget(t *testing.T) {
+	for _, level := range []float64{-24, -6} {
+		n := NewLoudnessNormalizer(testSampleRate)
+		out := runNormalizer(n, "host", sine(6, level))
+
+		if len(out) != 6*testSampleRate {
+			t.Fatalf("output has %d samples, want %d", len(out), 6*testSampleRate)
+		}
+
+		// only look at the second half, after the gain has adapted
+		got := MeasureLoudness(out[len(out)/2:], testSampleRate)
+		if math.Abs(got-defaultTargetLUFS) > 1 {
+			t.Errorf("input at %.0f dBFS normalized to %.2f LUFS, want %.0f", level, got, defaultTargetLUFS)
+		}
+	}
+}
+
+func TestLoudnessNormalizer_LimiterCeiling(t *testing.T) {
+	n := NewLoudnessNormalizer(testSampleRate)
+	n.SetTarget(-3) // loud target pushes peaks over the ceiling
+
+	input := sine(3, -12)
+	// add a full-scale spike
+	for i := 30000; i < 30010; i++ {
+		input[i] = 32767
+	}
+
+	ceiling := math.Pow(10, defaultCeilingDBFS/20) * 32768
+	for i, s := range runNormalizer(n, "host", input) {
+		if math.Abs(float64(s)) > ceiling+1 {
+			t.Fatalf("sample %d = %d exceeds ceiling %.0f", i, s, ceiling)
+		}
+	}
+}
+
+func TestLoudnessNormalizer_VoiceMemory(t *testing.T) {
+	n := NewLoudnessNormalizer(testSampleRate)
+
+	if _, ok := n.VoiceGain("host"); ok {
+		t.Fatal("unexpected gain for unknown voice")
+	}
+	runNormalizer(n, "host", sine(3, -30))
+
+	gain, ok := n.VoiceGain("host")
+	if !ok {
+		t.Fatal("gain for voice was not remembered")
+	}
+	if gain < 10 {
+		t.Errorf("remembered gain %.2f dB, expected a large boost", gain)
+	}
+
+	// the next segment of the same voice should start near the target
+	out := runNormalizer(n, "host", sine(1, -26))
+	start := MeasureLoudness(out[:testSampleRate/2], testSampleRate)
+	fresh := MeasureLoudness(runNormalizer(NewLoudnessNormalizer(testSampleRate), "guest", sine(1, -26))[:testSampleRate/2], testSampleRate)
+	if math.Abs(start-defaultTargetLUFS) >= math.Abs(fresh-defaultTargetLUFS) {
+		t.Errorf("remembered voice starts at %.2f LUFS, unknown voice at %.2f; memory should be closer to %.0f", start, fresh, defaultTargetLUFS)
+	}
+}
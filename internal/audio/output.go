@@ -0,0 +1,159 @@
+package audio
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+// deviceBuffer is how much audio an output queues ahead of the speaker.
+// It bounds how long audio keeps coming out after Stop.
+const deviceBuffer = 40 * time.Millisecond
+
+// Output is an audio device that plays PCM16 little-endian audio
+type Output interface {
+	// Write queues audio for playback, blocking while the device buffer
+	// is full
+	Write(pcm []byte) (int, error)
+
+	// Buffered returns how much of the written audio has not been played
+	Buffered() time.Duration
+
+	// Discard drops written audio that has not been played yet
+	Discard()
+
+	// Close releases the device
+	Close() error
+}
+
+// FileOutput writes played audio to a WAV file, for running without a
+// sound card. In realtime mode it plays at the speed of a real device, so
+// interrupting playback leaves in the file only what would have been
+// heard.
+type FileOutput struct {
+	file       *os.File
+	format     wav.Format
+	bytesPerMs float64
+
+	mu       sync.Mutex
+	realtime bool
+	written  int64     // data bytes in the file
+	ends     time.Time // when the audio written so far finishes playing
+	closed   bool
+}
+
+// NewFileOutput creates a WAV file at path for PCM16 audio
+func NewFileOutput(path string, sampleRate, channels int) (*FileOutput, error) {
+	format := wav.PCM16(sampleRate, channels)
+	if err := format.Validate(); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	// The sizes in the header are filled in by Close
+	if err := wav.Write(f, format, nil); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &FileOutput{
+		file:       f,
+		format:     format,
+		bytesPerMs: float64(format.ByteRate()) / 1000,
+	}, nil
+}
+
+// SetRealtime paces writes to the sample rate instead of writing as fast
+// as audio arrives
+func (o *FileOutput) SetRealtime(realtime bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.realtime = realtime
+}
+
+// Write appends audio to the file. In realtime mode it blocks while more
+// than deviceBuffer of audio is waiting to be played.
+func (o *FileOutput) Write(pcm []byte) (int, error) {
+	o.mu.Lock()
+	if o.closed {
+		o.mu.Unlock()
+		return 0, os.ErrClosed
+	}
+	n, err := o.file.Write(pcm)
+	o.written += int64(n)
+
+	var wait time.Duration
+	if o.realtime {
+		now := time.Now()
+		if o.ends.Before(now) {
+			// The device ran dry and played silence meanwhile
+			o.ends = now
+		}
+		o.ends = o.ends.Add(o.duration(n))
+		wait = o.ends.Sub(now) - deviceBuffer
+	}
+	o.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return n, err
+}
+
+// Buffered returns how much written audio a real device would still be
+// playing
+func (o *FileOutput) Buffered() time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buffered()
+}
+
+func (o *FileOutput) buffered() time.Duration {
+	if !o.realtime {
+		return 0
+	}
+	return max(0, time.Until(o.ends))
+}
+
+// Discard removes the audio that has not been played from the end of the
+// file
+func (o *FileOutput) Discard() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return
+	}
+
+	unplayed := int64(o.buffered().Seconds()*float64(o.format.ByteRate())) / int64(o.format.BlockAlign()) * int64(o.format.BlockAlign())
+	unplayed = min(unplayed, o.written)
+	o.written -= unplayed
+	o.ends = time.Now()
+	if unplayed > 0 {
+		o.file.Truncate(wav.HeaderSize + o.written)
+		o.file.Seek(0, io.SeekEnd)
+	}
+}
+
+// Close writes the final sizes into the WAV header and closes the file
+func (o *FileOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return nil
+	}
+	o.closed = true
+
+	if err := wav.WriteSizes(o.file, o.written); err != nil {
+		o.file.Close()
+		return err
+	}
+	return o.file.Close()
+}
+
+func (o *FileOutput) duration(n int) time.Duration {
+	return time.Duration(float64(n) / o.bytesPerMs * float64(time.Millisecond))
+}
@@ -0,0 +1,156 @@
+//go:build oto
+
+package audio
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/ebitengine/oto/v3"
+)
+
+// oto supports a single context per process, opened on first use
+var (
+	otoOnce     sync.Once
+	otoContext  *oto.Context
+	otoRate     int
+	otoChannels int
+	otoErr      error
+)
+
+func openOtoContext(sampleRate, channels int) (*oto.Context, error) {
+	otoOnce.Do(func() {
+		ctx, ready, err := oto.NewContext(&oto.NewContextOptions{
+			SampleRate:   sampleRate,
+			ChannelCount: channels,
+			Format:       oto.FormatSignedInt16LE,
+			BufferSize:   deviceBuffer / 2,
+		})
+		if err != nil {
+			otoErr = fmt.Errorf("open audio device: %w", err)
+			return
+		}
+		<-ready
+		otoContext, otoRate, otoChannels = ctx, sampleRate, channels
+	})
+	if otoErr != nil {
+		return nil, otoErr
+	}
+	if sampleRate != otoRate || channels != otoChannels {
+		return nil, fmt.Errorf("audio device already open at %d Hz with %d channels", otoRate, otoChannels)
+	}
+	return otoContext, nil
+}
+
+// OtoOutput plays audio on the default sound card through oto. Writes go
+// to a small queue the oto player pulls from; when the queue runs dry the
+// player gets silence, so it never stops and restarts.
+type OtoOutput struct {
+	player     *oto.Player
+	byteRate   int
+	frameBytes int
+	limit      int // queued bytes before Write blocks
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []byte
+	closed bool
+}
+
+// NewOtoOutput opens the default sound card
+func NewOtoOutput(sampleRate, channels int) (*OtoOutput, error) {
+	ctx, err := openOtoContext(sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &OtoOutput{
+		byteRate:   sampleRate * channels * 2,
+		frameBytes: channels * 2,
+	}
+	o.limit = int(deviceBuffer.Seconds()*float64(o.byteRate)) / 2 / o.frameBytes * o.frameBytes
+	o.cond = sync.NewCond(&o.mu)
+	o.player = ctx.NewPlayer(otoSource{o})
+	o.player.SetBufferSize(o.limit)
+	o.player.Play()
+	return o, nil
+}
+
+func defaultOutput(sampleRate, channels int) (Output, error) {
+	return NewOtoOutput(sampleRate, channels)
+}
+
+// Write queues audio, blocking while the queue is full
+func (o *OtoOutput) Write(pcm []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	written := 0
+	for written < len(pcm) {
+		for !o.closed && len(o.queue) >= o.limit {
+			o.cond.Wait()
+		}
+		if o.closed {
+			return written, io.ErrClosedPipe
+		}
+		n := min(len(pcm)-written, o.limit-len(o.queue))
+		o.queue = append(o.queue, pcm[written:written+n]...)
+		written += n
+	}
+	return written, o.player.Err()
+}
+
+// Buffered returns how much audio is queued here and inside oto
+func (o *OtoOutput) Buffered() time.Duration {
+	o.mu.Lock()
+	queued := len(o.queue)
+	o.mu.Unlock()
+	queued += o.player.BufferedSize()
+	return time.Duration(queued) * time.Second / time.Duration(o.byteRate)
+}
+
+// Discard drops queued audio, including what oto has buffered
+func (o *OtoOutput) Discard() {
+	o.mu.Lock()
+	o.queue = o.queue[:0]
+	o.cond.Broadcast()
+	o.mu.Unlock()
+
+	// Seeking resets oto's internal buffer and keeps the player running
+	o.player.Seek(0, io.SeekCurrent)
+}
+
+// Close stops the output. The oto context stays open for later outputs.
+func (o *OtoOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.closed = true
+	o.cond.Broadcast()
+	o.player.Pause()
+	return nil
+}
+
+// otoSource is the reader oto pulls audio from
+type otoSource struct {
+	o *OtoOutput
+}
+
+// Read hands queued audio to oto, padding with silence
+func (s otoSource) Read(p []byte) (int, error) {
+	o := s.o
+	o.mu.Lock()
+	n := copy(p, o.queue)
+	o.queue = o.queue[:copy(o.queue, o.queue[n:])]
+	o.cond.Broadcast()
+	o.mu.Unlock()
+
+	clear(p[n:])
+	return len(p) / o.frameBytes * o.frameBytes, nil
+}
+
+// Seek lets oto reset its buffer; the stream has no position to move
+func (s otoSource) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
@@ -0,0 +1,10 @@
+//go:build !oto
+
+package audio
+
+import "errors"
+
+// defaultOutput reports that sound card playback was not compiled in
+func defaultOutput(sampleRate, channels int) (Output, error) {
+	return nil, errors.New("audio playback requires building with -tags oto; use Player.SetOutput with a FileOutput to run headless")
+}
@@ -2,40 +2,387 @@ package audio
 
 import (
 	"context"
+	"encoding/binary"
+	"sync"
+	"time"
 
 	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/echo"
 )
 
-// Player implements AudioPlayer using oto library
+// Player defaults
+const (
+	playBlock     = 10 * time.Millisecond // audio written per step, the reaction time to Stop
+	defaultFade   = 5 * time.Millisecond  // ramp down on stop to avoid a click
+	historyLength = 200 * time.Millisecond
+	stopTimeout   = time.Second
+
+	defaultPositionInterval = 50 * time.Millisecond
+)
+
+// Player implements AudioPlayer on top of an Output device. Audio is
+// written in short blocks so that Stop and context cancellation take
+// effect within one block plus the device buffer.
 type Player struct {
 	sampleRate int
 	channels   int
-	// TODO: Add oto.Player or similar audio device handle
+	reference  *echo.Reference
+	fade       time.Duration
+
+	mu      sync.Mutex
+	output  Output
+	stop    chan struct{} // closed to stop the stream being played
+	done    chan struct{} // closed when that stream has stopped
+	history []byte        // the most recently written audio, for the fade-out
+
+	// position in the current stream, in frames
+	streamID uint64
+	playing  bool
+	written  int64 // frames written to the output
+	played   int64 // frames heard, once the stream has ended
+
+	// volume, ramping from gain to gainTarget by gainStep per frame
+	gain       float64
+	gainTarget float64
+	gainStep   float64
+
+	onPosition       func(ai.PlaybackPosition)
+	positionInterval time.Duration
+	reported         time.Time
 }
 
-// NewPlayer creates a new audio player
-func NewPlayer() ai.AudioPlayer {
+// NewPlayer creates a new audio player. It plays on the sound card unless
+// SetOutput chooses another output.
+func NewPlayer() *Player {
 	return &Player{
 		sampleRate: 24000, // default sample rate for OpenAI TTS
 		channels:   1,     // mono
+		fade:       defaultFade,
+		gain:       1,
+		gainTarget: 1,
+	}
+}
+
+// SetOutput plays on out instead of the sound card
+func (p *Player) SetOutput(out Output) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.output = out
+}
+
+// SetFade sets how long the audio ramps down when playback is stopped
+func (p *Player) SetFade(d time.Duration) {
+	p.fade = d
+}
+
+// SetReference publishes everything played to an echo canceller's
+// reference, nil disables it
+func (p *Player) SetReference(ref *echo.Reference) {
+	p.reference = ref
+}
+
+// Duck ramps the volume to gain over ramp, 1 being full volume. It
+// applies to audio written from now on, so it is heard after the device
+// buffer, and lasts until changed again.
+func (p *Player) Duck(gain float64, ramp time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gainTarget = max(0, min(1, gain))
+	frames := ramp.Seconds() * float64(p.sampleRate)
+	if frames < 1 {
+		p.gain = p.gainTarget
+		p.gainStep = 0
+		return
+	}
+	p.gainStep = (p.gainTarget - p.gain) / frames
+}
+
+// Position returns how much of the current stream has been heard, or of
+// the last one once it has ended. Audio written to the device but still
+// in its buffer does not count; the fade-out after a stop does.
+func (p *Player) Position() ai.PlaybackPosition {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.position()
+}
+
+// SetPositionCallback calls fn with the position about every interval
+// while a stream plays and once when it ends. fn runs on the playing
+// goroutine and must not block. nil removes the callback.
+func (p *Player) SetPositionCallback(interval time.Duration, fn func(ai.PlaybackPosition)) {
+	if interval <= 0 {
+		interval = defaultPositionInterval
 	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onPosition = fn
+	p.positionInterval = interval
 }
 
-// PlayStream plays audio from a stream channel
+// PlayStream plays audio from a stream channel until the stream ends and
+// its audio has been played, ctx is cancelled or Stop is called. Audio
+// still in the stream after a stop is read and dropped.
 func (p *Player) PlayStream(ctx context.Context, audioStream <-chan []byte) error {
-	// TODO: Implement audio playback using oto library
-	// - Initialize oto player
-	// - Read chunks from audioStream
-	// - Write to audio device
-	// - Handle context cancellation
-	for chunk := range audioStream {
-		_ = chunk // TODO: Play chunk
+	out, err := p.device()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	stop, done := make(chan struct{}), make(chan struct{})
+	p.stop, p.done = stop, done
+	p.streamID++
+	p.playing = true
+	p.written, p.played = 0, 0
+	p.reported = time.Now()
+	p.mu.Unlock()
+	defer close(done)
+	defer p.finish()
+
+	frame := p.channels * 2
+	blockBytes := int(playBlock.Seconds()*float64(p.sampleRate)) * frame
+	var pending []byte
+
+	for {
+		select {
+		case chunk, ok := <-audioStream:
+			if !ok {
+				return p.drain(ctx, stop, out)
+			}
+			pending = append(pending, chunk...)
+			for len(pending) >= frame {
+				n := min(len(pending), blockBytes) / frame * frame
+				if err := p.write(out, pending[:n]); err != nil {
+					return err
+				}
+				pending = pending[n:]
+
+				select {
+				case <-ctx.Done():
+					p.halt(out, audioStream)
+					return ctx.Err()
+				case <-stop:
+					p.halt(out, audioStream)
+					return nil
+				default:
+				}
+			}
+
+		case <-ctx.Done():
+			p.halt(out, audioStream)
+			return ctx.Err()
+		case <-stop:
+			p.halt(out, audioStream)
+			return nil
+		}
 	}
-	return nil
 }
 
-// Stop immediately stops the current playback
+// Stop immediately stops the current playback. It returns once the
+// output has been silenced.
 func (p *Player) Stop() error {
-	// TODO: Stop audio playback and cleanup
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop = nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		select {
+		case <-done:
+		case <-time.After(stopTimeout):
+		}
+	}
+	if p.reference != nil {
+		p.reference.Reset()
+	}
+	return nil
+}
+
+// Close releases the output device
+func (p *Player) Close() error {
+	p.Stop()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.output == nil {
+		return nil
+	}
+	err := p.output.Close()
+	p.output = nil
+	return err
+}
+
+// device returns the output, opening the sound card on first use
+func (p *Player) device() (Output, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.output == nil {
+		out, err := defaultOutput(p.sampleRate, p.channels)
+		if err != nil {
+			return nil, err
+		}
+		p.output = out
+	}
+	return p.output, nil
+}
+
+func (p *Player) write(out Output, block []byte) error {
+	p.mu.Lock()
+	block = p.applyGain(block)
+	p.mu.Unlock()
+
+	if _, err := out.Write(block); err != nil {
+		return err
+	}
+	if p.reference != nil {
+		p.reference.Write(block, p.sampleRate)
+	}
+
+	p.mu.Lock()
+	p.written += int64(len(block) / (p.channels * 2))
+	p.history = append(p.history, block...)
+	if limit := int(historyLength.Seconds()*float64(p.sampleRate)) * p.channels * 2; len(p.history) > limit {
+		p.history = append(p.history[:0], p.history[len(p.history)-limit:]...)
+	}
+	p.mu.Unlock()
+
+	p.report(false)
+	return nil
+}
+
+// applyGain returns block at the current volume, moving the volume along
+// its ramp. It must be called with mu held.
+func (p *Player) applyGain(block []byte) []byte {
+	if p.gain == 1 && p.gainTarget == 1 {
+		return block
+	}
+	out := make([]byte, len(block))
+	frame := p.channels * 2
+	for i := 0; i+frame <= len(block); i += frame {
+		if p.gain != p.gainTarget {
+			p.gain += p.gainStep
+			if p.gainStep == 0 || (p.gainStep > 0) == (p.gain > p.gainTarget) {
+				p.gain = p.gainTarget
+			}
+		}
+		for off := i; off < i+frame; off += 2 {
+			s := int16(binary.LittleEndian.Uint16(block[off:]))
+			binary.LittleEndian.PutUint16(out[off:], uint16(int16(float64(s)*p.gain)))
+		}
+	}
+	return out
+}
+
+// position must be called with mu held
+func (p *Player) position() ai.PlaybackPosition {
+	pos := ai.PlaybackPosition{
+		StreamID:   p.streamID,
+		Samples:    p.played,
+		SampleRate: p.sampleRate,
+		Playing:    p.playing,
+	}
+	if p.playing && p.output != nil {
+		pending := int64(p.output.Buffered().Seconds() * float64(p.sampleRate))
+		pos.Samples = max(0, p.written-pending)
+	}
+	return pos
+}
+
+// report passes the position to the callback when the interval has
+// passed since the last report, or always when force is set
+func (p *Player) report(force bool) {
+	p.mu.Lock()
+	fn := p.onPosition
+	if fn == nil || !force && time.Since(p.reported) < p.positionInterval {
+		p.mu.Unlock()
+		return
+	}
+	p.reported = time.Now()
+	pos := p.position()
+	p.mu.Unlock()
+
+	fn(pos)
+}
+
+// finish records the end of the stream and reports its final position
+func (p *Player) finish() {
+	p.mu.Lock()
+	if p.playing {
+		// Not halted, so everything written was played
+		p.played = p.written
+		p.playing = false
+	}
+	p.mu.Unlock()
+	p.report(true)
+}
+
+// drain waits until the written audio has been played
+func (p *Player) drain(ctx context.Context, stop <-chan struct{}, out Output) error {
+	for out.Buffered() > 0 {
+		select {
+		case <-ctx.Done():
+			p.halt(out, nil)
+			return ctx.Err()
+		case <-stop:
+			p.halt(out, nil)
+			return nil
+		case <-time.After(playBlock):
+		}
+		p.report(false)
+	}
+	p.mu.Lock()
+	p.history = p.history[:0]
+	p.mu.Unlock()
 	return nil
 }
+
+// halt drops the audio the device has not played yet and replaces it with
+// a short fade-out of that same audio, so the sound ramps down from where
+// the listener is instead of cutting off
+func (p *Player) halt(out Output, audioStream <-chan []byte) {
+	frame := p.channels * 2
+	unplayed := int(out.Buffered().Seconds()*float64(p.sampleRate)) * frame
+	out.Discard()
+
+	p.mu.Lock()
+	history := p.history
+	p.history = nil
+	p.mu.Unlock()
+
+	unplayed = min(unplayed, len(history))
+	next := history[len(history)-unplayed:]
+	fadeBytes := int(p.fade.Seconds()*float64(p.sampleRate)) * frame
+	n := min(fadeBytes, len(next)) / frame * frame
+	if n > 0 {
+		out.Write(fadeOut(next[:n], p.channels))
+	}
+
+	// The listener heard up to where the fade-out ended
+	p.mu.Lock()
+	p.played = max(0, p.written-int64((unplayed-n)/frame))
+	p.playing = false
+	p.mu.Unlock()
+
+	// Producers may still be sending; let them finish without blocking
+	if audioStream != nil {
+		go func() {
+			for range audioStream {
+			}
+		}()
+	}
+}
+
+// fadeOut returns a copy of pcm ramped linearly down to silence
+func fadeOut(pcm []byte, channels int) []byte {
+	out := make([]byte, len(pcm))
+	frames := len(pcm) / 2 / channels
+	for i := 0; i < frames; i++ {
+		gain := float64(frames-1-i) / float64(frames)
+		for c := 0; c < channels; c++ {
+			off := (i*channels + c) * 2
+			s := int16(binary.LittleEndian.Uint16(pcm[off:]))
+			binary.LittleEndian.PutUint16(out[off:], uint16(int16(float64(s)*gain)))
+		}
+	}
+	return out
+}
@@ -0,0 +1,298 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+const playerRate = 24000
+
+// constant 生成指定时长、样本值恒定的 PCM
+func constant(d time.Duration, value int16) []byte {
+	n := int(d.Seconds() * playerRate)
+	out := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(value))
+	}
+	return out
+}
+
+// stream 按给定大小切块发送，模拟 TTS 的不规则分块
+func stream(pcm []byte, chunk int) <-chan []byte {
+	ch := make(chan []byte)
+	go func() {
+		defer close(ch)
+		for len(pcm) > 0 {
+			n := min(chunk, len(pcm))
+			ch <- pcm[:n]
+			pcm = pcm[n:]
+		}
+	}()
+	return ch
+}
+
+func fileOutput(t *testing.T, realtime bool) (*FileOutput, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out.wav")
+	out, err := NewFileOutput(path, playerRate, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.SetRealtime(realtime)
+	return out, path
+}
+
+func readOutput(t *testing.T, out *FileOutput, path string) []byte {
+	t.Helper()
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	f, err := wav.Decode(data)
+	if err != nil {
+		t.Fatalf("output is not a valid WAV: %v", err)
+	}
+	return f.Data
+}
+
+func TestPlayer_PlaysStream(t *testing.T) {
+	out, path := fileOutput(t, false)
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	// 奇数长度的分块也要按完整样本写出
+	pcm := constant(300*time.Millisecond, 1000)
+	if err := p.PlayStream(context.Background(), stream(pcm, 1001)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readOutput(t, out, path); !bytes.Equal(got, pcm) {
+		t.Errorf("played %d bytes, want the %d bytes streamed", len(got), len(pcm))
+	}
+}
+
+func TestPlayer_WaitsForPlayback(t *testing.T) {
+	out, _ := fileOutput(t, true)
+	defer out.Close()
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	start := time.Now()
+	p.PlayStream(context.Background(), stream(constant(200*time.Millisecond, 1000), 4800))
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("PlayStream returned after %v, before 200ms of audio was played", elapsed)
+	}
+}
+
+// checkStopped 检查输出在停止点附近结束，并以淡出收尾
+func checkStopped(t *testing.T, played []byte, at time.Duration) {
+	t.Helper()
+	heard := time.Duration(len(played)/2) * time.Second / playerRate
+	if heard < at-playBlock || heard > at+deviceBuffer+defaultFade+playBlock {
+		t.Errorf("%v of audio played when stopped at %v", heard, at)
+	}
+
+	fade := int(defaultFade.Seconds() * playerRate)
+	tail := played[len(played)-fade*2:]
+	prev := int16(1000)
+	for i := 0; i < fade; i++ {
+		s := int16(binary.LittleEndian.Uint16(tail[i*2:]))
+		if s > prev {
+			t.Fatalf("fade-out rises at sample %d: %d after %d", i, s, prev)
+		}
+		prev = s
+	}
+	if prev > 10 {
+		t.Errorf("output ends at %d, want silence", prev)
+	}
+}
+
+func TestPlayer_Stop(t *testing.T) {
+	out, path := fileOutput(t, true)
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	result := make(chan error)
+	go func() {
+		result <- p.PlayStream(context.Background(), stream(constant(time.Second, 1000), 2400))
+	}()
+
+	time.Sleep(150 * time.Millisecond)
+	stopped := time.Now()
+	p.Stop()
+	if d := time.Since(stopped); d > 2*playBlock {
+		t.Errorf("Stop took %v", d)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("PlayStream() = %v after Stop", err)
+	}
+
+	checkStopped(t, readOutput(t, out, path), 150*time.Millisecond)
+}
+
+func TestPlayer_Cancel(t *testing.T) {
+	out, path := fileOutput(t, true)
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	// 生产者在播放停止后不应阻塞
+	produced := make(chan struct{})
+	ch := make(chan []byte)
+	go func() {
+		defer close(produced)
+		defer close(ch)
+		pcm := constant(time.Second, 1000)
+		for i := 0; i < len(pcm); i += 2400 {
+			ch <- pcm[i : i+2400]
+		}
+	}()
+
+	if err := p.PlayStream(ctx, ch); err != context.DeadlineExceeded {
+		t.Errorf("PlayStream() = %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case <-produced:
+	case <-time.After(time.Second):
+		t.Fatal("producer blocked after playback stopped")
+	}
+
+	checkStopped(t, readOutput(t, out, path), 150*time.Millisecond)
+}
+
+func TestPlayer_StopIdle(t *testing.T) {
+	p := NewPlayer()
+	if err := p.Stop(); err != nil {
+		t.Errorf("Stop() = %v while idle", err)
+	}
+}
+
+func TestPlayer_Position(t *testing.T) {
+	out, path := fileOutput(t, true)
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	var mu sync.Mutex
+	var reports []ai.PlaybackPosition
+	p.SetPositionCallback(20*time.Millisecond, func(pos ai.PlaybackPosition) {
+		mu.Lock()
+		reports = append(reports, pos)
+		mu.Unlock()
+	})
+
+	result := make(chan error)
+	go func() {
+		result <- p.PlayStream(context.Background(), stream(constant(time.Second, 1000), 2400))
+	}()
+
+	time.Sleep(150 * time.Millisecond)
+	pos := p.Position()
+	if !pos.Playing || pos.StreamID != 1 {
+		t.Errorf("Position() = %+v while playing the first stream", pos)
+	}
+	// 已写入设备缓冲但尚未播放的音频不计入
+	if e := pos.Elapsed(); e < 150*time.Millisecond-3*playBlock || e > 150*time.Millisecond {
+		t.Errorf("Elapsed() = %v after 150ms of playback", e)
+	}
+
+	p.Stop()
+	<-result
+
+	// 停止后的位置与实际输出一致，包括淡出
+	pos = p.Position()
+	played := int64(len(readOutput(t, out, path)) / 2)
+	if pos.Playing || pos.Samples < played-int64(playerRate/100) || pos.Samples > played {
+		t.Errorf("Position() = %+v after Stop, %d samples were played", pos, played)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) < 4 {
+		t.Fatalf("got %d position reports in 150ms, want one about every 20ms", len(reports))
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i].Samples < reports[i-1].Samples {
+			t.Errorf("position went back from %d to %d", reports[i-1].Samples, reports[i].Samples)
+		}
+	}
+	if last := reports[len(reports)-1]; last != pos {
+		t.Errorf("last report %+v, want the final position %+v", last, pos)
+	}
+}
+
+func TestPlayer_PositionNextStream(t *testing.T) {
+	out, _ := fileOutput(t, false)
+	defer out.Close()
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	pcm := constant(100*time.Millisecond, 1000)
+	for i := 1; i <= 2; i++ {
+		p.PlayStream(context.Background(), stream(pcm, 1000))
+		want := ai.PlaybackPosition{StreamID: uint64(i), Samples: int64(len(pcm) / 2), SampleRate: playerRate}
+		if got := p.Position(); got != want {
+			t.Errorf("Position() = %+v after stream %d, want %+v", got, i, want)
+		}
+	}
+}
+
+func TestPlayer_Duck(t *testing.T) {
+	out, path := fileOutput(t, false)
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	pcm := constant(100*time.Millisecond, 1000)
+	p.Duck(0.5, 0)
+	p.PlayStream(context.Background(), stream(pcm, 4800))
+
+	// 音量平滑恢复，没有跳变
+	p.Duck(1, 20*time.Millisecond)
+	p.PlayStream(context.Background(), stream(pcm, 4800))
+
+	played := readOutput(t, out, path)
+	sample := func(i int) int16 { return int16(binary.LittleEndian.Uint16(played[i*2:])) }
+	half := len(pcm) / 2
+	if s := sample(half - 1); s != 500 {
+		t.Errorf("ducked sample = %d, want 500", s)
+	}
+	ramp := int(0.02 * playerRate)
+	for i := half + 1; i < half+ramp; i++ {
+		if d := sample(i) - sample(i-1); d < 0 || d > 2 {
+			t.Fatalf("volume jumps by %d at sample %d", d, i)
+		}
+	}
+	if s := sample(half + ramp); s != 1000 {
+		t.Errorf("sample after the ramp = %d, want 1000", s)
+	}
+}
+
+func TestPlayer_PlayAfterStop(t *testing.T) {
+	out, path := fileOutput(t, false)
+	p := NewPlayer()
+	p.SetOutput(out)
+
+	// 停止后播放器可以继续使用，重复停止无副作用
+	p.Stop()
+	p.Stop()
+	pcm := constant(100*time.Millisecond, 1000)
+	if err := p.PlayStream(context.Background(), stream(pcm, 4800)); err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	if err := p.PlayStream(context.Background(), stream(pcm, 4800)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readOutput(t, out, path); len(got) != 2*len(pcm) {
+		t.Errorf("played %d bytes, want both streams", len(got))
+	}
+}
@@ -2,29 +2,258 @@ package audio
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/capture"
+)
+
+// Recorder defaults
+const (
+	defaultRecordPreroll   = time.Second      // audio kept from before Record was called
+	defaultMaxRecording    = 15 * time.Second // longest question recorded
+	defaultPartialInterval = time.Second      // how often RecordStream re-transcribes
+	minPartialAudio        = 300 * time.Millisecond
+	transcribeTimeout      = 30 * time.Second
 )
 
 // Recorder implements AudioRecorder using microphone capture and Whisper API
 type Recorder struct {
-	apiKey string
-	// TODO: Add audio capture device handle
+	transcriber     ai.Transcriber
+	bus             *capture.Bus
+	preroll         time.Duration
+	maxRecording    time.Duration
+	partialInterval time.Duration
 }
 
-// NewRecorder creates a new audio recorder
-func NewRecorder(apiKey string) ai.AudioRecorder {
+// NewRecorder creates a new audio recorder transcribing with the OpenAI
+// Whisper API
+func NewRecorder(apiKey string) *Recorder {
 	return &Recorder{
-		apiKey: apiKey,
+		transcriber:     NewWhisper(apiKey, ""),
+		preroll:         defaultRecordPreroll,
+		maxRecording:    defaultMaxRecording,
+		partialInterval: defaultPartialInterval,
 	}
 }
 
-// Record captures audio and returns transcribed text
+// SetCapture records from the shared capture bus. Recording starts preroll
+// before Record is called, so the words spoken while the VAD was still
+// deciding are not lost.
+func (r *Recorder) SetCapture(bus *capture.Bus, preroll time.Duration) {
+	r.bus = bus
+	r.preroll = preroll
+}
+
+// SetTranscriber replaces the speech-to-text backend, e.g. with a Whisper
+// client pointed at a local server
+func (r *Recorder) SetTranscriber(t ai.Transcriber) {
+	r.transcriber = t
+}
+
+// SetMaxRecording sets the longest recording Record makes
+func (r *Recorder) SetMaxRecording(d time.Duration) {
+	r.maxRecording = d
+}
+
+// SetPartialInterval sets how often RecordStream transcribes the audio
+// captured so far. Each partial is a full transcription request, so
+// shorter intervals cost more.
+func (r *Recorder) SetPartialInterval(d time.Duration) {
+	r.partialInterval = d
+}
+
+// Record captures audio until the capture context set with ai.WithCapture
+// is done and returns transcribed text. Cancelling ctx abandons the
+// recording, stopping a transcription in flight.
 func (r *Recorder) Record(ctx context.Context) (string, error) {
-	// TODO: Implement audio recording and STT
-	// - Capture audio from microphone
-	// - Detect silence/end of speech
-	// - Send to OpenAI Whisper API for transcription
-	// - Return transcribed text
-	return "", nil
+	t, err := r.RecordTranscription(ctx)
+	if err != nil || t == nil {
+		return "", err
+	}
+	return t.Text, nil
+}
+
+// RecordTranscription captures audio and returns the transcription with
+// segment timestamps. It returns nil without capture or when nothing was
+// recorded.
+func (r *Recorder) RecordTranscription(ctx context.Context) (*ai.Transcription, error) {
+	if r.bus == nil {
+		return nil, nil
+	}
+
+	pcm, err := r.capture(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.transcribe(ctx, pcm)
+}
+
+// RecordStream captures audio like Record and re-transcribes what has been
+// captured every partialInterval, sending each result as a partial
+// hypothesis, so a repeated text means recognition has settled. The final
+// hypothesis is the transcription of the whole recording.
+func (r *Recorder) RecordStream(ctx context.Context) <-chan ai.Hypothesis {
+	ch := make(chan ai.Hypothesis)
+	go func() {
+		defer close(ch)
+		if r.bus == nil {
+			ch <- ai.Hypothesis{Final: true}
+			return
+		}
+
+		src, closeSrc, err := r.open(ctx)
+		if err != nil {
+			ch <- ai.Hypothesis{Final: true, Err: err}
+			return
+		}
+		defer closeSrc()
+
+		pcm, err := r.streamPartials(ctx, src, ch)
+		if err != nil {
+			ch <- ai.Hypothesis{Final: true, Err: err}
+			return
+		}
+		t, err := r.transcribe(ctx, pcm)
+		final := ai.Hypothesis{Final: true, Err: err}
+		if t != nil {
+			final.Text = t.Text
+		}
+		ch <- final
+	}()
+	return ch
+}
+
+// streamPartials reads src to the end, transcribing periodically while it
+// does, and returns all audio read
+func (r *Recorder) streamPartials(ctx context.Context, src io.Reader, ch chan<- ai.Hypothesis) ([]byte, error) {
+	// Partials in flight when capture ends are abandoned for the final one
+	partialCtx, cancelPartial := context.WithCancel(ctx)
+	defer cancelPartial()
+
+	var mu sync.Mutex
+	var pcm []byte
+	captured := make(chan error, 1)
+	go func() {
+		defer cancelPartial()
+		buf := make([]byte, 4096)
+		for {
+			n, err := src.Read(buf)
+			mu.Lock()
+			pcm = append(pcm, buf[:n]...)
+			mu.Unlock()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				captured <- err
+				return
+			}
+		}
+	}()
+
+	rate := r.bus.SampleRate()
+	minBytes := int(minPartialAudio.Seconds()*float64(rate)) * 2
+	ticker := time.NewTicker(r.partialInterval)
+	defer ticker.Stop()
+
+	transcribed := 0
+	for {
+		select {
+		case err := <-captured:
+			// The reader has returned, pcm is complete
+			return pcm, err
+
+		case <-ticker.C:
+			// pcm only grows, so the snapshot is never written to
+			mu.Lock()
+			snapshot := pcm
+			mu.Unlock()
+			if len(snapshot) < minBytes || len(snapshot) == transcribed {
+				continue
+			}
+			transcribed = len(snapshot)
+
+			t, err := r.transcriber.Transcribe(partialCtx, snapshot, rate)
+			if err != nil {
+				if partialCtx.Err() == nil {
+					log.Printf("[Recorder] Partial transcription failed: %v", err)
+				}
+				continue
+			}
+			if t.Text == "" {
+				continue
+			}
+			select {
+			case ch <- ai.Hypothesis{Text: t.Text}:
+			case <-ctx.Done():
+			}
+		}
+	}
+}
+
+// capture reads PCM16 mono audio from the bus, starting preroll in the
+// past, until capture ends, the bus stops or maxRecording is reached
+func (r *Recorder) capture(ctx context.Context) ([]byte, error) {
+	src, closeSrc, err := r.open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSrc()
+
+	pcm, err := io.ReadAll(src)
+	if err != nil {
+		return nil, err
+	}
+	return pcm, nil
+}
+
+// open subscribes to the bus and returns a reader of the recording, which
+// ends when ctx or its capture context is done, the bus stops or
+// maxRecording is reached
+func (r *Recorder) open(ctx context.Context) (io.Reader, func(), error) {
+	sub, err := r.bus.Subscribe(r.preroll)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	capture, cancel := context.WithTimeout(ai.CaptureContext(ctx), r.maxRecording)
+	stopCapture := context.AfterFunc(capture, func() {
+		sub.Close()
+	})
+	stopAbandon := context.AfterFunc(ctx, func() {
+		sub.Close()
+	})
+	closeSrc := func() {
+		stopCapture()
+		stopAbandon()
+		cancel()
+		sub.Close()
+	}
+
+	limit := int64(r.preroll+r.maxRecording) * int64(r.bus.SampleRate()) / int64(time.Second) * 2
+	return io.LimitReader(sub, limit), closeSrc, nil
+}
+
+// transcribe converts a finished recording to text. It returns nil when
+// nothing was recorded.
+func (r *Recorder) transcribe(ctx context.Context, pcm []byte) (*ai.Transcription, error) {
+	if len(pcm) == 0 {
+		return nil, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, transcribeTimeout)
+	defer cancel()
+	t, err := r.transcriber.Transcribe(ctx, pcm, r.bus.SampleRate())
+	if err != nil {
+		return nil, fmt.Errorf("transcribe: %w", err)
+	}
+	return t, nil
 }
@@ -0,0 +1,169 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/capture"
+)
+
+func TestRecorder_CapturePreroll(t *testing.T) {
+	const rate = 16000
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	bus := capture.NewBus(capture.NewPipeSource(pr, rate))
+	go bus.Run(context.Background())
+
+	// VAD 触发前用户已经说了 1 秒
+	before := bytes.Repeat([]byte{1, 0}, rate)
+	pw.Write(before)
+	for bus.Position() < rate {
+		time.Sleep(time.Millisecond)
+	}
+
+	r := NewRecorder("")
+	r.SetCapture(bus, 500*time.Millisecond)
+	r.SetMaxRecording(100 * time.Millisecond)
+
+	go func() {
+		after := bytes.Repeat([]byte{2, 0}, rate/20)
+		pw.Write(after)
+	}()
+
+	pcm, err := r.capture(context.Background())
+	if err != nil {
+		t.Fatalf("capture failed: %v", err)
+	}
+
+	// 前 500ms 来自预录，后面是调用之后的音频
+	if len(pcm) < rate {
+		t.Fatalf("captured %d samples, want at least the %d of pre-roll", len(pcm)/2, rate/2)
+	}
+	if v := binary.LittleEndian.Uint16(pcm); v != 1 {
+		t.Errorf("first sample %d, want pre-roll audio", v)
+	}
+	if v := binary.LittleEndian.Uint16(pcm[rate:]); v != 2 {
+		t.Errorf("sample after pre-roll %d, want live audio", v)
+	}
+}
+
+func TestRecorder_NoCapture(t *testing.T) {
+	text, err := NewRecorder("").Record(context.Background())
+	if text != "" || err != nil {
+		t.Errorf("Record() = %q, %v without capture", text, err)
+	}
+}
+
+// lengthTranscriber 以收到的音频时长作为转写文本
+type lengthTranscriber struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (l *lengthTranscriber) Transcribe(ctx context.Context, pcm []byte, sampleRate int) (*ai.Transcription, error) {
+	l.mu.Lock()
+	l.calls++
+	l.mu.Unlock()
+	d := time.Duration(len(pcm)/2) * time.Second / time.Duration(sampleRate)
+	return &ai.Transcription{Text: d.Round(100 * time.Millisecond).String()}, nil
+}
+
+func TestRecorder_RecordStream(t *testing.T) {
+	const rate = 16000
+	pr, pw := io.Pipe()
+	bus := capture.NewBus(capture.NewPipeSource(pr, rate))
+	go bus.Run(context.Background())
+
+	lt := &lengthTranscriber{}
+	r := NewRecorder("")
+	r.SetTranscriber(lt)
+	r.SetCapture(bus, 0)
+	r.SetPartialInterval(50 * time.Millisecond)
+
+	hyps := r.RecordStream(context.Background())
+
+	// 以实时速度说 1 秒后结束采集
+	go func() {
+		frame := make([]byte, rate/50*2)
+		for range 50 {
+			pw.Write(frame)
+			time.Sleep(20 * time.Millisecond)
+		}
+		pw.Close()
+	}()
+
+	var got []ai.Hypothesis
+	for h := range hyps {
+		got = append(got, h)
+	}
+	if len(got) < 3 {
+		t.Fatalf("got %d hypotheses, want partials before the final", len(got))
+	}
+	final := got[len(got)-1]
+	if !final.Final || final.Err != nil || final.Text != "1s" {
+		t.Errorf("final hypothesis = %+v, want 1s of audio", final)
+	}
+	for _, h := range got[:len(got)-1] {
+		if h.Final {
+			t.Errorf("final hypothesis %+v before the end", h)
+		}
+	}
+}
+
+// blockingTranscriber 在 ctx 结束前一直转写
+type blockingTranscriber struct {
+	started chan struct{}
+}
+
+func (b blockingTranscriber) Transcribe(ctx context.Context, pcm []byte, sampleRate int) (*ai.Transcription, error) {
+	close(b.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRecorder_CancelDuringTranscription(t *testing.T) {
+	const rate = 16000
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	bus := capture.NewBus(capture.NewPipeSource(pr, rate))
+	go bus.Run(context.Background())
+	pw.Write(bytes.Repeat([]byte{1, 0}, rate/10))
+	for bus.Position() < rate/10 {
+		time.Sleep(time.Millisecond)
+	}
+
+	bt := blockingTranscriber{started: make(chan struct{})}
+	r := NewRecorder("")
+	r.SetTranscriber(bt)
+	r.SetCapture(bus, time.Second)
+
+	// 采集单独结束，转写开始后取消 ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	capture, stopCapture := context.WithCancel(ctx)
+	stopCapture()
+	go func() {
+		<-bt.started
+		cancel()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Record(ai.WithCapture(ctx, capture))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Record() error %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Record() kept transcribing after ctx was cancelled")
+	}
+}
@@ -0,0 +1,250 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+// Whisper defaults
+const (
+	defaultWhisperBaseURL  = "https://api.openai.com/v1"
+	defaultWhisperModel    = "whisper-1"
+	transcriptionsEndpoint = "/audio/transcriptions"
+)
+
+// Transcription errors, matched with errors.Is against an *APIError
+var (
+	ErrUnauthorized  = errors.New("stt: unauthorized")
+	ErrRateLimited   = errors.New("stt: rate limited")
+	ErrAudioTooLarge = errors.New("stt: audio too large")
+	ErrBadRequest    = errors.New("stt: bad request")
+	ErrServer        = errors.New("stt: server error")
+)
+
+// APIError is a non-200 response from the transcription endpoint
+type APIError struct {
+	StatusCode int
+	Message    string
+	Type       string        // error type reported by the server, if any
+	RetryAfter time.Duration // from the Retry-After header, zero if absent
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// Unwrap maps the status code to one of the Err* sentinels
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode == http.StatusRequestEntityTooLarge:
+		return ErrAudioTooLarge
+	case e.StatusCode >= 500:
+		return ErrServer
+	case e.StatusCode >= 400:
+		return ErrBadRequest
+	}
+	return nil
+}
+
+// Whisper implements Transcriber using an OpenAI-compatible
+// /audio/transcriptions endpoint. Local servers such as faster-whisper
+// based ones, LocalAI or the whisper.cpp server work by pointing baseURL
+// (and for whisper.cpp the endpoint) at them.
+type Whisper struct {
+	apiKey   string
+	baseURL  string
+	endpoint string
+	model    string
+	language string
+	prompt   string
+	client   *http.Client
+}
+
+// whisperResponse is the verbose_json response body. Servers that only
+// return {"text": ...} leave the other fields empty.
+type whisperResponse struct {
+	Text     string           `json:"text"`
+	Language string           `json:"language"`
+	Duration float64          `json:"duration"`
+	Segments []whisperSegment `json:"segments"`
+}
+
+type whisperSegment struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// whisperError is the OpenAI error body
+type whisperError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// NewWhisper creates a transcription client. An empty baseURL uses OpenAI.
+func NewWhisper(apiKey, baseURL string) *Whisper {
+	if baseURL == "" {
+		baseURL = defaultWhisperBaseURL
+	}
+	return &Whisper{
+		apiKey:   apiKey,
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		endpoint: transcriptionsEndpoint,
+		model:    defaultWhisperModel,
+		client:   &http.Client{},
+	}
+}
+
+// SetModel sets the transcription model
+func (w *Whisper) SetModel(model string) {
+	w.model = model
+}
+
+// SetEndpoint sets the path of the transcription endpoint under baseURL,
+// e.g. "/inference" for the whisper.cpp server
+func (w *Whisper) SetEndpoint(path string) {
+	w.endpoint = path
+}
+
+// SetLanguage sets the ISO-639-1 language of the speech, e.g. "zh".
+// Empty lets the server detect it.
+func (w *Whisper) SetLanguage(language string) {
+	w.language = language
+}
+
+// SetPrompt sets text that guides the transcription, such as the podcast
+// topic or the spelling of names
+func (w *Whisper) SetPrompt(prompt string) {
+	w.prompt = prompt
+}
+
+// Transcribe converts PCM16 mono audio at sampleRate to text
+func (w *Whisper) Transcribe(ctx context.Context, pcm []byte, sampleRate int) (*ai.Transcription, error) {
+	req, err := w.newRequest(ctx, pcm, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body)
+	}
+	return parseTranscription(body, resp.Header.Get("Content-Type"))
+}
+
+func (w *Whisper) newRequest(ctx context.Context, pcm []byte, sampleRate int) (*http.Request, error) {
+	audio, err := wav.Encode(wav.PCM16(sampleRate, 1), pcm)
+	if err != nil {
+		return nil, fmt.Errorf("encode wav: %w", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	file, err := mw.CreateFormFile("file", "audio.wav")
+	if err != nil {
+		return nil, fmt.Errorf("create form: %w", err)
+	}
+	file.Write(audio)
+
+	fields := [][2]string{
+		{"model", w.model},
+		{"response_format", "verbose_json"},
+		{"timestamp_granularities[]", "segment"},
+	}
+	if w.language != "" {
+		fields = append(fields, [2]string{"language", w.language})
+	}
+	if w.prompt != "" {
+		fields = append(fields, [2]string{"prompt", w.prompt})
+	}
+	for _, f := range fields {
+		mw.WriteField(f[0], f[1])
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("create form: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.baseURL+w.endpoint, &body)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if w.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.apiKey)
+	}
+	return req, nil
+}
+
+// parseTranscription reads a verbose_json, json or plain text response
+func parseTranscription(body []byte, contentType string) (*ai.Transcription, error) {
+	if !strings.HasPrefix(contentType, "application/json") && !json.Valid(body) {
+		return &ai.Transcription{Text: strings.TrimSpace(string(body))}, nil
+	}
+
+	var resp whisperResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	t := &ai.Transcription{
+		Text:     strings.TrimSpace(resp.Text),
+		Language: resp.Language,
+		Duration: seconds(resp.Duration),
+	}
+	for _, s := range resp.Segments {
+		t.Segments = append(t.Segments, ai.TranscriptSegment{
+			Text:  strings.TrimSpace(s.Text),
+			Start: seconds(s.Start),
+			End:   seconds(s.End),
+		})
+	}
+	return t, nil
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+
+	var we whisperError
+	if json.Unmarshal(body, &we) == nil && we.Error.Message != "" {
+		e.Message = we.Error.Message
+		e.Type = we.Error.Type
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+	return e
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
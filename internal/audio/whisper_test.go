@@ -0,0 +1,212 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/capture"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+const verboseJSON = `{
+	"task": "transcribe",
+	"language": "chinese",
+	"duration": 2.5,
+	"text": " 这个观点有什么依据？",
+	"segments": [
+		{"id": 0, "start": 0.0, "end": 1.2, "text": " 这个观点"},
+		{"id": 1, "start": 1.2, "end": 2.5, "text": "有什么依据？"}
+	]
+}`
+
+// whisperServer 模拟 /audio/transcriptions，记录收到的请求
+func whisperServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *http.Request) {
+	t.Helper()
+	var got http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/audio/transcriptions" {
+			t.Errorf("path %s", r.URL.Path)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse multipart: %v", err)
+		}
+		got = *r
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got
+}
+
+func TestWhisper_Transcribe(t *testing.T) {
+	srv, req := whisperServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, verboseJSON)
+	})
+
+	c := NewWhisper("test-key", srv.URL+"/v1/")
+	c.SetLanguage("zh")
+	c.SetPrompt("播客：人工智能")
+
+	pcm := bytes.Repeat([]byte{1, 0}, 1600)
+	tr, err := c.Transcribe(context.Background(), pcm, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h := req.Header.Get("Authorization"); h != "Bearer test-key" {
+		t.Errorf("Authorization = %q", h)
+	}
+	form := req.MultipartForm.Value
+	want := map[string]string{
+		"model":                     defaultWhisperModel,
+		"language":                  "zh",
+		"prompt":                    "播客：人工智能",
+		"response_format":           "verbose_json",
+		"timestamp_granularities[]": "segment",
+	}
+	for k, v := range want {
+		if len(form[k]) != 1 || form[k][0] != v {
+			t.Errorf("field %s = %v, want %q", k, form[k], v)
+		}
+	}
+
+	// 上传的文件是完整的 WAV
+	files := req.MultipartForm.File["file"]
+	if len(files) != 1 {
+		t.Fatalf("got %d files", len(files))
+	}
+	f, _ := files[0].Open()
+	data, _ := io.ReadAll(f)
+	decoded, err := wav.Decode(data)
+	if err != nil {
+		t.Fatalf("uploaded file is not WAV: %v", err)
+	}
+	if decoded.Format != wav.PCM16(16000, 1) || !bytes.Equal(decoded.Data, pcm) {
+		t.Errorf("uploaded %s with %d bytes", decoded.Format, len(decoded.Data))
+	}
+
+	if tr.Text != "这个观点有什么依据？" || tr.Language != "chinese" || tr.Duration != 2500*time.Millisecond {
+		t.Errorf("transcription = %+v", tr)
+	}
+	if len(tr.Segments) != 2 {
+		t.Fatalf("got %d segments", len(tr.Segments))
+	}
+	if s := tr.Segments[1]; s.Text != "有什么依据？" || s.Start != 1200*time.Millisecond || s.End != 2500*time.Millisecond {
+		t.Errorf("segment 1 = %+v", s)
+	}
+}
+
+func TestWhisper_LocalServer(t *testing.T) {
+	// 本地服务可能不支持 verbose_json，只返回文本
+	tests := []struct {
+		name, contentType, body string
+	}{
+		{"json", "application/json", `{"text": "hello there"}`},
+		{"text", "text/plain; charset=utf-8", "hello there\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, req := whisperServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			})
+			c := NewWhisper("", srv.URL+"/v1")
+			c.SetModel("base.en")
+
+			tr, err := c.Transcribe(context.Background(), make([]byte, 320), 16000)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tr.Text != "hello there" || len(tr.Segments) != 0 {
+				t.Errorf("transcription = %+v", tr)
+			}
+			if h := req.Header.Get("Authorization"); h != "" {
+				t.Errorf("Authorization sent without a key: %q", h)
+			}
+			if _, ok := req.MultipartForm.Value["language"]; ok {
+				t.Error("language sent when unset")
+			}
+		})
+	}
+}
+
+func TestWhisper_Errors(t *testing.T) {
+	tests := []struct {
+		status     int
+		body       string
+		retryAfter string
+		want       error
+		message    string
+	}{
+		{401, `{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error"}}`, "", ErrUnauthorized, "Incorrect API key provided"},
+		{429, `{"error": {"message": "Rate limit reached", "type": "requests"}}`, "20", ErrRateLimited, "Rate limit reached"},
+		{413, "", "", ErrAudioTooLarge, "Request Entity Too Large"},
+		{400, `{"error": {"message": "Invalid file format."}}`, "", ErrBadRequest, "Invalid file format."},
+		{503, "model is loading", "", ErrServer, "model is loading"},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv, _ := whisperServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+
+			_, err := NewWhisper("key", srv.URL+"/v1").Transcribe(context.Background(), make([]byte, 320), 16000)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error %v, want %v", err, tt.want)
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %T is not an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.message {
+				t.Errorf("APIError = %+v", apiErr)
+			}
+			if tt.retryAfter != "" && apiErr.RetryAfter != 20*time.Second {
+				t.Errorf("RetryAfter = %v", apiErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestRecorder_Transcribe(t *testing.T) {
+	srv, req := whisperServer(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, verboseJSON)
+	})
+
+	const rate = 16000
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	bus := capture.NewBus(capture.NewPipeSource(pr, rate))
+	go bus.Run(context.Background())
+	pw.Write(bytes.Repeat([]byte{1, 0}, rate/10))
+	for bus.Position() < rate/10 {
+		time.Sleep(time.Millisecond)
+	}
+
+	r := NewRecorder("")
+	r.SetTranscriber(NewWhisper("key", srv.URL+"/v1"))
+	r.SetCapture(bus, time.Second)
+	r.SetMaxRecording(50 * time.Millisecond)
+
+	// 录音在超时后结束，转写不受该超时影响
+	text, err := r.Record(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "这个观点有什么依据？" {
+		t.Errorf("Record() = %q", text)
+	}
+	if req.MultipartForm == nil {
+		t.Error("no request sent")
+	}
+}
@@ -0,0 +1,223 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+// WhisperCPP defaults
+const (
+	defaultWhisperCPPBinary = "whisper-cli"
+	autoLanguage            = "auto"
+	stderrTail              = 512 // bytes of stderr kept for error messages
+)
+
+// detectedLanguage matches whisper.cpp's "auto-detected language: en (p = 0.97)"
+var detectedLanguage = regexp.MustCompile(`auto-detected language: (\w+) \(p = ([0-9.]+)\)`)
+
+// WhisperCPP implements Transcriber by running a local whisper.cpp CLI, so
+// no audio leaves the machine. The audio is handed over in a temporary
+// WAV file and the result read from the JSON output (-oj).
+type WhisperCPP struct {
+	binary   string
+	model    string
+	language string
+	prompt   string
+	threads  int
+}
+
+// whisperCPPOutput is the JSON written by whisper.cpp with -oj
+type whisperCPPOutput struct {
+	Params struct {
+		Language string `json:"language"`
+	} `json:"params"`
+	Result struct {
+		Language string `json:"language"`
+	} `json:"result"`
+	Transcription []struct {
+		Offsets struct {
+			From int64 `json:"from"` // milliseconds
+			To   int64 `json:"to"`
+		} `json:"offsets"`
+		Text string `json:"text"`
+	} `json:"transcription"`
+}
+
+// NewWhisperCPP creates a transcriber running binary with the ggml model
+// at modelPath. An empty binary uses whisper-cli from PATH.
+func NewWhisperCPP(binary, modelPath string) *WhisperCPP {
+	if binary == "" {
+		binary = defaultWhisperCPPBinary
+	}
+	return &WhisperCPP{
+		binary:   binary,
+		model:    modelPath,
+		language: autoLanguage,
+	}
+}
+
+// NewLocalRecorder creates a recorder that transcribes offline with
+// whisper.cpp. A process still running when Record's ctx is done is
+// killed.
+func NewLocalRecorder(binary, modelPath string) *Recorder {
+	r := NewRecorder("")
+	r.SetTranscriber(NewWhisperCPP(binary, modelPath))
+	return r
+}
+
+// SetLanguage sets the spoken language, e.g. "zh". Empty or "auto" lets
+// whisper.cpp detect it.
+func (w *WhisperCPP) SetLanguage(language string) {
+	if language == "" {
+		language = autoLanguage
+	}
+	w.language = language
+}
+
+// SetPrompt sets text that guides the transcription
+func (w *WhisperCPP) SetPrompt(prompt string) {
+	w.prompt = prompt
+}
+
+// SetThreads sets the number of threads whisper.cpp uses, 0 for its default
+func (w *WhisperCPP) SetThreads(n int) {
+	w.threads = n
+}
+
+// Transcribe converts PCM16 mono audio at sampleRate to text. The process
+// is killed when ctx is done.
+func (w *WhisperCPP) Transcribe(ctx context.Context, pcm []byte, sampleRate int) (*ai.Transcription, error) {
+	dir, err := os.MkdirTemp("", "hma-whisper-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	input, err := writeWAV(dir, pcm, sampleRate)
+	if err != nil {
+		return nil, err
+	}
+	output := filepath.Join(dir, "out")
+
+	args := append(w.args(input), "-oj", "-of", output)
+	if _, err := w.run(ctx, args); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(output + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("read whisper.cpp output: %w", err)
+	}
+	t, err := parseWhisperCPP(data)
+	if err != nil {
+		return nil, err
+	}
+	t.Duration = time.Duration(len(pcm)/2) * time.Second / time.Duration(sampleRate)
+	return t, nil
+}
+
+// DetectLanguage returns the language whisper.cpp detects in the audio and
+// its probability, without transcribing it
+func (w *WhisperCPP) DetectLanguage(ctx context.Context, pcm []byte, sampleRate int) (string, float64, error) {
+	dir, err := os.MkdirTemp("", "hma-whisper-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	input, err := writeWAV(dir, pcm, sampleRate)
+	if err != nil {
+		return "", 0, err
+	}
+
+	args := []string{"-m", w.model, "-f", input, "-l", autoLanguage, "-dl"}
+	stderr, err := w.run(ctx, args)
+	if err != nil {
+		return "", 0, err
+	}
+	m := detectedLanguage.FindStringSubmatch(stderr)
+	if m == nil {
+		return "", 0, errors.New("whisper.cpp reported no language")
+	}
+	p, _ := strconv.ParseFloat(m[2], 64)
+	return m[1], p, nil
+}
+
+func (w *WhisperCPP) args(input string) []string {
+	args := []string{"-m", w.model, "-f", input, "-l", w.language}
+	if w.prompt != "" {
+		args = append(args, "--prompt", w.prompt)
+	}
+	if w.threads > 0 {
+		args = append(args, "-t", strconv.Itoa(w.threads))
+	}
+	return args
+}
+
+// run executes whisper.cpp and returns what it wrote to stderr
+func (w *WhisperCPP) run(ctx context.Context, args []string) (string, error) {
+	cmd := exec.CommandContext(ctx, w.binary, args...)
+	cmd.WaitDelay = time.Second
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		msg := stderr.String()
+		if len(msg) > stderrTail {
+			msg = msg[len(msg)-stderrTail:]
+		}
+		return "", fmt.Errorf("run %s: %w: %s", w.binary, err, strings.TrimSpace(msg))
+	}
+	return stderr.String(), nil
+}
+
+func writeWAV(dir string, pcm []byte, sampleRate int) (string, error) {
+	data, err := wav.Encode(wav.PCM16(sampleRate, 1), pcm)
+	if err != nil {
+		return "", fmt.Errorf("encode wav: %w", err)
+	}
+	path := filepath.Join(dir, "in.wav")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func parseWhisperCPP(data []byte) (*ai.Transcription, error) {
+	var out whisperCPPOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("decode whisper.cpp output: %w", err)
+	}
+
+	t := &ai.Transcription{Language: out.Result.Language}
+	if t.Language == "" && out.Params.Language != autoLanguage {
+		t.Language = out.Params.Language
+	}
+	var text strings.Builder
+	for _, s := range out.Transcription {
+		text.WriteString(s.Text)
+		t.Segments = append(t.Segments, ai.TranscriptSegment{
+			Text:  strings.TrimSpace(s.Text),
+			Start: time.Duration(s.Offsets.From) * time.Millisecond,
+			End:   time.Duration(s.Offsets.To) * time.Millisecond,
+		})
+	}
+	t.Text = strings.TrimSpace(text.String())
+	return t, nil
+}
@@ -0,0 +1,187 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/capture"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+// 设置 FAKE_WHISPER_CPP 时测试二进制自身充当 whisper.cpp
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("FAKE_WHISPER_CPP"); mode != "" {
+		os.Exit(fakeWhisperCPP(mode, os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+const fakeOutput = `{
+	"params": {"model": "ggml-base.bin", "language": "auto", "translate": false},
+	"result": {"language": "zh"},
+	"transcription": [
+		{"timestamps": {"from": "00:00:00,000", "to": "00:00:01,500"}, "offsets": {"from": 0, "to": 1500}, "text": " 这个观点"},
+		{"timestamps": {"from": "00:00:01,500", "to": "00:00:03,000"}, "offsets": {"from": 1500, "to": 3000}, "text": "有什么依据？"}
+	]
+}`
+
+// fakeWhisperCPP 模拟 whisper-cli 的参数和输出
+func fakeWhisperCPP(mode string, args []string) int {
+	flags := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		if strings.HasPrefix(args[i], "-") && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			flags[args[i]] = args[i+1]
+			i++
+		} else {
+			flags[args[i]] = ""
+		}
+	}
+	if path := os.Getenv("FAKE_WHISPER_ARGS"); path != "" {
+		os.WriteFile(path, []byte(strings.Join(args, "\n")), 0o644)
+	}
+
+	data, err := os.ReadFile(flags["-f"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: failed to open '%s'\n", flags["-f"])
+		return 2
+	}
+	if _, err := wav.Decode(data); err != nil {
+		fmt.Fprintln(os.Stderr, "error: failed to read WAV file")
+		return 2
+	}
+
+	switch mode {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "whisper_init_from_file_with_params_no_state: failed to open model")
+		return 3
+	case "hang":
+		time.Sleep(10 * time.Second)
+		return 0
+	}
+
+	if flags["-l"] == "auto" {
+		fmt.Fprintln(os.Stderr, "whisper_full_with_state: auto-detected language: zh (p = 0.934512)")
+	}
+	if _, ok := flags["-dl"]; ok {
+		return 0
+	}
+	if _, ok := flags["-oj"]; ok {
+		os.WriteFile(flags["-of"]+".json", []byte(fakeOutput), 0o644)
+	}
+	return 0
+}
+
+// fakeWhisperCPPBinary 让 WhisperCPP 运行测试二进制
+func fakeWhisperCPPBinary(t *testing.T, mode string) *WhisperCPP {
+	t.Helper()
+	t.Setenv("FAKE_WHISPER_CPP", mode)
+	return NewWhisperCPP(os.Args[0], "ggml-base.bin")
+}
+
+func TestWhisperCPP_Transcribe(t *testing.T) {
+	w := fakeWhisperCPPBinary(t, "ok")
+	argsPath := t.TempDir() + "/args"
+	t.Setenv("FAKE_WHISPER_ARGS", argsPath)
+	w.SetThreads(4)
+	w.SetPrompt("播客")
+
+	pcm := bytes.Repeat([]byte{1, 0}, 3*16000)
+	tr, err := w.Transcribe(context.Background(), pcm, 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tr.Text != "这个观点有什么依据？" || tr.Language != "zh" || tr.Duration != 3*time.Second {
+		t.Errorf("transcription = %+v", tr)
+	}
+	if len(tr.Segments) != 2 || tr.Segments[1].Start != 1500*time.Millisecond || tr.Segments[1].End != 3*time.Second {
+		t.Errorf("segments = %+v", tr.Segments)
+	}
+
+	args, _ := os.ReadFile(argsPath)
+	for _, want := range []string{"-m\nggml-base.bin", "-l\nauto", "--prompt\n播客", "-t\n4", "-oj"} {
+		if !strings.Contains(string(args), want) {
+			t.Errorf("args missing %q:\n%s", want, args)
+		}
+	}
+}
+
+func TestWhisperCPP_Language(t *testing.T) {
+	w := fakeWhisperCPPBinary(t, "ok")
+	lang, p, err := w.DetectLanguage(context.Background(), make([]byte, 3200), 16000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lang != "zh" || p < 0.93 || p > 0.94 {
+		t.Errorf("DetectLanguage() = %q, %v", lang, p)
+	}
+
+	// DetectLanguage 总是自动检测，不受配置的语言影响
+	w.SetLanguage("en")
+	if _, _, err := w.DetectLanguage(context.Background(), make([]byte, 3200), 16000); err != nil {
+		t.Errorf("DetectLanguage ignores the configured language: %v", err)
+	}
+}
+
+func TestWhisperCPP_Errors(t *testing.T) {
+	w := fakeWhisperCPPBinary(t, "fail")
+	_, err := w.Transcribe(context.Background(), make([]byte, 3200), 16000)
+	if err == nil || !strings.Contains(err.Error(), "failed to open model") {
+		t.Errorf("error %v, want stderr in the message", err)
+	}
+
+	missing := NewWhisperCPP(t.TempDir()+"/whisper-cli", "model.bin")
+	if _, err := missing.Transcribe(context.Background(), make([]byte, 3200), 16000); err == nil {
+		t.Error("missing binary accepted")
+	}
+}
+
+func TestWhisperCPP_Cancel(t *testing.T) {
+	w := fakeWhisperCPPBinary(t, "hang")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := w.Transcribe(ctx, make([]byte, 3200), 16000)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("process not killed, took %v", elapsed)
+	}
+}
+
+func TestLocalRecorder_Cancel(t *testing.T) {
+	t.Setenv("FAKE_WHISPER_CPP", "hang")
+	const rate = 16000
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	bus := capture.NewBus(capture.NewPipeSource(pr, rate))
+	go bus.Run(context.Background())
+	pw.Write(bytes.Repeat([]byte{1, 0}, rate/10))
+	for bus.Position() < rate/10 {
+		time.Sleep(time.Millisecond)
+	}
+
+	r := NewLocalRecorder(os.Args[0], "ggml-base.bin")
+	r.SetCapture(bus, time.Second)
+	r.SetMaxRecording(50 * time.Millisecond)
+
+	// 采集在 50ms 后结束，whisper.cpp 运行中 ctx 到期
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := r.Record(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("process not killed, took %v", elapsed)
+	}
+}
@@ -0,0 +1,299 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Bus defaults
+const (
+	frameTime        = 20 * time.Millisecond
+	defaultPreroll   = 1500 * time.Millisecond
+	maxSubscriberLag = 10 * time.Second // a subscriber further behind loses its oldest audio
+)
+
+// ErrClosed is returned by Subscribe after the bus has stopped
+var ErrClosed = errors.New("capture: bus closed")
+
+// Bus reads a Source once and fans the audio out to any number of
+// subscribers. It keeps the most recent audio in a ring buffer, so a
+// subscriber that joins late, like the recorder after the VAD fired, can
+// start from before the moment it subscribed.
+type Bus struct {
+	source     Source
+	sampleRate int
+	frameBytes int
+
+	mu       sync.Mutex
+	ring     []byte // the most recent audio, oldest first once full
+	ringPos  int
+	ringFull bool
+	position int64 // samples captured so far
+	subs     map[*Subscription]struct{}
+	closed   bool
+	done     chan struct{}
+}
+
+// NewBus creates a bus for source keeping defaultPreroll of audio
+func NewBus(source Source) *Bus {
+	b := &Bus{
+		source:     source,
+		sampleRate: source.SampleRate(),
+		frameBytes: int(frameTime.Seconds()*float64(source.SampleRate())) * 2,
+		subs:       make(map[*Subscription]struct{}),
+		done:       make(chan struct{}),
+	}
+	b.SetPreroll(defaultPreroll)
+	return b
+}
+
+// SetPreroll sets how much past audio the bus keeps for new subscribers.
+// It must be called before Run.
+func (b *Bus) SetPreroll(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ring = make([]byte, int(d.Seconds()*float64(b.sampleRate))*2)
+	b.ringPos = 0
+	b.ringFull = false
+}
+
+// SampleRate returns the sample rate of the captured audio
+func (b *Bus) SampleRate() int {
+	return b.sampleRate
+}
+
+// Position returns the number of samples captured so far
+func (b *Bus) Position() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.position
+}
+
+// Run captures until ctx is done or the source ends, then closes the
+// source and every subscription. It returns the error that ended the
+// capture, nil for a cancelled context or the end of the source.
+func (b *Bus) Run(ctx context.Context) error {
+	// Reads block, so closing the source is what interrupts them
+	stop := context.AfterFunc(ctx, func() {
+		b.source.Close()
+	})
+	defer stop()
+
+	var err error
+	buf := make([]byte, b.frameBytes)
+	for {
+		var n int
+		n, err = io.ReadFull(b.source, buf)
+		n &^= 1
+		if n > 0 {
+			b.publish(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || ctx.Err() != nil {
+		err = nil
+	} else {
+		log.Printf("[Capture] Capture stopped: %v", err)
+	}
+	b.source.Close()
+	b.close(err)
+	return err
+}
+
+// Done is closed when the bus has stopped
+func (b *Bus) Done() <-chan struct{} {
+	return b.done
+}
+
+// Subscribe returns a reader that receives all audio captured from now on,
+// preceded by up to preroll of audio captured before
+func (b *Bus) Subscribe(preroll time.Duration) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	s := &Subscription{
+		bus:    b,
+		notify: make(chan struct{}, 1),
+		limit:  int(maxSubscriberLag.Seconds()*float64(b.sampleRate)) * 2,
+	}
+
+	past := b.recent()
+	if want := int(preroll.Seconds()*float64(b.sampleRate)) * 2; want < len(past) {
+		past = past[len(past)-want:]
+	}
+	s.start = b.position - int64(len(past)/2)
+	if len(past) > 0 {
+		s.queue = append(s.queue, past)
+		s.queued = len(past)
+	}
+
+	b.subs[s] = struct{}{}
+	return s, nil
+}
+
+// recent returns a copy of the ring buffer contents, oldest first
+func (b *Bus) recent() []byte {
+	if !b.ringFull {
+		return append([]byte(nil), b.ring[:b.ringPos]...)
+	}
+	out := make([]byte, 0, len(b.ring))
+	out = append(out, b.ring[b.ringPos:]...)
+	return append(out, b.ring[:b.ringPos]...)
+}
+
+func (b *Bus) publish(frame []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.position += int64(len(frame) / 2)
+
+	if len(b.ring) > 0 {
+		for data := frame; len(data) > 0; {
+			n := copy(b.ring[b.ringPos:], data)
+			data = data[n:]
+			b.ringPos += n
+			if b.ringPos == len(b.ring) {
+				b.ringPos = 0
+				b.ringFull = true
+			}
+		}
+	}
+
+	for s := range b.subs {
+		s.push(append([]byte(nil), frame...))
+	}
+}
+
+func (b *Bus) close(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for s := range b.subs {
+		s.finish(err)
+	}
+	b.subs = nil
+	close(b.done)
+}
+
+func (b *Bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, s)
+}
+
+// Subscription is one consumer's view of the bus. It is an io.Reader of
+// PCM16 mono audio, so it can be handed to a VAD or the recorder.
+type Subscription struct {
+	bus    *Bus
+	start  int64 // bus position of the first sample delivered
+	limit  int   // queued bytes kept before the oldest are dropped
+	notify chan struct{}
+
+	mu      sync.Mutex
+	queue   [][]byte
+	queued  int
+	dropped int64 // samples lost because the subscriber fell behind
+	err     error
+	done    bool
+}
+
+// Start returns the bus position, in samples, of the first sample this
+// subscription delivers
+func (s *Subscription) Start() int64 {
+	return s.start
+}
+
+// Dropped returns how many samples were lost because the reader fell
+// too far behind
+func (s *Subscription) Dropped() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Read reads captured audio, blocking until some is available. It returns
+// io.EOF once the bus stops or the subscription is closed.
+func (s *Subscription) Read(p []byte) (int, error) {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			n := 0
+			for n < len(p) && len(s.queue) > 0 {
+				c := copy(p[n:], s.queue[0])
+				n += c
+				if c == len(s.queue[0]) {
+					s.queue = s.queue[1:]
+				} else {
+					s.queue[0] = s.queue[0][c:]
+				}
+			}
+			s.queued -= n
+			s.mu.Unlock()
+			return n, nil
+		}
+		if s.done {
+			err := s.err
+			s.mu.Unlock()
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		s.mu.Unlock()
+		<-s.notify
+	}
+}
+
+// Close stops delivering audio to this subscription
+func (s *Subscription) Close() error {
+	s.bus.unsubscribe(s)
+	s.finish(nil)
+	return nil
+}
+
+func (s *Subscription) push(frame []byte) {
+	s.mu.Lock()
+	if s.done {
+		s.mu.Unlock()
+		return
+	}
+	s.queue = append(s.queue, frame)
+	s.queued += len(frame)
+	for s.queued > s.limit && len(s.queue) > 1 {
+		s.queued -= len(s.queue[0])
+		s.dropped += int64(len(s.queue[0]) / 2)
+		s.queue = s.queue[1:]
+	}
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *Subscription) finish(err error) {
+	s.mu.Lock()
+	if !s.done {
+		s.done = true
+		s.err = err
+	}
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *Subscription) wake() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,238 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+const testRate = 16000
+
+// ramp 生成样本值依次递增的 PCM，便于检查顺序和位置
+func ramp(start, n int) []byte {
+	out := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(start+i))
+	}
+	return out
+}
+
+func firstSample(b []byte) int {
+	return int(binary.LittleEndian.Uint16(b))
+}
+
+// waitPosition 等待总线读到指定位置
+func waitPosition(t *testing.T, b *Bus, pos int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for b.Position() < pos {
+		if time.Now().After(deadline) {
+			t.Fatalf("bus stuck at %d, want %d", b.Position(), pos)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBus_FanOut(t *testing.T) {
+	pr, pw := io.Pipe()
+	bus := NewBus(NewPipeSource(pr, testRate))
+
+	a, _ := bus.Subscribe(0)
+	b, _ := bus.Subscribe(0)
+	go bus.Run(context.Background())
+
+	data := ramp(0, testRate)
+	go func() {
+		pw.Write(data)
+		pw.Close()
+	}()
+
+	for name, sub := range map[string]*Subscription{"a": a, "b": b} {
+		got, err := io.ReadAll(sub)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s received %d bytes, want the %d bytes captured", name, len(got), len(data))
+		}
+	}
+	<-bus.Done()
+	if _, err := bus.Subscribe(0); err != ErrClosed {
+		t.Errorf("Subscribe after close = %v, want ErrClosed", err)
+	}
+}
+
+func TestBus_Preroll(t *testing.T) {
+	pr, pw := io.Pipe()
+	bus := NewBus(NewPipeSource(pr, testRate))
+	go bus.Run(context.Background())
+	defer pw.Close()
+
+	// 先采集 1 秒，再订阅并要求 500ms 的预录
+	pw.Write(ramp(0, testRate))
+	waitPosition(t, bus, testRate)
+
+	sub, err := bus.Subscribe(500 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Start() != testRate/2 {
+		t.Errorf("Start() = %d, want %d", sub.Start(), testRate/2)
+	}
+
+	go pw.Write(ramp(testRate, testRate/2))
+
+	buf := make([]byte, testRate*2)
+	if _, err := io.ReadFull(sub, buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := firstSample(buf); got != testRate/2 {
+		t.Errorf("first sample %d, want %d", got, testRate/2)
+	}
+	if !bytes.Equal(buf, ramp(testRate/2, testRate)) {
+		t.Error("pre-roll and live audio are not contiguous")
+	}
+}
+
+func TestBus_PrerollLimitedByRing(t *testing.T) {
+	pr, pw := io.Pipe()
+	bus := NewBus(NewPipeSource(pr, testRate))
+	bus.SetPreroll(time.Second)
+	go bus.Run(context.Background())
+	defer pw.Close()
+
+	pw.Write(ramp(0, 3*testRate))
+	waitPosition(t, bus, 3*testRate)
+
+	// 环形缓冲只保留最近 1 秒
+	sub, _ := bus.Subscribe(5 * time.Second)
+	if sub.Start() != 2*testRate {
+		t.Errorf("Start() = %d, want %d", sub.Start(), 2*testRate)
+	}
+	buf := make([]byte, 2)
+	io.ReadFull(sub, buf)
+	if got := firstSample(buf); got != 2*testRate {
+		t.Errorf("first sample %d, want %d", got, 2*testRate)
+	}
+}
+
+func TestBus_SlowSubscriber(t *testing.T) {
+	seconds := int(maxSubscriberLag/time.Second) + 2
+	data := ramp(0, seconds*testRate)
+	bus := NewBus(NewPipeSource(bytes.NewReader(data), testRate))
+	sub, _ := bus.Subscribe(0)
+
+	// 订阅者一直不读，最旧的音频被丢弃
+	bus.Run(context.Background())
+
+	got, _ := io.ReadAll(sub)
+	if sub.Dropped() == 0 {
+		t.Fatal("no audio dropped for a stalled subscriber")
+	}
+	if int64(len(got)/2)+sub.Dropped() != int64(seconds*testRate) {
+		t.Errorf("received %d + dropped %d samples, want %d", len(got)/2, sub.Dropped(), seconds*testRate)
+	}
+	if !bytes.Equal(got, data[len(data)-len(got):]) {
+		t.Error("kept audio is not the most recent")
+	}
+}
+
+func TestBus_Cancel(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	bus := NewBus(NewPipeSource(pr, testRate))
+	sub, _ := bus.Subscribe(0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- bus.Run(ctx) }()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run() = %v, want nil after cancel", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop on cancel")
+	}
+	if _, err := sub.Read(make([]byte, 10)); err != io.EOF {
+		t.Errorf("Read after stop = %v, want io.EOF", err)
+	}
+}
+
+func TestSubscription_Close(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	bus := NewBus(NewPipeSource(pr, testRate))
+	go bus.Run(context.Background())
+	sub, _ := bus.Subscribe(0)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		sub.Close()
+	}()
+	if _, err := sub.Read(make([]byte, 10)); err != io.EOF {
+		t.Errorf("Read after Close = %v, want io.EOF", err)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	pcm := ramp(0, testRate/10)
+
+	mono, _ := wav.Encode(wav.PCM16(testRate, 1), pcm)
+	path := filepath.Join(dir, "mono.wav")
+	os.WriteFile(path, mono, 0o644)
+
+	src, err := NewFileSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.SetRealtime(true)
+	if src.SampleRate() != testRate {
+		t.Errorf("SampleRate() = %d, want %d", src.SampleRate(), testRate)
+	}
+
+	start := time.Now()
+	got, _ := io.ReadAll(src)
+	if !bytes.Equal(got, pcm) {
+		t.Error("file source returned different audio")
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("realtime read of 100ms took %v", elapsed)
+	}
+
+	stereo, _ := wav.Encode(wav.PCM16(testRate, 2), pcm)
+	path = filepath.Join(dir, "stereo.wav")
+	os.WriteFile(path, stereo, 0o644)
+	if _, err := NewFileSource(path); err == nil {
+		t.Error("stereo file accepted")
+	}
+}
+
+func TestCommandSource(t *testing.T) {
+	pcm := ramp(0, testRate/10)
+	path := filepath.Join(t.TempDir(), "audio.raw")
+	os.WriteFile(path, pcm, 0o644)
+
+	src, err := NewCommandSource(testRate, "cat", path)
+	if err != nil {
+		t.Skipf("cat not available: %v", err)
+	}
+	bus := NewBus(src)
+	sub, _ := bus.Subscribe(0)
+	go bus.Run(context.Background())
+
+	got, _ := io.ReadAll(sub)
+	if !bytes.Equal(got, pcm) {
+		t.Errorf("command source returned %d bytes, want %d", len(got), len(pcm))
+	}
+}
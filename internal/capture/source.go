@@ -0,0 +1,188 @@
+// Package capture reads microphone audio once and shares it between the
+// VAD, the recorder and anything else that listens.
+package capture
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+// Source produces PCM16 little-endian mono audio
+type Source interface {
+	io.ReadCloser
+
+	// SampleRate returns the sample rate of the audio
+	SampleRate() int
+}
+
+// ErrNoDevice is returned when no capture program is installed
+var ErrNoDevice = errors.New("capture: no recording program found (arecord, parec or rec)")
+
+// PipeSource reads raw PCM from a reader, e.g. stdin or the output of
+// another program
+type PipeSource struct {
+	r          io.Reader
+	sampleRate int
+}
+
+// NewPipeSource creates a source reading raw PCM16 mono audio at
+// sampleRate from r
+func NewPipeSource(r io.Reader, sampleRate int) *PipeSource {
+	return &PipeSource{r: r, sampleRate: sampleRate}
+}
+
+func (s *PipeSource) Read(p []byte) (int, error) {
+	return s.r.Read(p)
+}
+
+// Close closes the underlying reader if it is closable
+func (s *PipeSource) Close() error {
+	if c, ok := s.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// SampleRate returns the sample rate of the audio
+func (s *PipeSource) SampleRate() int {
+	return s.sampleRate
+}
+
+// FileSource plays back a WAV file as if it were being captured
+type FileSource struct {
+	data       *bytes.Reader
+	sampleRate int
+	realtime   bool
+	started    time.Time
+	read       int64
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewFileSource opens a PCM16 mono WAV file
+func NewFileSource(path string) (*FileSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := wav.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if f.Format.AudioFormat != wav.FormatPCM || f.Format.BitsPerSample != 16 || f.Format.Channels != 1 {
+		return nil, fmt.Errorf("%s is %s, want 16-bit mono PCM", path, f.Format)
+	}
+
+	return &FileSource{
+		data:       bytes.NewReader(f.Data),
+		sampleRate: int(f.Format.SampleRate),
+	}, nil
+}
+
+// SetRealtime paces reads to the sample rate instead of returning the
+// whole file as fast as it is read
+func (s *FileSource) SetRealtime(realtime bool) {
+	s.realtime = realtime
+}
+
+func (s *FileSource) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return 0, io.EOF
+	}
+
+	n, err := s.data.Read(p)
+	if s.realtime && n > 0 {
+		if s.started.IsZero() {
+			s.started = time.Now()
+		}
+		s.read += int64(n)
+		due := time.Duration(s.read/2) * time.Second / time.Duration(s.sampleRate)
+		time.Sleep(time.Until(s.started.Add(due)))
+	}
+	return n, err
+}
+
+// Close makes further reads return io.EOF
+func (s *FileSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+// SampleRate returns the sample rate of the file
+func (s *FileSource) SampleRate() int {
+	return s.sampleRate
+}
+
+// CommandSource captures audio through an external program writing raw
+// PCM16 mono to stdout
+type CommandSource struct {
+	cmd        *exec.Cmd
+	stdout     io.ReadCloser
+	sampleRate int
+	once       sync.Once
+}
+
+// NewCommandSource starts name with args and reads its output
+func NewCommandSource(sampleRate int, name string, args ...string) (*CommandSource, error) {
+	cmd := exec.Command(name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", name, err)
+	}
+	return &CommandSource{cmd: cmd, stdout: stdout, sampleRate: sampleRate}, nil
+}
+
+// NewDeviceSource captures from the default microphone using the first
+// recording program found: arecord (ALSA), parec (PulseAudio/PipeWire) or
+// rec (SoX)
+func NewDeviceSource(sampleRate int) (*CommandSource, error) {
+	rate := strconv.Itoa(sampleRate)
+	candidates := [][]string{
+		{"arecord", "-q", "-t", "raw", "-f", "S16_LE", "-c", "1", "-r", rate},
+		{"parec", "--raw", "--format=s16le", "--channels=1", "--rate=" + rate},
+		{"rec", "-q", "-t", "raw", "-b", "16", "-e", "signed", "-c", "1", "-r", rate, "-"},
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return NewCommandSource(sampleRate, c[0], c[1:]...)
+		}
+	}
+	return nil, ErrNoDevice
+}
+
+func (s *CommandSource) Read(p []byte) (int, error) {
+	return s.stdout.Read(p)
+}
+
+// Close stops the capture program
+func (s *CommandSource) Close() error {
+	s.once.Do(func() {
+		s.cmd.Process.Kill()
+		s.stdout.Close()
+		s.cmd.Wait()
+	})
+	return nil
+}
+
+// SampleRate returns the sample rate of the captured audio
+func (s *CommandSource) SampleRate() int {
+	return s.sampleRate
+}
@@ -0,0 +1,213 @@
+// Package command recognizes spoken playback controls such as "pause",
+// "louder" or "下一章", so they take effect without a round trip to the
+// LLM.
+package command
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Action is a playback control
+type Action int
+
+const (
+	Pause   Action = iota + 1 // stop until told to resume
+	Resume                    // continue after a pause
+	Skip                      // drop the rest of the current segment
+	Repeat                    // play the current segment again
+	Slower                    // lower the speech speed
+	Faster                    // raise the speech speed
+	Louder                    // raise the volume
+	Quieter                   // lower the volume
+	Stop                      // end the session
+)
+
+func (a Action) String() string {
+	switch a {
+	case Pause:
+		return "Pause"
+	case Resume:
+		return "Resume"
+	case Skip:
+		return "Skip"
+	case Repeat:
+		return "Repeat"
+	case Slower:
+		return "Slower"
+	case Faster:
+		return "Faster"
+	case Louder:
+		return "Louder"
+	case Quieter:
+		return "Quieter"
+	case Stop:
+		return "Stop"
+	default:
+		return "Unknown"
+	}
+}
+
+// Command is a recognized control and the phrase it matched
+type Command struct {
+	Action Action
+	Phrase string
+}
+
+// Grammar settings
+const (
+	phraseTolerance = 0.25 // edits per character still matching a phrase
+	minFuzzyLength  = 4    // shorter phrases must match exactly
+)
+
+// defaultPhrases are the built-in English and Chinese phrases
+var defaultPhrases = map[Action][]string{
+	Pause:   {"pause", "hold on", "wait", "暂停", "等一下", "等等", "先停一下"},
+	Resume:  {"resume", "continue", "go on", "play", "继续", "继续播放", "接着说"},
+	Skip:    {"skip", "next", "skip this", "next chapter", "跳过", "下一个", "下一章", "下一段"},
+	Repeat:  {"repeat", "repeat that", "say that again", "again", "重复", "再说一遍", "再来一遍", "重复一下"},
+	Slower:  {"slower", "slow down", "too fast", "慢点", "慢一点", "说慢点", "太快了"},
+	Faster:  {"faster", "speed up", "too slow", "快点", "快一点", "说快点", "太慢了"},
+	Louder:  {"louder", "volume up", "turn it up", "大声点", "大声一点", "声音大一点", "听不清"},
+	Quieter: {"quieter", "softer", "volume down", "turn it down", "小声点", "小声一点", "声音小一点", "太吵了"},
+	Stop:    {"stop", "stop playing", "that's enough", "停止", "停止播放", "别说了", "关掉"},
+}
+
+// politeness around a command that does not change it
+var (
+	leadingFillers  = []string{"please", "can you", "could you", "can we", "okay", "ok", "请", "麻烦", "你"}
+	trailingFillers = []string{"please", "for me", "一下", "吧", "呀", "啊", "了"}
+)
+
+// Grammar matches utterances against command phrases. The whole
+// utterance, apart from polite words, must be the phrase, so "why did
+// they stop" is a question rather than Stop; small recognition errors
+// are tolerated.
+type Grammar struct {
+	phrases []phrase
+}
+
+type phrase struct {
+	action Action
+	text   string
+	key    []rune
+}
+
+// NewGrammar creates a grammar with the built-in phrases
+func NewGrammar() *Grammar {
+	g := &Grammar{}
+	for a := Pause; a <= Stop; a++ {
+		g.Add(a, defaultPhrases[a]...)
+	}
+	return g
+}
+
+// Add adds phrases for action
+func (g *Grammar) Add(action Action, phrases ...string) {
+	for _, p := range phrases {
+		if key := normalize(strip(p)); len(key) > 0 {
+			g.phrases = append(g.phrases, phrase{action: action, text: p, key: key})
+		}
+	}
+}
+
+// Match returns the command text says, if any. The closest phrase wins.
+func (g *Grammar) Match(text string) (Command, bool) {
+	key := normalize(strip(text))
+	if len(key) == 0 {
+		return Command{}, false
+	}
+
+	best, bestDistance := -1, 0
+	for i, p := range g.phrases {
+		allowed := 0
+		if len(p.key) >= minFuzzyLength {
+			allowed = int(float64(len(p.key)) * phraseTolerance)
+		}
+		d := editDistance(key, p.key)
+		if d <= allowed && (best < 0 || d < bestDistance) {
+			best, bestDistance = i, d
+		}
+	}
+	if best < 0 {
+		return Command{}, false
+	}
+	return Command{Action: g.phrases[best].action, Phrase: g.phrases[best].text}, true
+}
+
+// strip removes polite words from both ends of text
+func strip(text string) string {
+	text = strings.ToLower(strings.TrimSpace(text))
+	trim := func(s string) string {
+		return strings.TrimFunc(s, func(r rune) bool { return unicode.IsPunct(r) || unicode.IsSpace(r) })
+	}
+	for changed := true; changed; {
+		changed = false
+		text = trim(text)
+		for _, f := range leadingFillers {
+			if rest, ok := strings.CutPrefix(text, f); ok && rest != "" && !startsWord(rest, f) {
+				text, changed = rest, true
+			}
+		}
+		for _, f := range trailingFillers {
+			if rest, ok := strings.CutSuffix(text, f); ok && trim(rest) != "" && !endsWord(rest, f) {
+				text, changed = rest, true
+			}
+		}
+	}
+	return text
+}
+
+// startsWord reports whether cutting an English filler off the front of
+// s split a word, as "ok" would in "okay"
+func startsWord(s, filler string) bool {
+	r := []rune(s)[0]
+	return isLatin(filler) && unicode.IsLetter(r) && r < unicode.MaxASCII
+}
+
+// endsWord is startsWord for the end of s
+func endsWord(s, filler string) bool {
+	r := []rune(s)
+	last := r[len(r)-1]
+	return isLatin(filler) && unicode.IsLetter(last) && last < unicode.MaxASCII
+}
+
+func isLatin(s string) bool {
+	for _, r := range s {
+		if r >= unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize keeps only lowercased letters and digits
+func normalize(text string) []rune {
+	var out []rune
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			out = append(out, unicode.ToLower(r))
+		}
+	}
+	return out
+}
+
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j-1]+cost, prev[j]+1, cur[j-1]+1)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
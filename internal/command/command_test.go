@@ -0,0 +1,53 @@
+package command
+
+import "testing"
+
+func TestGrammar_Match(t *testing.T) {
+	tests := []struct {
+		text string
+		want Action // 0 表示不是命令
+	}{
+		{"pause", Pause},
+		{"Pause.", Pause},
+		{"Please pause", Pause},
+		{"could you repeat that please?", Repeat},
+		{"暂停", Pause},
+		{"下一章", Skip},
+		{"下一章吧", Skip},
+		{"请说慢点", Slower},
+		{"大声一点", Louder},
+		{"别说了", Stop},
+		{"继续", Resume},
+		{"再说一次", Repeat}, // 识别误差
+		{"slover", Slower},
+		{"louder please", Louder},
+		{"Okay, stop", Stop},
+		{"why did they stop", 0},
+		{"Wait, is that true?", 0},
+		{"okay", 0},
+		{"please", 0},
+		{"停一停再说吧，我有个问题", 0},
+		{"", 0},
+	}
+	g := NewGrammar()
+	for _, tt := range tests {
+		cmd, ok := g.Match(tt.text)
+		if tt.want == 0 {
+			if ok {
+				t.Errorf("Match(%q) = %s (%q), want no command", tt.text, cmd.Action, cmd.Phrase)
+			}
+			continue
+		}
+		if !ok || cmd.Action != tt.want {
+			t.Errorf("Match(%q) = %s, %v, want %s", tt.text, cmd.Action, ok, tt.want)
+		}
+	}
+}
+
+func TestGrammar_Add(t *testing.T) {
+	g := NewGrammar()
+	g.Add(Skip, "next topic", "换个话题")
+	if cmd, ok := g.Match("换个话题吧"); !ok || cmd.Action != Skip || cmd.Phrase != "换个话题" {
+		t.Errorf("Match = %+v, %v, want the added phrase", cmd, ok)
+	}
+}
@@ -0,0 +1,211 @@
+package echo
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// Canceller defaults
+const (
+	defaultTailLength  = 128 * time.Millisecond // longest echo path the filter models
+	defaultStepSize    = 0.4
+	defaultSuppression = -20.0 // dB applied to the residual while only the host talks
+
+	geigelThreshold   = 0.5                    // near-end louder than half the far-end peak means double talk
+	doubleTalkHold    = 60 * time.Millisecond  // keep adaptation frozen a little longer
+	farActiveLevel    = 1e-3                   // far-end peak above which the host is considered talking
+	peakDecayTime     = 100 * time.Millisecond // far-end peak decay
+	suppressionTime   = 10 * time.Millisecond  // suppression gain smoothing
+	erleAveragingTime = 500 * time.Millisecond
+	regularization    = 1e-6
+)
+
+// Canceller is an io.Reader that removes the echo of the reference from a
+// PCM16 little-endian mono capture source. An NLMS adaptive filter models
+// the path from the speakers to the microphone; adaptation pauses during
+// double talk so the user's voice is not cancelled, and the residual is
+// attenuated while only the host is talking.
+type Canceller struct {
+	source io.Reader
+	ref    *Reference
+	step   float64
+
+	taps    int
+	weights []float64
+	history []float64 // reference history, stored twice for contiguous access
+	pos     int
+	power   float64 // reference power over the filter length
+
+	farPeak    float64
+	peakDecay  float64
+	hold       int // samples left of double talk hold
+	holdLength int
+	suppress   float64 // linear gain for echo-only residual, 1 disables
+	gain       float64
+	gainCoef   float64
+
+	mu       sync.Mutex
+	micPower float64
+	errPower float64
+	erleCoef float64
+
+	buf   []byte
+	carry []byte
+	frame []float64
+}
+
+// NewCanceller creates an echo canceller for a capture source at rate
+func NewCanceller(source io.Reader, ref *Reference, rate int) *Canceller {
+	taps := int(defaultTailLength.Seconds() * float64(rate))
+	coef := func(d time.Duration) float64 {
+		return 1 - math.Exp(-1/(d.Seconds()*float64(rate)))
+	}
+	return &Canceller{
+		source:     source,
+		ref:        ref,
+		step:       defaultStepSize,
+		taps:       taps,
+		weights:    make([]float64, taps),
+		history:    make([]float64, 2*taps),
+		peakDecay:  1 - coef(peakDecayTime),
+		holdLength: int(doubleTalkHold.Seconds() * float64(rate)),
+		suppress:   math.Pow(10, defaultSuppression/20),
+		gain:       1,
+		gainCoef:   coef(suppressionTime),
+		erleCoef:   coef(erleAveragingTime),
+	}
+}
+
+// SetStepSize sets the NLMS step size (0, 1]. Larger steps converge
+// faster but leave more residual echo.
+func (c *Canceller) SetStepSize(step float64) {
+	c.step = math.Max(1e-3, math.Min(1, step))
+}
+
+// SetSuppression sets the attenuation in dB applied to the residual while
+// only the host is talking, 0 disables it
+func (c *Canceller) SetSuppression(db float64) {
+	c.suppress = math.Pow(10, math.Min(0, db)/20)
+}
+
+// ERLE returns the recent echo return loss enhancement in dB: how much
+// weaker the output is than the microphone signal
+func (c *Canceller) ERLE() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.errPower == 0 {
+		return 0
+	}
+	return 10 * math.Log10(c.micPower/c.errPower)
+}
+
+// Read reads captured audio with the echo removed
+func (c *Canceller) Read(p []byte) (int, error) {
+	want := len(p) &^ 1
+	if want == 0 {
+		return 0, nil
+	}
+
+	if cap(c.buf) < want {
+		c.buf = make([]byte, want)
+	}
+	buf := c.buf[:want]
+	n := copy(buf, c.carry)
+	c.carry = c.carry[:0]
+
+	m, err := c.source.Read(buf[n:])
+	n += m
+	if n%2 == 1 {
+		c.carry = append(c.carry, buf[n-1])
+		n--
+	}
+	if n == 0 {
+		return 0, err
+	}
+
+	samples := n / 2
+	if cap(c.frame) < samples {
+		c.frame = make([]float64, samples)
+	}
+	far := c.frame[:samples]
+	c.ref.Read(far)
+
+	var micSum, errSum float64
+	for i := 0; i < samples; i++ {
+		d := float64(int16(binary.LittleEndian.Uint16(buf[i*2:]))) / 32768
+		e := c.process(d, far[i])
+		micSum += d * d
+		errSum += e * e
+		binary.LittleEndian.PutUint16(p[i*2:], uint16(toInt16(e)))
+	}
+	c.updateERLE(micSum, errSum, samples)
+
+	return n, err
+}
+
+// process cancels the echo from one microphone sample d given the
+// reference sample x played at the same time
+func (c *Canceller) process(d, x float64) float64 {
+	// Push x into the history, newest first
+	old := c.history[c.pos+c.taps-1]
+	c.pos = (c.pos - 1 + c.taps) % c.taps
+	c.history[c.pos] = x
+	c.history[c.pos+c.taps] = x
+	c.power += x*x - old*old
+	if c.power < 0 {
+		c.power = 0
+	}
+
+	hist := c.history[c.pos : c.pos+c.taps]
+	var y float64
+	for k, w := range c.weights {
+		y += w * hist[k]
+	}
+	e := d - y
+
+	// Geigel double talk detector on a decaying far-end peak
+	c.farPeak = math.Max(math.Abs(x), c.farPeak*c.peakDecay)
+	farActive := c.farPeak > farActiveLevel
+	if farActive && math.Abs(d) > geigelThreshold*c.farPeak {
+		c.hold = c.holdLength
+	} else if c.hold > 0 {
+		c.hold--
+	}
+	doubleTalk := c.hold > 0
+
+	if farActive && !doubleTalk {
+		mu := c.step * e / (c.power + regularization)
+		for k := range c.weights {
+			c.weights[k] += mu * hist[k]
+		}
+	}
+
+	target := 1.0
+	if farActive && !doubleTalk {
+		target = c.suppress
+	}
+	c.gain += (target - c.gain) * c.gainCoef
+	return e * c.gain
+}
+
+func (c *Canceller) updateERLE(micSum, errSum float64, samples int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a := 1 - math.Pow(1-c.erleCoef, float64(samples))
+	c.micPower += (micSum/float64(samples) - c.micPower) * a
+	c.errPower += (errSum/float64(samples) - c.errPower) * a
+}
+
+func toInt16(x float64) int16 {
+	v := math.Round(x * 32768)
+	if v > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if v < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(v)
+}
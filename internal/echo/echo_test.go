@@ -0,0 +1,249 @@
+package echo
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/vad"
+)
+
+const (
+	testRate  = 16000
+	frameSize = 320 // 20ms
+)
+
+// speechLike 合成类语音信号：声门脉冲串经共振峰滤波并按音节调幅，
+// 用 f0 和 seed 区分主持人与用户的声音
+func speechLike(rng *rand.Rand, n int, f0, db float64) []float64 {
+	out := make([]float64, n)
+	formants := []float64{700, 400, 550, 300, 650}
+	syllable := testRate / 4
+
+	var y1, y2, phase float64
+	var a1, a2 float64
+	for i := range out {
+		if i%syllable == 0 {
+			f := formants[rng.Intn(len(formants))]
+			r := math.Exp(-math.Pi * 120 / testRate)
+			a1, a2 = 2*r*math.Cos(2*math.Pi*f/testRate), -r*r
+		}
+		pos := float64(i%syllable) / float64(syllable)
+
+		x := rng.NormFloat64() * 0.02
+		phase += f0 * (1 + 0.1*math.Sin(2*math.Pi*float64(i)/testRate)) / testRate
+		if phase >= 1 {
+			phase--
+			x += 1
+		}
+		y := x + a1*y1 + a2*y2
+		y2, y1 = y1, y
+		out[i] = y * math.Sin(math.Pi*pos)
+	}
+	return normalize(out, db)
+}
+
+func normalize(x []float64, db float64) []float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	gain := math.Pow(10, db/20) / math.Sqrt(sum/float64(len(x)))
+	for i := range x {
+		x[i] *= gain
+	}
+	return x
+}
+
+// echoPath 模拟扬声器到麦克风的声学路径：10ms 延迟加一个短 FIR
+func echoPath(far []float64) []float64 {
+	taps := []float64{0.25, 0.12, -0.06, 0.03}
+	delay := testRate / 100
+	out := make([]float64, len(far))
+	for i := range out {
+		for k, h := range taps {
+			if j := i - delay - k*3; j >= 0 {
+				out[i] += h * far[j]
+			}
+		}
+	}
+	return out
+}
+
+func toPCM(x []float64) []byte {
+	out := make([]byte, len(x)*2)
+	for i, v := range x {
+		binary.LittleEndian.PutUint16(out[i*2:], uint16(toInt16(v)))
+	}
+	return out
+}
+
+func fromPCM(b []byte) []float64 {
+	out := make([]float64, len(b)/2)
+	for i := range out {
+		out[i] = float64(int16(binary.LittleEndian.Uint16(b[i*2:]))) / 32768
+	}
+	return out
+}
+
+func rms(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	return 10 * math.Log10(sum/float64(len(x))+1e-20)
+}
+
+// run 按 20ms 一帧交替发布参考信号、读取消除后的麦克风信号
+func run(t *testing.T, c *Canceller, ref *Reference, far, mic []float64) []float64 {
+	t.Helper()
+	source := bytes.NewReader(toPCM(mic))
+	c.source = source
+
+	var out []float64
+	buf := make([]byte, frameSize*2)
+	for i := 0; i+frameSize <= len(mic); i += frameSize {
+		ref.Write(toPCM(far[i:i+frameSize]), testRate)
+		if _, err := io.ReadFull(c, buf); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		out = append(out, fromPCM(buf)...)
+	}
+	return out
+}
+
+func TestCanceller_EchoOnly(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	far := speechLike(rng, testRate*4, 120, -20)
+	mic := echoPath(far)
+
+	ref := NewReference(testRate)
+	c := NewCanceller(nil, ref, testRate)
+	c.SetSuppression(0) // 只衡量自适应滤波器本身
+	out := run(t, c, ref, far, mic)
+
+	tail := testRate * 3
+	erle := rms(mic[tail:]) - rms(out[tail:])
+	if erle < 20 {
+		t.Errorf("ERLE after convergence %.1f dB, want at least 20 dB", erle)
+	}
+	if got := c.ERLE(); got < 15 {
+		t.Errorf("reported ERLE %.1f dB, want at least 15 dB", got)
+	}
+}
+
+func TestCanceller_DoubleTalk(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	n := testRate * 6
+	far := speechLike(rng, n, 120, -20)
+
+	near := make([]float64, n)
+	copy(near[testRate*3:], speechLike(rng, testRate*2, 210, -22))
+	echo := echoPath(far)
+	mic := make([]float64, n)
+	for i := range mic {
+		mic[i] = echo[i] + near[i]
+	}
+
+	ref := NewReference(testRate)
+	c := NewCanceller(nil, ref, testRate)
+	out := run(t, c, ref, far, mic)
+
+	// 用户说话期间的输出应基本是用户的声音
+	talk := out[testRate*3 : testRate*5]
+	residual := make([]float64, len(talk))
+	for i := range talk {
+		residual[i] = talk[i] - near[testRate*3+i]
+	}
+	if snr := rms(near[testRate*3:testRate*5]) - rms(residual); snr < 10 {
+		t.Errorf("near-end speech distorted: SNR %.1f dB", snr)
+	}
+
+	// 双讲结束后滤波器没有发散
+	if erle := rms(mic[testRate*5+testRate/2:]) - rms(out[testRate*5+testRate/2:]); erle < 20 {
+		t.Errorf("ERLE after double talk %.1f dB, want at least 20 dB", erle)
+	}
+}
+
+func TestReference_Resample(t *testing.T) {
+	ref := NewReference(testRate)
+
+	// 24kHz 的 1kHz 正弦，分多次以奇数字节写入
+	const srcRate = 24000
+	src := make([]float64, srcRate/2)
+	for i := range src {
+		src[i] = 0.5 * math.Sin(2*math.Pi*1000*float64(i)/srcRate)
+	}
+	pcm := toPCM(src)
+	for i := 0; i < len(pcm); i += 999 {
+		ref.Write(pcm[i:min(i+999, len(pcm))], srcRate)
+	}
+
+	out := make([]float64, testRate/2+100)
+	ref.Read(out)
+	if got := out[testRate/2-2 : testRate/2+100]; rms(got[4:]) > -100 {
+		t.Errorf("reference longer than the resampled input")
+	}
+
+	for i := 10; i < testRate/2-10; i++ {
+		want := 0.5 * math.Sin(2*math.Pi*1000*float64(i)/testRate)
+		if math.Abs(out[i]-want) > 0.02 {
+			t.Fatalf("sample %d = %.4f, want %.4f", i, out[i], want)
+		}
+	}
+
+	ref.Reset()
+	ref.Read(out[:10])
+	for _, v := range out[:10] {
+		if v != 0 {
+			t.Fatal("reference not empty after Reset")
+		}
+	}
+}
+
+// 把消除后的信号交给能量 VAD：主持人自己的声音不触发，用户插话触发
+func TestCanceller_GatesVAD(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	n := testRate * 6
+	far := speechLike(rng, n, 120, -20)
+	echo := echoPath(far)
+
+	near := make([]float64, n)
+	copy(near[testRate*4:], speechLike(rng, testRate, 210, -20))
+	mic := make([]float64, n)
+	for i := range mic {
+		mic[i] = echo[i] + near[i]
+	}
+
+	starts := func(source io.Reader) []time.Duration {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var offsets []time.Duration
+		for ev := range vad.NewEnergy(source, testRate).Start(ctx) {
+			if ev.Type == ai.SpeechStart {
+				offsets = append(offsets, time.Duration(ev.Offset)*time.Second/testRate)
+			}
+		}
+		return offsets
+	}
+
+	// 不做消除时 VAD 会被主持人的声音触发
+	if got := starts(bytes.NewReader(toPCM(mic))); len(got) == 0 || got[0] >= 4*time.Second {
+		t.Fatalf("expected the raw echo to trigger the VAD before 4s, got %v", got)
+	}
+
+	ref := NewReference(testRate)
+	c := NewCanceller(nil, ref, testRate)
+	cleaned := toPCM(run(t, c, ref, far, mic))
+
+	got := starts(bytes.NewReader(cleaned))
+	if len(got) != 1 || got[0] < 4*time.Second || got[0] > 4*time.Second+200*time.Millisecond {
+		t.Errorf("VAD onsets %v, want one at about 4s", got)
+	}
+}
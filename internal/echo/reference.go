@@ -0,0 +1,95 @@
+// Package echo removes the podcast's own playback from the microphone
+// signal, so the VAD does not hear the host and interrupt it.
+package echo
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// maxReferenceLag bounds how far the published reference may run ahead of
+// the capture; older samples are dropped to stay in step
+const maxReferenceLag = 2 * time.Second
+
+// Reference carries the audio the player is sending to the speakers to
+// the echo canceller, resampled to the capture rate. The player writes
+// what it plays, the canceller reads one reference sample per captured
+// sample.
+type Reference struct {
+	rate   int
+	maxLen int
+
+	mu    sync.Mutex
+	fifo  []float64
+	carry []byte // odd byte left over from the previous write
+
+	// streaming linear resampler state
+	srcRate int
+	prev    float64
+	phase   float64 // position of the next output between prev and the next input, 1 before the first input
+}
+
+// NewReference creates a reference for a capture path running at rate
+func NewReference(rate int) *Reference {
+	return &Reference{
+		rate:   rate,
+		maxLen: int(maxReferenceLag.Seconds() * float64(rate)),
+	}
+}
+
+// Write publishes PCM16 little-endian mono audio being played at
+// sampleRate
+func (r *Reference) Write(pcm []byte, sampleRate int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if sampleRate != r.srcRate {
+		r.srcRate = sampleRate
+		r.phase = 1
+	}
+	if len(r.carry) > 0 {
+		pcm = append(r.carry, pcm...)
+		r.carry = nil
+	}
+	if len(pcm)%2 == 1 {
+		r.carry = []byte{pcm[len(pcm)-1]}
+		pcm = pcm[:len(pcm)-1]
+	}
+
+	step := float64(sampleRate) / float64(r.rate)
+	for i := 0; i+1 < len(pcm); i += 2 {
+		x := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) / 32768
+		for r.phase < 1 {
+			r.fifo = append(r.fifo, r.prev+(x-r.prev)*r.phase)
+			r.phase += step
+		}
+		r.phase--
+		r.prev = x
+	}
+
+	if over := len(r.fifo) - r.maxLen; over > 0 {
+		r.fifo = append(r.fifo[:0], r.fifo[over:]...)
+	}
+}
+
+// Read fills out with the next reference samples, padding with silence
+// when nothing is playing
+func (r *Reference) Read(out []float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := copy(out, r.fifo)
+	clear(out[n:])
+	r.fifo = r.fifo[n:]
+}
+
+// Reset drops pending reference audio, e.g. when playback is stopped
+func (r *Reference) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fifo = nil
+	r.carry = nil
+	r.prev = 0
+	r.phase = 1
+}
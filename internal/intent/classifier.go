@@ -0,0 +1,98 @@
+package intent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/command"
+)
+
+// defaultLLMBudget bounds the LLM call, which delays every answer
+const defaultLLMBudget = 500 * time.Millisecond
+
+// intentPrompt asks the LLM for a one-word label
+const intentPrompt = `A listener interrupted a podcast and said the words below. Classify them with exactly one word:
+"question" if they ask something or make a remark the host should respond to,
+"backchannel" if they only show they are following, like "yeah" or "right",
+"command" if they tell the player to pause, skip, repeat or change speed or volume,
+"noise" if it is not meaningful speech.
+
+Listener: %q`
+
+// Classifier labels utterances by rules, and asks an LLM about those the
+// rules cannot tell. Without an LLM, or when it is slow or fails, such
+// utterances count as questions so the listener is not ignored.
+type Classifier struct {
+	llm    ai.LLMEngine
+	budget time.Duration
+}
+
+// NewClassifier creates a classifier using rules only
+func NewClassifier() *Classifier {
+	return &Classifier{budget: defaultLLMBudget}
+}
+
+// SetLLM consults llm for utterances the rules cannot tell. Answers taking
+// longer than budget are ignored; a small, fast model is enough.
+func (c *Classifier) SetLLM(llm ai.LLMEngine, budget time.Duration) {
+	c.llm = llm
+	c.budget = budget
+}
+
+// Classify labels text. It never returns Unknown. Only what commands
+// match is a Command: what the LLM takes for a command they do not know
+// counts as a question, since nothing would carry it out.
+func (c *Classifier) Classify(ctx context.Context, text string, commands *command.Grammar) Kind {
+	kind := Analyze(text, commands)
+	if kind != Unknown {
+		return kind
+	}
+	if c.llm == nil {
+		return Question
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.budget)
+	defer cancel()
+	kind, err := c.ask(ctx, text)
+	if err != nil {
+		log.Printf("[Intent] Classifier unavailable for %q: %v", text, err)
+		return Question
+	}
+	if kind == Command {
+		log.Printf("[Intent] %q sounds like an unknown command, answering it", text)
+		return Question
+	}
+	return kind
+}
+
+// ask reads the LLM's label from the first words of its answer
+func (c *Classifier) ask(ctx context.Context, text string) (Kind, error) {
+	labels := map[string]Kind{
+		"question":    Question,
+		"backchannel": Backchannel,
+		"command":     Command,
+		"noise":       Noise,
+	}
+
+	var answer strings.Builder
+	for chunk := range c.llm.GenerateResponse(ctx, fmt.Sprintf(intentPrompt, text), "") {
+		answer.WriteString(chunk)
+		word := strings.ToLower(strings.TrimSpace(answer.String()))
+		for label, kind := range labels {
+			if strings.HasPrefix(word, label) {
+				return kind, nil
+			}
+		}
+		if len(word) >= len("backchannel") {
+			return Unknown, fmt.Errorf("unexpected classifier answer %q", word)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return Unknown, err
+	}
+	return Unknown, fmt.Errorf("unexpected classifier answer %q", answer.String())
+}
@@ -0,0 +1,144 @@
+// Package intent tells what a listener meant by interrupting: a question
+// to answer, a command, or a backchannel or noise that needs no answer.
+package intent
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/command"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/turn"
+)
+
+// Kind is what an utterance is for
+type Kind int
+
+const (
+	Unknown     Kind = iota // the rules cannot tell
+	Question                // asks something, or makes a remark worth answering
+	Backchannel             // "yeah", "对对对", "uh-huh": the listener is following along
+	Command                 // controls playback: "pause", "louder", "下一章"
+	Noise                   // a cough, music or an empty transcript
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Question:
+		return "Question"
+	case Backchannel:
+		return "Backchannel"
+	case Command:
+		return "Command"
+	case Noise:
+		return "Noise"
+	default:
+		return "Unknown"
+	}
+}
+
+// Answered reports whether an utterance of this kind gets an answer
+func (k Kind) Answered() bool {
+	return k == Question || k == Unknown
+}
+
+// Utterances are matched with case, spaces and punctuation removed, as a
+// sequence of these tokens
+var (
+	backchannels = tokens(
+		"yeah", "yes", "yep", "yup", "ya", "uh huh", "uh-huh", "mm hm", "mm-hm", "mm-hmm", "mhm",
+		"mm", "hmm", "um", "uh", "ah", "oh", "ok", "okay", "right", "sure", "cool", "nice", "wow",
+		"got it", "i see", "true", "exactly", "interesting", "alright", "go on", "makes sense",
+		"对", "嗯", "是", "好", "哦", "噢", "啊", "行", "没错", "是的", "对的", "好的", "明白",
+		"懂了", "原来如此", "有道理", "这样", "确实",
+	)
+	// what recognizers tend to produce for silence or background sound
+	hallucinations = tokens(
+		"thank you", "thanks for watching", "you",
+		"字幕由amara.org社区提供", "请不吝点赞订阅转发打赏支持明镜与点点栏目",
+	)
+)
+
+// Analyze classifies an utterance by rules alone. It returns Unknown for
+// remarks that are neither clearly a question nor clearly nothing.
+// commands are the playback controls the caller carries out; with nil,
+// nothing is a Command.
+func Analyze(text string, commands *command.Grammar) Kind {
+	text = strings.TrimSpace(text)
+	if isBracketed(text) {
+		return Noise
+	}
+	key := normalize(text)
+	if key == "" || hallucinations[key] {
+		return Noise
+	}
+	if commands != nil {
+		if _, ok := commands.Match(text); ok {
+			return Command
+		}
+	}
+	if turn.IsQuestion(text) {
+		return Question
+	}
+	if segment(key, backchannels) {
+		return Backchannel
+	}
+	return Unknown
+}
+
+// isBracketed reports whether text is only sound descriptions such as
+// "(coughs)" or "[MUSIC]"
+func isBracketed(text string) bool {
+	if text == "" {
+		return false
+	}
+	depth := 0
+	for _, r := range text {
+		switch r {
+		case '(', '[', '（', '【':
+			depth++
+		case ')', ']', '）', '】':
+			depth--
+		default:
+			if depth == 0 && !unicode.IsSpace(r) && !unicode.IsPunct(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// normalize lowercases text and keeps only letters and digits, so
+// "Uh-huh, yeah." becomes "uhhuhyeah"
+func normalize(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func tokens(list ...string) map[string]bool {
+	set := make(map[string]bool, len(list))
+	for _, t := range list {
+		set[normalize(t)] = true
+	}
+	return set
+}
+
+// segment reports whether s can be split entirely into tokens from set
+func segment(s string, set map[string]bool) bool {
+	if s == "" {
+		return false
+	}
+	// ok[i] is whether s[:i] can be split
+	ok := make([]bool, len(s)+1)
+	ok[0] = true
+	for i := 1; i <= len(s); i++ {
+		for j := 0; j < i && !ok[i]; j++ {
+			ok[i] = ok[j] && set[s[j:i]]
+		}
+	}
+	return ok[len(s)]
+}
@@ -0,0 +1,107 @@
+package intent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/command"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		text string
+		want Kind
+	}{
+		{"yeah", Backchannel},
+		{"Uh-huh, yeah.", Backchannel},
+		{"Mm-hmm", Backchannel},
+		{"对对对", Backchannel},
+		{"嗯嗯，有道理", Backchannel},
+		{"I see.", Backchannel},
+		{"why?", Question},
+		{"Right?", Question},
+		{"这个数据是哪来的", Question},
+		{"Pause", Command},
+		{"please stop", Command},
+		{"下一章吧", Command},
+		{"大声点", Command},
+		{"(coughs)", Noise},
+		{"[MUSIC]", Noise},
+		{"Thank you.", Noise},
+		{"  ", Noise},
+		{"...", Noise},
+		{"please", Unknown},
+		{"I don't agree with that", Unknown},
+		{"yesterday", Unknown},
+	}
+	commands := command.NewGrammar()
+	for _, tt := range tests {
+		if got := Analyze(tt.text, commands); got != tt.want {
+			t.Errorf("Analyze(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+
+	// 没有语音命令时，命令词不算 Command
+	if got := Analyze("Pause", nil); got != Unknown {
+		t.Errorf("Analyze(%q) without commands = %s, want Unknown", "Pause", got)
+	}
+}
+
+// fakeLLM 以固定文本回答，可延迟
+type fakeLLM struct {
+	answer string
+	delay  time.Duration
+}
+
+func (f fakeLLM) GenerateStream(ctx context.Context, prompt string) <-chan string {
+	return f.GenerateResponse(ctx, prompt, "")
+}
+
+func (f fakeLLM) GenerateResponse(ctx context.Context, question, context string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return
+		}
+		for _, r := range f.answer {
+			select {
+			case ch <- string(r):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func TestClassifier(t *testing.T) {
+	tests := []struct {
+		name string
+		llm  *fakeLLM
+		text string
+		want Kind
+	}{
+		{"rules", &fakeLLM{answer: "question"}, "yeah", Backchannel},
+		{"no llm", nil, "I don't agree with that", Question},
+		{"llm", &fakeLLM{answer: "Backchannel."}, "that's fair", Backchannel},
+		{"llm noise", &fakeLLM{answer: "noise"}, "hm ah la", Noise},
+		{"slow llm", &fakeLLM{answer: "backchannel", delay: time.Second}, "that's fair", Question},
+		{"bad answer", &fakeLLM{answer: "I think it is a remark"}, "that's fair", Question},
+		{"unknown command", &fakeLLM{answer: "command"}, "turn the bass up", Question},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewClassifier()
+			if tt.llm != nil {
+				c.SetLLM(tt.llm, 50*time.Millisecond)
+			}
+			if got := c.Classify(context.Background(), tt.text, command.NewGrammar()); got != tt.want {
+				t.Errorf("Classify(%q) = %s, want %s", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"sync"
+	"unicode"
+)
+
+// defaultAcknowledgements are played while an answer is being prepared
+var defaultAcknowledgements = []string{
+	"Good question, let me think.",
+	"Hmm, let me think about that.",
+	"Right, give me a second.",
+	"Okay, let me see.",
+	"好问题，我想一想。",
+	"嗯，让我想想。",
+	"这个问题问得好。",
+	"好，稍等一下。",
+}
+
+// acknowledgements are short phrases that fill the silence between the
+// end of a question and the first audio of the answer. Their audio is
+// synthesized ahead of time for each voice, and they are used in turn so
+// the same one is not heard twice in a row.
+type acknowledgements struct {
+	phrases map[bool][]string // by whether they are Chinese
+
+	mu     sync.Mutex
+	audio  map[string][]byte // by voice and phrase
+	voices map[string]bool   // voices prepared or being prepared
+	next   map[bool]int
+}
+
+func newAcknowledgements(phrases []string) *acknowledgements {
+	a := &acknowledgements{
+		phrases: make(map[bool][]string),
+		audio:   make(map[string][]byte),
+		voices:  make(map[string]bool),
+		next:    make(map[bool]int),
+	}
+	for _, p := range phrases {
+		zh := isChinese(p)
+		a.phrases[zh] = append(a.phrases[zh], p)
+	}
+	return a
+}
+
+// SetAcknowledgements sets the phrases played while an answer is being
+// prepared, e.g. "Good question, let me think..." or "好问题". Questions
+// get a phrase in their own language. No phrases disables them.
+func (o *Orchestrator) SetAcknowledgements(phrases ...string) {
+	if len(phrases) == 0 {
+		o.acks = nil
+		return
+	}
+	o.acks = newAcknowledgements(phrases)
+}
+
+// ensureAcknowledgements starts synthesizing the acknowledgements in the
+// current voice under ctx, unless they have been already. It is called at
+// the start of the session and of every script segment, so after the
+// voice changes they are ready again by the next question.
+func (o *Orchestrator) ensureAcknowledgements(ctx context.Context) {
+	acks := o.acks
+	if acks == nil {
+		return
+	}
+	voice := o.voice()
+	if !acks.claim(voice) {
+		return
+	}
+	o.spawn(func() {
+		if !o.prepareAcknowledgements(ctx, acks, voice) {
+			acks.release(voice)
+		}
+	})
+}
+
+// prepareAcknowledgements synthesizes the acknowledgements in voice, so
+// they play without delay. It gives up, reporting false, when ctx is done
+// or the voice changes meanwhile.
+func (o *Orchestrator) prepareAcknowledgements(ctx context.Context, acks *acknowledgements, voice string) bool {
+	for _, phrases := range acks.phrases {
+		for _, p := range phrases {
+			if acks.get(voice, p) != nil {
+				continue
+			}
+			var pcm []byte
+			for chunk := range o.level(ctx, o.tts.SynthesizeStream(ctx, p)) {
+				pcm = append(pcm, chunk...)
+			}
+			if ctx.Err() != nil || o.voice() != voice {
+				return false
+			}
+			acks.set(voice, p, pcm)
+		}
+	}
+	log.Printf("[Orchestrator] Acknowledgements ready for voice %q", voice)
+	return true
+}
+
+// claim reports whether voice still needs preparing, and marks it as
+// being prepared
+func (a *acknowledgements) claim(voice string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.voices[voice] {
+		return false
+	}
+	a.voices[voice] = true
+	return true
+}
+
+// release lets voice be prepared again after an attempt gave up
+func (a *acknowledgements) release(voice string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.voices, voice)
+}
+
+func (a *acknowledgements) get(voice, phrase string) []byte {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.audio[voice+"\x00"+phrase]
+}
+
+func (a *acknowledgements) set(voice, phrase string, pcm []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(pcm) > 0 {
+		a.audio[voice+"\x00"+phrase] = pcm
+	}
+}
+
+// pick returns the next prepared acknowledgement in the language of
+// question, or nil when none is ready
+func (a *acknowledgements) pick(voice, question string) (string, []byte) {
+	zh := isChinese(question)
+	phrases := a.phrases[zh]
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for range phrases {
+		i := a.next[zh] % len(phrases)
+		a.next[zh] = i + 1
+		if pcm := a.audio[voice+"\x00"+phrases[i]]; pcm != nil {
+			return phrases[i], pcm
+		}
+	}
+	return "", nil
+}
+
+// acknowledge plays an acknowledgement while the answer to question is
+// prepared. Nothing is played when the answer has already started to
+// arrive. When its first chunk arrives during the acknowledgement, it is
+// synthesized right away and played as soon as the acknowledgement ends.
+// The remaining responses are returned.
+func (o *Orchestrator) acknowledge(ctx context.Context, question string, responses <-chan string) <-chan string {
+	if o.acks == nil {
+		return responses
+	}
+	select {
+	case text, ok := <-responses:
+		if !ok {
+			return responses
+		}
+		return prepend(text, responses)
+	default:
+	}
+
+	phrase, pcm := o.acks.pick(o.voice(), question)
+	if pcm == nil {
+		log.Printf("[Orchestrator] No acknowledgement ready for voice %q", o.voice())
+		o.ensureAcknowledgements(o.session())
+		return responses
+	}
+	log.Printf("[Orchestrator] Acknowledging: %s", phrase)
+	ack := make(chan []byte, 1)
+	ack <- pcm
+	close(ack)
+	played := make(chan error, 1)
+	go func() {
+		played <- o.player.PlayStream(ctx, ack)
+	}()
+
+	select {
+	case <-played:
+		return responses
+	case text, ok := <-responses:
+		if !ok {
+			<-played
+			return responses
+		}
+		first := o.synthesize(ctx, text)
+		<-played
+		log.Printf("[Orchestrator] Response chunk: %s", text)
+		if err := o.play(ctx, first); err != nil {
+			log.Printf("[Orchestrator] Response playback error: %v", err)
+		}
+		return responses
+	}
+}
+
+// prepend returns a channel with text followed by everything from rest
+func prepend(text string, rest <-chan string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		ch <- text
+		for t := range rest {
+			ch <- t
+		}
+	}()
+	return ch
+}
+
+// isChinese reports whether text contains Han characters
+func isChinese(text string) bool {
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package orchestrator
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// slowPlayer 记录每次播放的音频长度，每次播放耗时固定
+type slowPlayer struct {
+	fakePositionedPlayer
+	mu     sync.Mutex
+	played []int
+}
+
+func (p *slowPlayer) PlayStream(ctx context.Context, audioStream <-chan []byte) error {
+	n := 0
+	for chunk := range audioStream {
+		n += len(chunk)
+	}
+	time.Sleep(50 * time.Millisecond)
+	p.mu.Lock()
+	p.played = append(p.played, n)
+	p.mu.Unlock()
+	return nil
+}
+
+func TestAcknowledgements_Rotate(t *testing.T) {
+	o := New(&answerLLM{}, &alignedTTS{}, nil, &slowPlayer{}, nil)
+	o.ensureAcknowledgements(context.Background())
+	o.loops.Wait()
+
+	// 同一语言的确认语轮流使用，不连续重复
+	first, _ := o.acks.pick("", "why is that?")
+	second, _ := o.acks.pick("", "and how?")
+	if first == second || isChinese(first) || isChinese(second) {
+		t.Errorf("picked %q then %q for English questions", first, second)
+	}
+	if zh, pcm := o.acks.pick("", "为什么？"); !isChinese(zh) || len(pcm) == 0 {
+		t.Errorf("picked %q for a Chinese question", zh)
+	}
+	// 其他音色尚未合成
+	if p, _ := o.acks.pick("other voice", "why?"); p != "" {
+		t.Errorf("picked %q without audio for the voice", p)
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	tts := &alignedTTS{}
+	player := &slowPlayer{}
+	o := New(&answerLLM{}, tts, nil, player, nil)
+	o.SetAcknowledgements("Okay.")
+	o.ensureAcknowledgements(context.Background())
+	o.loops.Wait()
+	ackBytes := len([]rune("Okay.")) * ttsRate / 20 * 2
+
+	t.Run("answer arrives during acknowledgement", func(t *testing.T) {
+		player.played = nil
+		responses := make(chan string)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			responses <- "ok"
+			close(responses)
+		}()
+
+		// 先播确认语，答案第一段紧接着播放
+		rest := o.acknowledge(context.Background(), "why?", responses)
+		if _, ok := <-rest; ok {
+			t.Error("first chunk returned after it was played")
+		}
+		if len(player.played) != 2 || player.played[0] != ackBytes {
+			t.Errorf("played %v, want the acknowledgement then the answer", player.played)
+		}
+	})
+
+	t.Run("answer ready", func(t *testing.T) {
+		player.played = nil
+		responses := make(chan string, 1)
+		responses <- "ok"
+		close(responses)
+
+		// 答案已就绪时不播放确认语
+		rest := o.acknowledge(context.Background(), "why?", responses)
+		if text := <-rest; text != "ok" {
+			t.Errorf("first response %q, want the ready chunk", text)
+		}
+		if len(player.played) != 0 {
+			t.Errorf("played %v, want nothing", player.played)
+		}
+	})
+}
+
+// voicedTTS 是可切换音色的 alignedTTS，记录每段文本合成时的音色
+type voicedTTS struct {
+	alignedTTS
+	voice  string
+	voices []string
+}
+
+func (v *voicedTTS) Voice() string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.voice
+}
+
+func (v *voicedTTS) SetVoice(voice string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.voice = voice
+}
+
+func (v *voicedTTS) SynthesizeStream(ctx context.Context, text string) <-chan []byte {
+	audio, _ := v.SynthesizeAligned(ctx, text)
+	return audio
+}
+
+func (v *voicedTTS) SynthesizeAligned(ctx context.Context, text string) (<-chan []byte, <-chan ai.Segment) {
+	v.mu.Lock()
+	v.voices = append(v.voices, v.voice+": "+text)
+	v.mu.Unlock()
+	return v.alignedTTS.SynthesizeAligned(ctx, text)
+}
+
+func TestAcknowledgements_VoiceChange(t *testing.T) {
+	tts := &voicedTTS{voice: "tongtong"}
+	player := &slowPlayer{}
+	o := New(&answerLLM{}, tts, nil, player, nil)
+	o.SetAcknowledgements("Okay.")
+	o.ensureAcknowledgements(context.Background())
+	o.loops.Wait()
+
+	// 换音色后，下一段脚本开始时用新音色重新合成确认语
+	tts.SetVoice("xiaochen")
+	o.playSegment(paragraph{text: "Next."})
+	o.loops.Wait()
+	for _, voice := range []string{"tongtong", "xiaochen"} {
+		if p, pcm := o.acks.pick(voice, "why?"); p != "Okay." || len(pcm) == 0 {
+			t.Errorf("picked %q for voice %q, want the acknowledgement", p, voice)
+		}
+	}
+	want := []string{"tongtong: Okay.", "xiaochen: Next.", "xiaochen: Okay."}
+	tts.mu.Lock()
+	got := append([]string(nil), tts.voices...)
+	tts.mu.Unlock()
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("synthesized %q, want %q", got, want)
+	}
+
+	// 提问时用新音色播放确认语
+	player.played = nil
+	responses := make(chan string)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		responses <- "ok"
+		close(responses)
+	}()
+	for range o.acknowledge(context.Background(), "why?", responses) {
+	}
+	if len(player.played) != 2 {
+		t.Errorf("played %v, want the acknowledgement then the answer", player.played)
+	}
+}
+
+func TestAcknowledge_UnpreparedVoice(t *testing.T) {
+	tts := &voicedTTS{voice: "tongtong"}
+	player := &slowPlayer{}
+	o := New(&answerLLM{}, tts, nil, player, nil)
+	o.SetAcknowledgements("Okay.")
+
+	// 当前音色的确认语尚未合成时不播放，并在后台合成
+	o.acknowledge(context.Background(), "why?", make(chan string))
+	o.loops.Wait()
+	if len(player.played) != 0 {
+		t.Errorf("played %v before the acknowledgement was ready", player.played)
+	}
+	if p, _ := o.acks.pick("tongtong", "why?"); p != "Okay." {
+		t.Errorf("picked %q after a miss, want the acknowledgement prepared", p)
+	}
+}
@@ -0,0 +1,108 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// ErrRunning is returned when starting an orchestrator that is running
+var ErrRunning = errors.New("orchestrator: already running")
+
+// Start begins the podcast with the given topic and returns at once. It
+// returns ErrRunning while a session is running; once the script has
+// been played or Stop has been called, it starts a new one.
+func (o *Orchestrator) Start(topic string) error {
+	_, err := o.start(context.Background(), topic)
+	return err
+}
+
+// Run plays the podcast on the given topic and blocks until the script
+// has been played, ctx is done or Stop is called. Every goroutine of the
+// session has exited when it returns. It returns ctx.Err() when ctx ended
+// the session.
+func (o *Orchestrator) Run(ctx context.Context, topic string) error {
+	session, err := o.start(ctx, topic)
+	if err != nil {
+		return err
+	}
+	<-session.Done()
+	o.Stop()
+	return ctx.Err()
+}
+
+// start begins a session under parent and returns its context
+func (o *Orchestrator) start(parent context.Context, topic string) (context.Context, error) {
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+
+	o.phaseMu.Lock()
+	running := o.ctx != nil && o.ctx.Err() == nil
+	o.phaseMu.Unlock()
+	if running {
+		return nil, ErrRunning
+	}
+	// A session that ended on its own may still be winding down
+	o.loops.Wait()
+
+	ctx, cancel := context.WithCancel(parent)
+	o.phaseMu.Lock()
+	o.ctx = ctx
+	o.cancelFunc = cancel
+	o.paused = nil
+	o.phaseMu.Unlock()
+	o.queueMu.Lock()
+	o.queue = nil
+	o.queueMu.Unlock()
+	o.setState(PLAYING)
+
+	// Generate initial podcast script stream. The LLM streams token
+	// deltas; they are played in whole paragraphs.
+	scriptStream := paragraphs(ctx, o.llm.GenerateStream(ctx, topic))
+
+	// Have acknowledgements ready before the first question
+	o.ensureAcknowledgements(ctx)
+
+	// Start playback loop
+	o.spawn(func() { o.playbackLoop(ctx, scriptStream) })
+
+	// Start monitoring for interruptions
+	o.spawn(func() { o.monitorInterruption(ctx) })
+
+	return ctx, nil
+}
+
+// spawn runs fn in a goroutine of the session, which Stop waits for
+func (o *Orchestrator) spawn(fn func()) {
+	o.loops.Add(1)
+	go func() {
+		defer o.loops.Done()
+		fn()
+	}()
+}
+
+// end cancels the session without waiting for it to wind down, for use
+// from its own goroutines
+func (o *Orchestrator) end() {
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	if o.cancelFunc != nil {
+		o.cancelFunc()
+	}
+	o.floorFree.Broadcast()
+}
+
+// Stop stops the orchestrator and returns once every goroutine of the
+// session has exited. Cancelling the session ends every phase running
+// under it. Stopping a stopped orchestrator does nothing more.
+func (o *Orchestrator) Stop() {
+	o.runMu.Lock()
+	defer o.runMu.Unlock()
+
+	log.Println("[Orchestrator] Stopping orchestrator")
+	o.end()
+	o.player.Stop()
+	o.vad.Stop()
+	o.loops.Wait()
+	o.setState(IDLE)
+}
@@ -0,0 +1,144 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// checkNoLeaks 等待会话的所有 goroutine 退出
+func checkNoLeaks(t *testing.T) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		buf := make([]byte, 1<<20)
+		stacks := string(buf[:runtime.Stack(buf, true)])
+		var leaked []string
+		for _, g := range strings.Split(stacks, "\n\n") {
+			if strings.Contains(g, "orchestrator.(*Orchestrator)") {
+				leaked = append(leaked, g)
+			}
+		}
+		if len(leaked) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines still running:\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newScripted(chunks ...string) (*Orchestrator, *timedPlayer) {
+	player := newTimedPlayer(50 * time.Millisecond)
+	o := New(&scriptLLM{chunks: chunks}, textTTS{}, &chanVAD{}, player, fixedRecorder{"why?"})
+	o.SetTurnDetector(nil)
+	return o, player
+}
+
+func TestStart_Restart(t *testing.T) {
+	o, player := newScripted("one", "two")
+
+	if err := o.Start("topic"); err != nil {
+		t.Fatal(err)
+	}
+	// 运行中再次启动不会产生重复的循环
+	if err := o.Start("topic"); !errors.Is(err, ErrRunning) {
+		t.Errorf("second Start() = %v, want ErrRunning", err)
+	}
+	<-player.started
+
+	// Stop 等待所有 goroutine 退出，且可重复调用
+	o.Stop()
+	checkNoLeaks(t)
+	o.Stop()
+
+	// 停止后可以重新开始
+	if err := o.Start("topic"); err != nil {
+		t.Fatalf("Start() after Stop = %v", err)
+	}
+	select {
+	case text := <-player.started:
+		if text != "one" {
+			t.Errorf("restarted with %q, want the script from the start", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing played after restart")
+	}
+	o.Stop()
+	checkNoLeaks(t)
+	if s := o.GetState(); s != IDLE {
+		t.Errorf("state %s after Stop", s)
+	}
+}
+
+func TestRun_WholeSentences(t *testing.T) {
+	// LLM 按 token 流式输出，TTS 收到的是完整的段落，markdown 和数字不会被拆开
+	llm := &scriptLLM{chunks: []string{"**bo", "ld** 1", "23 items", ". Do", "ne.\n"}, deltas: true}
+	player := newTimedPlayer(0)
+	o := New(llm, textTTS{}, &chanVAD{}, player, fixedRecorder{"why?"})
+	o.SetTurnDetector(nil)
+	if err := o.Run(context.Background(), "topic"); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	var got []string
+	for _, p := range player.played() {
+		got = append(got, p.text)
+	}
+	want := []string{"**bold** 123 items. Done."}
+	if !slices.Equal(got, want) {
+		t.Errorf("played %q, want %q", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Run("script ends", func(t *testing.T) {
+		o, player := newScripted("one", "two", "three")
+		if err := o.Run(context.Background(), "topic"); err != nil {
+			t.Errorf("Run() = %v", err)
+		}
+		checkNoLeaks(t)
+		if got := player.played(); len(got) != 3 {
+			t.Errorf("played %+v, want the whole script", got)
+		}
+
+		// 脚本播完后可以再次运行
+		if err := o.Run(context.Background(), "topic"); err != nil {
+			t.Errorf("second Run() = %v", err)
+		}
+		checkNoLeaks(t)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		o, player := newScripted("one", "two", "three")
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-player.started
+			cancel()
+		}()
+		if err := o.Run(ctx, "topic"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Run() = %v, want context.Canceled", err)
+		}
+		checkNoLeaks(t)
+		if got := player.played(); len(got) != 1 || !got[0].interrupted {
+			t.Errorf("played %+v, want the first segment cut short", got)
+		}
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		o, player := newScripted("one", "two", "three")
+		go func() {
+			<-player.started
+			o.Stop()
+		}()
+		if err := o.Run(context.Background(), "topic"); err != nil {
+			t.Errorf("Run() = %v after Stop", err)
+		}
+		checkNoLeaks(t)
+	})
+}
@@ -4,33 +4,231 @@ import (
 	"context"
 	"log"
 	"sync"
+	"time"
 
 	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/align"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/audio"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/command"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/intent"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/textnorm"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/turn"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wake"
+)
+
+const (
+	// defaultWakeCapture bounds the capture that checks for the wake phrase
+	defaultWakeCapture = 3 * time.Second
+
+	// duckRamp is how quickly the volume goes down and back up in soft
+	// interruption mode
+	duckRamp = 100 * time.Millisecond
+
+	// voice changes per "slower"/"faster" and "louder"/"quieter"
+	speedStep    = 0.25
+	volumeFactor = 1.5
 )
 
 // Orchestrator manages the podcast playback and interruption flow
 type Orchestrator struct {
-	state      State
-	stateMu    sync.RWMutex
+	state    State
+	stateMu  sync.RWMutex
+	onState  func(State)
+	llm      ai.LLMEngine
+	tts      ai.TTSEngine
+	vad      ai.VADMonitor
+	player   ai.AudioPlayer
+	recorder ai.AudioRecorder
+
+	// the running session; runMu serializes starting and stopping it and
+	// loops tracks its goroutines
+	runMu sync.Mutex
+	loops sync.WaitGroup
+
+	// the session context, cancelled by Stop, and the phases running
+	// under it. Whoever speaks holds the floor; playback waits for it
+	// while paused.
+	phaseMu    sync.Mutex
 	ctx        context.Context
 	cancelFunc context.CancelFunc
-	llm        ai.LLMEngine
-	tts        ai.TTSEngine
-	vad        ai.VADMonitor
-	player     ai.AudioPlayer
-	recorder   ai.AudioRecorder
+	phases     map[*phaseRun]struct{}
+	floorFree  *sync.Cond
+	floorBusy  bool
+	taking     int          // interruptions waiting for the floor
+	paused     *resumePoint // where playback was paused, nil when not paused
+
+	// transcript keeps the timing of everything spoken on one timeline
+	transcript *align.Transcript
+	playMu     sync.Mutex
+	timeline   int64     // timeline position where the next stream starts
+	playBase   int64     // timeline position of the stream being played
+	playEnd    int64     // end of the audio received for the current stream
+	playStart  time.Time // when the current stream started playing
+	playText   string    // text of the current stream
+	playAfter  uint64    // last player stream before the current one
+	sampleRate int
+
+	loudness *audio.LoudnessNormalizer
+
+	// user speech as reported by the VAD
+	vadEvents     <-chan ai.VADEvent
+	minConfidence float32
+	speechMu      sync.Mutex
+	speaking      bool
+	speechStart   ai.VADEvent
+
+	// wake phrase gating, nil interrupts on any speech
+	wake        *wake.Matcher
+	wakeCapture time.Duration
+
+	// soft interruption: duck to duckGain and stop only after softHold of
+	// speech or a question; zero softHold stops on any speech
+	softHold time.Duration
+	duckGain float64
+
+	// answer from partial transcripts when the recorder streams them
+	speculative bool
+
+	// decides when the user's question is over, nil leaves it to the recorder
+	turns *turn.Detector
+
+	// tells questions from backchannels and noise, nil answers everything
+	intents *intent.Classifier
+
+	// spoken playback controls, nil sends everything to the LLM
+	commands *command.Grammar
+
+	// when questions are answered, and those waiting for a break
+	policy  Policy
+	queueMu sync.Mutex
+	queue   []string
+	current *segment // script segment being played, guarded by playMu
+
+	// played while an answer is prepared, nil disables them
+	acks *acknowledgements
+
+	// times playback and interruptions
+	clock ai.Clock
+}
+
+// resumePoint is where an interrupted stream can be picked up again
+type resumePoint struct {
+	rest  string // from the word the listener was hearing
+	whole string // the whole stream, to repeat it
 }
 
 // New creates a new Orchestrator instance
 func New(llm ai.LLMEngine, tts ai.TTSEngine, vad ai.VADMonitor, player ai.AudioPlayer, recorder ai.AudioRecorder) *Orchestrator {
-	return &Orchestrator{
-		state:    IDLE,
-		llm:      llm,
-		tts:      tts,
-		vad:      vad,
-		player:   player,
-		recorder: recorder,
+	o := &Orchestrator{
+		state:       IDLE,
+		llm:         llm,
+		tts:         tts,
+		vad:         vad,
+		player:      player,
+		recorder:    recorder,
+		transcript:  align.NewTranscript(),
+		wakeCapture: defaultWakeCapture,
+		speculative: true,
+		turns:       turn.NewDetector(),
+		intents:     intent.NewClassifier(),
+		commands:    command.NewGrammar(),
+		acks:        newAcknowledgements(defaultAcknowledgements),
+		clock:       ai.SystemClock,
+	}
+	o.floorFree = sync.NewCond(&o.phaseMu)
+	return o
+}
+
+// SetLoudnessNormalizer levels all synthesized audio before playback,
+// nil disables it
+func (o *Orchestrator) SetLoudnessNormalizer(n *audio.LoudnessNormalizer) {
+	o.loudness = n
+}
+
+// SetMinVoiceConfidence ignores speech onsets the VAD reports with a lower
+// probability, so short blips do not interrupt playback
+func (o *Orchestrator) SetMinVoiceConfidence(p float32) {
+	o.minConfidence = p
+}
+
+// SetWakePhrases makes speech interrupt only when it begins with one of
+// the phrases, e.g. "hey host" or "主持人". Playback continues while the
+// start of the speech is transcribed. No phrases disables the gate.
+func (o *Orchestrator) SetWakePhrases(phrases ...string) {
+	if len(phrases) == 0 {
+		o.wake = nil
+		return
 	}
+	o.wake = wake.NewMatcher(phrases...)
+}
+
+// SetWakeCapture sets how long speech is captured to look for the wake phrase
+func (o *Orchestrator) SetWakeCapture(d time.Duration) {
+	o.wakeCapture = d
+}
+
+// SetSoftInterruption makes speech duck playback to gain instead of
+// stopping it. Playback stops only when the speech lasts longer than hold
+// or its transcript is a question, so coughs and "mm-hm" leave it running
+// at full volume. A zero hold stops playback on any speech again. The
+// wake phrase gate, when set, takes precedence.
+func (o *Orchestrator) SetSoftInterruption(hold time.Duration, gain float64) {
+	o.softHold = hold
+	o.duckGain = gain
+}
+
+// SetSpeculativeAnswers controls whether, with a streaming recorder, the
+// answer is generated from a stable partial transcript before the user
+// finishes speaking. It is on by default.
+func (o *Orchestrator) SetSpeculativeAnswers(enabled bool) {
+	o.speculative = enabled
+}
+
+// SetClock sets the clock timing playback and interruptions, the system
+// clock by default. The turn detector has its own, see
+// turn.Detector.SetClock.
+func (o *Orchestrator) SetClock(c ai.Clock) {
+	o.clock = c
+}
+
+// SetTurnDetector sets what decides the user has finished their question.
+// nil records until the recorder's own limit.
+func (o *Orchestrator) SetTurnDetector(d *turn.Detector) {
+	o.turns = d
+}
+
+// SetIntentClassifier sets what decides whether an interruption needs an
+// answer. Backchannels like "yeah" and noise resume playback without one.
+// nil answers everything.
+func (o *Orchestrator) SetIntentClassifier(c *intent.Classifier) {
+	o.intents = c
+}
+
+// SetCommandGrammar sets the phrases recognized as playback controls,
+// such as "pause" or "下一章". They act at once instead of being answered.
+// nil disables voice commands.
+func (o *Orchestrator) SetCommandGrammar(g *command.Grammar) {
+	o.commands = g
+}
+
+// SetStateObserver calls fn with every state the orchestrator enters, in
+// order. fn must not block or call back into the orchestrator.
+func (o *Orchestrator) SetStateObserver(fn func(State)) {
+	o.stateMu.Lock()
+	defer o.stateMu.Unlock()
+	o.onState = fn
+}
+
+// UserSpeaking reports whether the VAD currently hears the user
+func (o *Orchestrator) UserSpeaking() bool {
+	o.speechMu.Lock()
+	defer o.speechMu.Unlock()
+	return o.speaking
+}
+
+// Transcript returns the timed transcript of everything spoken so far
+func (o *Orchestrator) Transcript() *align.Transcript {
+	return o.transcript
 }
 
 // GetState returns the current state (thread-safe)
@@ -46,124 +244,673 @@ func (o *Orchestrator) setState(newState State) {
 	defer o.stateMu.Unlock()
 	log.Printf("[Orchestrator] State transition: %s -> %s", o.state, newState)
 	o.state = newState
+	if o.onState != nil {
+		o.onState(newState)
+	}
 }
 
-// Start begins the podcast with the given topic
-func (o *Orchestrator) Start(topic string) error {
-	o.setState(PLAYING)
+// playbackLoop manages the continuous playback of the podcast. Each
+// segment waits for the floor, so the script carries on after an
+// interruption has been answered and stays put while paused.
+func (o *Orchestrator) playbackLoop(ctx context.Context, scriptStream <-chan paragraph) {
+	for {
+		select {
+		case p, ok := <-scriptStream:
+			if !ok {
+				// Script stream ended
+				log.Println("[Orchestrator] Script stream ended")
+				if o.acquireFloor() {
+					o.answerQueued()
+					o.setState(IDLE)
+					o.releaseFloor()
+				}
+				// The podcast is over; this ends the session
+				o.end()
+				return
+			}
+
+			log.Printf("[Orchestrator] Processing script segment: %s", p.text)
+			if !o.acquireFloor() {
+				log.Println("[Orchestrator] Playback loop cancelled")
+				return
+			}
+
+			// Convert text to audio and play it
+			o.playSegment(p)
+			o.releaseFloor()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	o.ctx = ctx
-	o.cancelFunc = cancel
+		case <-ctx.Done():
+			log.Println("[Orchestrator] Playback loop cancelled")
+			return
+		}
+	}
+}
 
-	// Generate initial podcast script stream
-	scriptStream := o.llm.GenerateStream(ctx, topic)
+// playSegment speaks one script segment. Queued questions are answered
+// after it when the policy makes its end a break, or in the middle when
+// the segment is cut at the end of a sentence; the rest follows the
+// answer.
+func (o *Orchestrator) playSegment(p paragraph) {
+	ctx, end := o.startPhase(o.session(), playbackPhase)
+	defer end()
+	// The voice may have changed since the last segment
+	o.ensureAcknowledgements(o.session())
 
-	// Start playback loop
-	go o.playbackLoop(scriptStream)
+	seg := &segment{cancel: end}
+	o.playMu.Lock()
+	o.current = seg
+	o.playMu.Unlock()
 
-	// Start monitoring for interruptions
-	go o.monitorInterruption()
+	err := o.speak(ctx, p.text)
 
-	return nil
+	o.playMu.Lock()
+	o.current = nil
+	cut, rest := seg.cut, seg.rest
+	o.playMu.Unlock()
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[Orchestrator] Playback error: %v", err)
+	}
+
+	if cut || o.atBreak(p) {
+		o.answerQueued()
+		o.resume(rest)
+	}
 }
 
-// playbackLoop manages the continuous playback of the podcast
-func (o *Orchestrator) playbackLoop(scriptStream <-chan string) {
+// monitorInterruption listens for voice activity and triggers interruption
+// handling. It runs for the whole session.
+func (o *Orchestrator) monitorInterruption(ctx context.Context) {
+	events := o.vad.Start(ctx)
+	o.vadEvents = events
+
 	for {
 		select {
-		case text, ok := <-scriptStream:
+		case ev, ok := <-events:
 			if !ok {
-				// Script stream ended
-				log.Println("[Orchestrator] Script stream ended")
-				o.setState(IDLE)
+				log.Println("[Orchestrator] VAD monitor closed")
 				return
 			}
+			if !o.observeSpeech(ev) {
+				continue
+			}
 
-			log.Printf("[Orchestrator] Processing text chunk: %s", text)
+			if o.wake == nil && o.softHold > 0 {
+				log.Printf("[Orchestrator] Voice detected (p=%.2f), ducking playback", ev.Probability)
+				if question, ok := o.listenSoftly(ev); ok {
+					o.interrupt(ev, question)
+				}
+				continue
+			}
 
-			// Convert text to audio stream
-			audioStream := o.tts.SynthesizeStream(o.ctx, text)
+			if o.wake == nil {
+				log.Printf("[Orchestrator] Voice detected (p=%.2f, %v after onset), triggering interruption", ev.Probability, ev.Delay())
+				o.interrupt(ev, "")
+				continue
+			}
 
-			// Play audio
-			if err := o.player.PlayStream(o.ctx, audioStream); err != nil {
-				log.Printf("[Orchestrator] Playback error: %v", err)
+			log.Printf("[Orchestrator] Voice detected (p=%.2f), listening for wake phrase", ev.Probability)
+			if question, ok := o.listenForWake(); ok {
+				o.interrupt(ev, question)
 			}
 
-		case <-o.ctx.Done():
-			log.Println("[Orchestrator] Playback loop cancelled")
+		case <-ctx.Done():
+			log.Println("[Orchestrator] Monitor interruption cancelled")
 			return
 		}
 	}
 }
 
-// monitorInterruption listens for voice activity and triggers interruption handling
-func (o *Orchestrator) monitorInterruption() {
-	vadSignal := o.vad.Start(o.ctx)
+// observeSpeech tracks whether the user is speaking and passes the event
+// on to the turn detector. It reports whether ev is a speech onset
+// confident enough to act on.
+func (o *Orchestrator) observeSpeech(ev ai.VADEvent) bool {
+	switch ev.Type {
+	case ai.SpeechStart:
+		if ev.Probability < o.minConfidence {
+			log.Printf("[Orchestrator] Ignoring voice blip (p=%.2f)", ev.Probability)
+			return false
+		}
+		o.speechMu.Lock()
+		o.speaking = true
+		o.speechStart = ev
+		o.speechMu.Unlock()
+
+	case ai.SpeechEnd:
+		o.speechMu.Lock()
+		wasSpeaking := o.speaking
+		o.speaking = false
+		start := o.speechStart
+		o.speechMu.Unlock()
+
+		if !wasSpeaking {
+			return false
+		}
+		if ev.SampleRate > 0 {
+			duration := time.Duration(ev.Offset-start.Offset) * time.Second / time.Duration(ev.SampleRate)
+			log.Printf("[Orchestrator] User stopped speaking after %v", duration)
+		}
+	}
+
+	if o.turns != nil {
+		o.turns.Speech(ev)
+	}
+	return ev.Type == ai.SpeechStart
+}
+
+// listenForWake transcribes the start of the speech while playback goes
+// on, and reports whether it began with a wake phrase together with
+// anything said after it
+func (o *Orchestrator) listenForWake() (string, bool) {
+	listen, end := o.startPhase(o.session(), listeningPhase)
+	defer end()
+	capture, cancel := context.WithTimeout(listen, o.wakeCapture)
+	defer cancel()
 
+	heard, err := o.recorder.Record(ai.WithCapture(listen, capture))
+	if err != nil {
+		if listen.Err() == nil {
+			log.Printf("[Orchestrator] Wake phrase capture error: %v", err)
+		}
+		return "", false
+	}
+
+	match, ok := o.wake.Match(heard)
+	if !ok {
+		log.Printf("[Orchestrator] No wake phrase in %q, continuing playback", heard)
+		return "", false
+	}
+	log.Printf("[Orchestrator] Wake phrase %q heard, triggering interruption", match.Phrase)
+	return match.Remainder, true
+}
+
+// listenSoftly ducks playback while the listener speaks and reports
+// whether the speech interrupts: it does when it lasts longer than the
+// hold time or its transcript is a question, which is returned. Otherwise
+// playback goes back to full volume.
+func (o *Orchestrator) listenSoftly(onset ai.VADEvent) (string, bool) {
+	o.duck(o.duckGain)
+
+	session := o.session()
+	ctx, cancel := o.startPhase(session, listeningPhase)
+	defer cancel()
+	capture, stopCapture := context.WithCancel(ctx)
+	defer stopCapture()
+
+	type result struct {
+		text string
+		err  error
+	}
+	recorded := make(chan result, 1)
+	go func() {
+		text, err := o.recorder.Record(ai.WithCapture(ctx, capture))
+		recorded <- result{text, err}
+	}()
+
+	hold := o.clock.After(o.softHold - onset.Delay() - o.clock.Now().Sub(onset.Time))
+
+	events := o.vadEvents
 	for {
 		select {
-		case _, ok := <-vadSignal:
+		case <-hold:
+			// The question is recorded again after playback stops; the
+			// recorder's pre-roll keeps its start
+			log.Printf("[Orchestrator] Listener spoke for over %v, triggering interruption", o.softHold)
+			return "", true
+
+		case ev, ok := <-events:
 			if !ok {
-				log.Println("[Orchestrator] VAD monitor closed")
-				return
+				events = nil
+				continue
+			}
+			o.observeSpeech(ev)
+			if ev.Type == ai.SpeechEnd && !o.UserSpeaking() {
+				// Stopping the capture transcribes what was said
+				stopCapture()
 			}
-			log.Println("[Orchestrator] Voice detected, triggering interruption")
-			o.handleInterruption()
 
-		case <-o.ctx.Done():
-			log.Println("[Orchestrator] Monitor interruption cancelled")
-			return
+		case r := <-recorded:
+			if r.err != nil && session.Err() == nil {
+				log.Printf("[Orchestrator] Soft interruption capture error: %v", r.err)
+			}
+			// Voice changes apply to what is synthesized next, so
+			// playback need not stop for them
+			if cmd, ok := o.matchCommand(r.text); ok && o.adjustVoice(cmd.Action) {
+				log.Printf("[Orchestrator] Command %s (%q), continuing playback", cmd.Action, cmd.Phrase)
+				o.duck(1)
+				return "", false
+			}
+			if kind := o.classify(session, r.text); kind.Answered() {
+				log.Printf("[Orchestrator] Heard %s %q, triggering interruption", kind, r.text)
+				return r.text, true
+			}
+			log.Printf("[Orchestrator] Heard %q, continuing playback", r.text)
+			o.duck(1)
+			return "", false
+
+		case <-session.Done():
+			return "", false
 		}
 	}
 }
 
-// handleInterruption processes user interruption and generates response
-func (o *Orchestrator) handleInterruption() {
+// duck sets the playback volume, if the player supports it
+func (o *Orchestrator) duck(gain float64) {
+	if p, ok := o.player.(ai.DuckingPlayer); ok {
+		p.Duck(gain, duckRamp)
+	}
+}
+
+// handleInterruption processes user interruption and generates response.
+// question is what the user already asked, if anything; otherwise it is
+// recorded after playback stops. Only the playback and answering phases
+// are cancelled; the session and its monitor carry on.
+func (o *Orchestrator) handleInterruption(onset ai.VADEvent, question string) {
 	o.setState(INTERRUPTED)
 
+	// Find out how far the listener got before stopping playback
+	heard := o.heardSample()
+	if word := o.transcript.WordAt(heard); word >= 0 && o.aligned(heard) {
+		log.Printf("[Orchestrator] Interrupted at word %d (%q), %v into the stream",
+			word, o.transcript.Words()[word].Text, o.streamElapsed(heard))
+	} else {
+		log.Printf("[Orchestrator] Interrupted %v into the stream, ahead of its timing", o.streamElapsed(heard))
+	}
+	at := o.resumePointAt(heard)
+	if paused := o.pausedAt(); paused != nil {
+		at = *paused
+	}
+
 	// Stop current playback immediately
 	log.Println("[Orchestrator] Stopping current playback")
-	o.cancelFunc()
-	o.player.Stop()
+	release := o.takeFloor()
+	defer release()
+	log.Printf("[Orchestrator] Playback stopped %v after speech onset", onset.Delay()+o.clock.Now().Sub(onset.Time))
+
+	// Answer at full volume after a soft interruption
+	o.duck(1)
+
+	ctx, end := o.startPhase(o.session(), answeringPhase)
+	defer end()
 
 	// Record user question
-	log.Println("[Orchestrator] Recording user question")
 	o.setState(THINKING)
-	question, err := o.recorder.Record(o.ctx)
-	if err != nil {
-		log.Printf("[Orchestrator] Recording error: %v", err)
-		o.setState(PLAYING)
+	heardText := o.transcript.HeardText(heard)
+	var responseStream <-chan string
+	if question == "" {
+		log.Println("[Orchestrator] Recording user question")
+		var release func()
+		var err error
+		question, responseStream, release, err = o.recordQuestion(ctx, heardText, o.speculative)
+		if release != nil {
+			defer release()
+		}
+		if err != nil {
+			log.Printf("[Orchestrator] Recording error: %v", err)
+			o.setState(PLAYING)
+			return
+		}
+	}
+
+	// Playback controls act at once, without the LLM
+	if cmd, ok := o.matchCommand(question); ok {
+		o.runCommand(cmd, at)
 		return
 	}
 
+	// Backchannels and noise need no answer; carry on where the listener was
+	if kind := o.classify(ctx, question); !kind.Answered() {
+		log.Printf("[Orchestrator] User said %q (%s), not answering", question, kind)
+		o.carryOn(at)
+		return
+	}
 	log.Printf("[Orchestrator] User question: %s", question)
 
-	// Generate response stream
-	responseStream := o.llm.GenerateResponse(o.ctx, question, "")
+	// Generate response stream, unless it was started while recording,
+	// and speak it in whole sentences
+	if responseStream == nil {
+		responseStream = o.llm.GenerateResponse(ctx, question, heardText)
+	}
+	responseStream = o.acknowledge(ctx, question, textnorm.Sentences(ctx, responseStream))
 
 	// Play response
 	for text := range responseStream {
 		log.Printf("[Orchestrator] Response chunk: %s", text)
 
-		audioStream := o.tts.SynthesizeStream(o.ctx, text)
-		if err := o.player.PlayStream(o.ctx, audioStream); err != nil {
+		if err := o.speak(ctx, text); err != nil && ctx.Err() == nil {
 			log.Printf("[Orchestrator] Response playback error: %v", err)
 		}
 	}
 
 	// Resume or update remaining script
+	log.Println("[Orchestrator] Response completed")
+	o.carryOn(at)
+}
+
+// matchCommand recognizes a playback control in what the user said
+func (o *Orchestrator) matchCommand(text string) (command.Command, bool) {
+	if o.commands == nil {
+		return command.Command{}, false
+	}
+	return o.commands.Match(text)
+}
+
+// runCommand carries out a playback control. at is where playback was
+// interrupted.
+func (o *Orchestrator) runCommand(cmd command.Command, at resumePoint) {
+	log.Printf("[Orchestrator] Command %s (%q)", cmd.Action, cmd.Phrase)
+	switch cmd.Action {
+	case command.Pause:
+		o.setPaused(&at)
+		o.setState(PAUSED)
+	case command.Resume:
+		o.setPaused(nil)
+		o.carryOn(at)
+	case command.Skip:
+		// The rest of the interrupted paragraph is dropped
+		o.setPaused(nil)
+		o.setState(PLAYING)
+	case command.Repeat:
+		// From the start of the interrupted paragraph
+		o.setPaused(nil)
+		o.setState(PLAYING)
+		o.resume(at.whole)
+	case command.Stop:
+		o.setPaused(nil)
+		o.setState(IDLE)
+		o.end()
+	default:
+		o.adjustVoice(cmd.Action)
+		o.carryOn(at)
+	}
+}
+
+// adjustVoice changes the speech speed or volume for a voice command and
+// reports whether action was one. The change applies to speech
+// synthesized from now on; engines without the setting ignore it.
+func (o *Orchestrator) adjustVoice(action command.Action) bool {
+	switch action {
+	case command.Slower, command.Faster:
+		tts, ok := o.tts.(interface {
+			Speed() float64
+			SetSpeed(float64)
+		})
+		if !ok {
+			log.Printf("[Orchestrator] TTS engine has no speed setting")
+			return true
+		}
+		step := speedStep
+		if action == command.Slower {
+			step = -step
+		}
+		tts.SetSpeed(tts.Speed() + step)
+		log.Printf("[Orchestrator] Speech speed set to %.2f", tts.Speed())
+
+	case command.Louder, command.Quieter:
+		tts, ok := o.tts.(interface {
+			Volume() float64
+			SetVolume(float64)
+		})
+		if !ok {
+			log.Printf("[Orchestrator] TTS engine has no volume setting")
+			return true
+		}
+		factor := volumeFactor
+		if action == command.Quieter {
+			factor = 1 / factor
+		}
+		tts.SetVolume(tts.Volume() * factor)
+		log.Printf("[Orchestrator] Speech volume set to %.2f", tts.Volume())
+
+	default:
+		return false
+	}
+	return true
+}
+
+// carryOn goes back to playing from where the listener was, unless they
+// paused playback
+func (o *Orchestrator) carryOn(at resumePoint) {
+	if o.pausedAt() != nil {
+		o.setState(PAUSED)
+		return
+	}
 	o.setState(PLAYING)
-	log.Println("[Orchestrator] Response completed, resuming playback")
+	o.resume(at.rest)
+}
+
+// classify labels what the user said, treating everything as a question
+// without a classifier. Commands are those of the orchestrator's grammar,
+// so with voice commands disabled "pause" is answered rather than dropped.
+func (o *Orchestrator) classify(ctx context.Context, text string) intent.Kind {
+	if o.intents == nil {
+		return intent.Question
+	}
+	return o.intents.Classify(ctx, text, o.commands)
+}
+
+// resume speaks the rest of the interrupted segment, if any. It is part of
+// the script, so it plays in a playback phase.
+func (o *Orchestrator) resume(text string) {
+	if text == "" {
+		return
+	}
+	ctx, end := o.startPhase(o.session(), playbackPhase)
+	defer end()
+	log.Printf("[Orchestrator] Resuming interrupted segment: %s", text)
+	if err := o.speak(ctx, text); err != nil && ctx.Err() == nil {
+		log.Printf("[Orchestrator] Resume playback error: %v", err)
+	}
+}
+
+// resumePointAt returns where to pick up the current stream when the
+// listener interrupted at heard
+func (o *Orchestrator) resumePointAt(heard int64) resumePoint {
+	o.playMu.Lock()
+	base := o.playBase
+	o.playMu.Unlock()
+	return resumePoint{rest: o.unheardText(heard), whole: o.unheardText(base)}
+}
+
+// unheardText returns what the listener had not heard yet of the stream
+// being played, from the word at heard on. TTS engines report timing
+// once audio is complete, so the listener may be hearing text without
+// segments yet; that is resumed from the start of its sentence.
+func (o *Orchestrator) unheardText(heard int64) string {
+	o.playMu.Lock()
+	playing, base, text := !o.playStart.IsZero(), o.playBase, o.playText
+	o.playMu.Unlock()
+
+	if !playing {
+		return ""
+	}
+	rest := o.transcript.RemainingText(base, heard)
+	if pending := o.pendingText(text, base); pending != "" {
+		rest = joinSentences(rest, pending)
+	}
+	return rest
+}
+
+// pendingText returns the sentences of text, the stream starting at
+// timeline position base, that no segment covers yet
+func (o *Orchestrator) pendingText(text string, base int64) string {
+	covered := 0
+	for _, seg := range o.transcript.Segments() {
+		if seg.StartSample >= base {
+			covered += len(align.SplitSentences(seg.Text))
+		}
+	}
+	sentences := align.SplitSentences(text)
+	if covered >= len(sentences) {
+		return ""
+	}
+	return text[sentences[covered].Start:]
+}
+
+// aligned reports whether the transcript covers timeline position heard
+// of the current stream
+func (o *Orchestrator) aligned(heard int64) bool {
+	o.playMu.Lock()
+	defer o.playMu.Unlock()
+	return heard < o.playEnd
+}
+
+// streamElapsed converts a timeline position into the time since the
+// start of the current stream
+func (o *Orchestrator) streamElapsed(sample int64) time.Duration {
+	o.playMu.Lock()
+	defer o.playMu.Unlock()
+	return align.SampleTime(sample-o.playBase, o.sampleRate)
+}
+
+// recordQuestion records the user's question until the turn detector
+// decides it is over. The monitor loop is busy with this interruption, so
+// VAD events are read here meanwhile to keep the detector informed. With
+// speculate and a streaming recorder the answer may already be under way;
+// responses is nil otherwise.
+func (o *Orchestrator) recordQuestion(ctx context.Context, heardText string, speculate bool) (question string, responses <-chan string, release func(), err error) {
+	listen, end := o.startPhase(ctx, listeningPhase)
+	defer end()
+	capture, endTurn := listen, context.CancelFunc(func() {})
+	if o.turns != nil {
+		capture, endTurn = o.turns.Watch(listen)
+	}
+	defer endTurn()
+	record := ai.WithCapture(listen, capture)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if rec, ok := o.recorder.(ai.StreamingRecorder); ok && speculate {
+			question, responses, release, err = o.askStreaming(ctx, record, rec, heardText)
+		} else {
+			question, err = o.recorder.Record(record)
+		}
+	}()
+
+	events := o.vadEvents
+	for {
+		select {
+		case <-done:
+			return question, responses, release, err
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			o.observeSpeech(ev)
+		}
+	}
+}
+
+// speak synthesizes and plays text. When the TTS engine reports timing,
+// the segments are added to the transcript while the audio plays.
+func (o *Orchestrator) speak(ctx context.Context, text string) error {
+	return o.play(ctx, o.synthesize(ctx, text))
 }
 
-// Stop stops the orchestrator
-func (o *Orchestrator) Stop() {
-	log.Println("[Orchestrator] Stopping orchestrator")
-	if o.cancelFunc != nil {
-		o.cancelFunc()
+// synthesis is speech being synthesized; segments is nil when the TTS
+// engine does not report timing
+type synthesis struct {
+	text     string
+	audio    <-chan []byte
+	segments <-chan ai.Segment
+}
+
+// synthesize starts synthesizing text without playing it
+func (o *Orchestrator) synthesize(ctx context.Context, text string) synthesis {
+	aligned, ok := o.tts.(ai.AlignedTTSEngine)
+	if !ok {
+		return synthesis{text: text, audio: o.level(ctx, o.tts.SynthesizeStream(ctx, text))}
+	}
+	audioStream, segments := aligned.SynthesizeAligned(ctx, text)
+	return synthesis{text: text, audio: o.level(ctx, audioStream), segments: segments}
+}
+
+// play plays synthesized speech, adding its segments to the transcript
+func (o *Orchestrator) play(ctx context.Context, s synthesis) error {
+	if s.segments == nil {
+		return o.player.PlayStream(ctx, s.audio)
+	}
+	audioStream, segments := s.audio, s.segments
+
+	o.playMu.Lock()
+	base := o.timeline
+	o.playBase = base
+	o.playEnd = base
+	o.playStart = o.clock.Now()
+	o.playText = s.text
+	if pp, ok := o.player.(ai.PositionedPlayer); ok {
+		o.playAfter = pp.Position().StreamID
+	}
+	o.playMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for seg := range segments {
+			o.transcript.Add(seg, base)
+
+			o.playMu.Lock()
+			o.playEnd = base + seg.EndSample
+			o.sampleRate = seg.SampleRate
+			o.playMu.Unlock()
+		}
+	}()
+
+	err := o.player.PlayStream(ctx, audioStream)
+	<-done
+
+	// When interrupted, only the part that was heard counts on the timeline
+	heard := o.heardSample()
+	o.playMu.Lock()
+	if ctx.Err() != nil {
+		o.timeline = heard
+	} else {
+		o.timeline = o.playEnd
+	}
+	o.playStart = time.Time{}
+	o.playMu.Unlock()
+	return err
+}
+
+// level runs the audio through the loudness normalizer, if one is set
+func (o *Orchestrator) level(ctx context.Context, audioStream <-chan []byte) <-chan []byte {
+	if o.loudness == nil {
+		return audioStream
+	}
+
+	// Gain is remembered per voice so segments from the same host stay consistent
+	return o.loudness.Process(ctx, o.voice(), audioStream)
+}
+
+// voice returns the TTS voice, if the engine has a choice of voices
+func (o *Orchestrator) voice() string {
+	if v, ok := o.tts.(interface{ Voice() string }); ok {
+		return v.Voice()
+	}
+	return ""
+}
+
+// heardSample returns the timeline position the listener has reached. A
+// player that reports its position gives it exactly; otherwise it is
+// estimated from the time elapsed since the current stream started.
+func (o *Orchestrator) heardSample() int64 {
+	o.playMu.Lock()
+	defer o.playMu.Unlock()
+
+	if o.playStart.IsZero() || o.sampleRate == 0 {
+		return o.timeline
+	}
+	heard := o.playBase + int64(o.clock.Now().Sub(o.playStart).Seconds()*float64(o.sampleRate))
+	if pp, ok := o.player.(ai.PositionedPlayer); ok {
+		heard = o.playBase
+		// A position from an earlier stream means this one has not started
+		if pos := pp.Position(); pos.StreamID > o.playAfter && pos.SampleRate > 0 {
+			heard += pos.Samples * int64(o.sampleRate) / int64(pos.SampleRate)
+		}
+	}
+	if heard > o.playEnd {
+		heard = o.playEnd
 	}
-	o.player.Stop()
-	o.vad.Stop()
-	o.setState(IDLE)
+	return heard
 }
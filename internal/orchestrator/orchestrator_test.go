@@ -0,0 +1,238 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// fakePositionedPlayer 报告固定的播放位置
+type fakePositionedPlayer struct {
+	pos ai.PlaybackPosition
+}
+
+func (f *fakePositionedPlayer) PlayStream(ctx context.Context, audioStream <-chan []byte) error {
+	for range audioStream {
+	}
+	return nil
+}
+
+func (f *fakePositionedPlayer) Stop() error { return nil }
+
+func (f *fakePositionedPlayer) Position() ai.PlaybackPosition { return f.pos }
+
+func (f *fakePositionedPlayer) SetPositionCallback(time.Duration, func(ai.PlaybackPosition)) {}
+
+func TestHeardSample_PlayerPosition(t *testing.T) {
+	player := &fakePositionedPlayer{}
+	o := New(nil, nil, nil, player, nil)
+
+	const text = "Hello there, how are you today?"
+	o.transcript.Add(ai.Segment{Text: text, StartSample: 0, EndSample: 24000, SampleRate: 24000}, 1000)
+	o.playBase, o.playEnd, o.sampleRate = 1000, 25000, 24000
+	o.playStart = time.Now().Add(-900 * time.Millisecond)
+	o.playAfter = 3
+
+	// 播放器仍报告上一段流时，本段还没开始播放
+	player.pos = ai.PlaybackPosition{StreamID: 3, Samples: 20000, SampleRate: 24000}
+	if got := o.heardSample(); got != 1000 {
+		t.Errorf("heardSample() = %d before the stream started, want 1000", got)
+	}
+	if got := o.unheardText(o.heardSample()); got != text {
+		t.Errorf("unheardText() = %q, want the whole segment", got)
+	}
+
+	// 位置按段落采样率换算，而不是按经过的时间估算
+	player.pos = ai.PlaybackPosition{StreamID: 4, Samples: 24000, SampleRate: 48000, Playing: true}
+	heard := o.heardSample()
+	if heard != 13000 {
+		t.Fatalf("heardSample() = %d, want 13000", heard)
+	}
+	if d := o.streamElapsed(heard); d != 500*time.Millisecond {
+		t.Errorf("streamElapsed() = %v, want 500ms", d)
+	}
+
+	word := o.transcript.Words()[o.transcript.WordAt(heard)]
+	if got, want := o.unheardText(heard), text[word.Start:]; got != want {
+		t.Errorf("unheardText() = %q, want %q from the word being spoken", got, want)
+	}
+}
+
+func TestUnheardText_PendingSegments(t *testing.T) {
+	player := &fakePositionedPlayer{}
+	o := New(nil, nil, nil, player, nil)
+
+	// 第一句的对齐已到，后两句的音频正在播放但对齐还没到
+	const text = "One two. Three four. Five six."
+	o.transcript.Add(ai.Segment{Text: "One two.", End: 8, StartSample: 0, EndSample: 24000, SampleRate: 24000}, 1000)
+	o.playBase, o.playEnd, o.sampleRate = 1000, 25000, 24000
+	o.playStart, o.playText = time.Now(), text
+	player.pos = ai.PlaybackPosition{StreamID: 1, Samples: 36000, SampleRate: 24000, Playing: true}
+
+	heard := o.heardSample()
+	if o.aligned(heard) {
+		t.Errorf("heard %d counted as aligned past the segments", heard)
+	}
+	if got, want := o.unheardText(heard), "Three four. Five six."; got != want {
+		t.Errorf("unheardText() past the segments = %q, want %q", got, want)
+	}
+	// 在已对齐的部分中打断，未对齐的句子接在后面
+	word := o.transcript.Words()[1]
+	if got, want := o.unheardText(word.StartSample), "two. Three four. Five six."; got != want {
+		t.Errorf("unheardText() = %q, want %q", got, want)
+	}
+}
+
+// duckingPlayer 记录每次 Duck 的音量
+type duckingPlayer struct {
+	fakePositionedPlayer
+	mu    sync.Mutex
+	gains []float64
+}
+
+func (d *duckingPlayer) Duck(gain float64, ramp time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.gains = append(d.gains, gain)
+}
+
+// textRecorder 录音直到采集结束，然后返回固定文本
+type textRecorder struct {
+	text string
+}
+
+func (r textRecorder) Record(ctx context.Context) (string, error) {
+	<-ai.CaptureContext(ctx).Done()
+	return r.text, ctx.Err()
+}
+
+func TestListenSoftly(t *testing.T) {
+	tests := []struct {
+		name         string
+		heard        string
+		speech       time.Duration // 0 表示一直在说
+		wantQuestion string
+		wantStop     bool
+		wantGains    []float64
+	}{
+		{"backchannel", "mm-hm", 20 * time.Millisecond, "", false, []float64{0.2, 1}},
+		{"cough", "", 20 * time.Millisecond, "", false, []float64{0.2, 1}},
+		{"question", "why?", 20 * time.Millisecond, "why?", true, []float64{0.2}},
+		{"long speech", "so I was thinking", 0, "", true, []float64{0.2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			player := &duckingPlayer{}
+			o := New(&fakeLLM{}, nil, nil, player, textRecorder{tt.heard})
+			o.SetSoftInterruption(100*time.Millisecond, 0.2)
+
+			events := make(chan ai.VADEvent, 1)
+			o.vadEvents = events
+			onset := ai.VADEvent{Type: ai.SpeechStart, Time: time.Now(), Probability: 1}
+			o.observeSpeech(onset)
+			if tt.speech > 0 {
+				time.AfterFunc(tt.speech, func() {
+					events <- ai.VADEvent{Type: ai.SpeechEnd, Time: time.Now()}
+				})
+			}
+
+			start := time.Now()
+			question, stop := o.listenSoftly(onset)
+			if question != tt.wantQuestion || stop != tt.wantStop {
+				t.Errorf("listenSoftly() = %q, %v, want %q, %v", question, stop, tt.wantQuestion, tt.wantStop)
+			}
+			if tt.speech == 0 {
+				if d := time.Since(start); d < 90*time.Millisecond {
+					t.Errorf("stopped after %v, before the hold time", d)
+				}
+			}
+			if !reflect.DeepEqual(player.gains, tt.wantGains) {
+				t.Errorf("volume set to %v, want %v", player.gains, tt.wantGains)
+			}
+		})
+	}
+}
+
+func TestHandleInterruption_Backchannel(t *testing.T) {
+	for _, said := range []string{"对对对", "Uh-huh.", "(coughs)"} {
+		llm := &fakeLLM{}
+		o := New(llm, nil, nil, &fakePositionedPlayer{}, nil)
+
+		// 附和与噪声不应触发回答
+		o.handleInterruption(ai.VADEvent{Type: ai.SpeechStart, Time: time.Now()}, said)
+		if len(llm.questions) != 0 {
+			t.Errorf("%q was answered as %q", said, llm.questions)
+		}
+		if s := o.GetState(); s != PLAYING {
+			t.Errorf("state %s after %q, want PLAYING", s, said)
+		}
+	}
+}
+
+// voiceTTS 只记录语速和音量
+type voiceTTS struct {
+	speed, volume float64
+}
+
+func (v *voiceTTS) SynthesizeStream(ctx context.Context, text string) <-chan []byte {
+	ch := make(chan []byte)
+	close(ch)
+	return ch
+}
+
+func (v *voiceTTS) Speed() float64         { return v.speed }
+func (v *voiceTTS) SetSpeed(speed float64) { v.speed = speed }
+func (v *voiceTTS) Volume() float64        { return v.volume }
+func (v *voiceTTS) SetVolume(vol float64)  { v.volume = vol }
+
+func TestHandleInterruption_Commands(t *testing.T) {
+	llm := &answerLLM{}
+	tts := &voiceTTS{speed: 1, volume: 1}
+	o := New(llm, tts, nil, &fakePositionedPlayer{}, nil)
+	interrupt := func(said string) {
+		o.handleInterruption(ai.VADEvent{Type: ai.SpeechStart, Time: time.Now()}, said)
+	}
+
+	interrupt("slower please")
+	interrupt("大声一点")
+	if tts.speed != 0.75 || tts.volume != 1.5 {
+		t.Errorf("speed %.2f, volume %.2f after slower and louder", tts.speed, tts.volume)
+	}
+
+	// 暂停后提问仍保持暂停，直到说"继续"
+	interrupt("暂停")
+	if s := o.GetState(); s != PAUSED {
+		t.Fatalf("state %s after pause", s)
+	}
+	interrupt("why is that?")
+	if s := o.GetState(); s != PAUSED {
+		t.Errorf("state %s after a question while paused", s)
+	}
+	interrupt("继续")
+	if s := o.GetState(); s != PLAYING {
+		t.Errorf("state %s after resume", s)
+	}
+
+	if len(llm.questions) != 1 || llm.questions[0] != "why is that?" {
+		t.Errorf("LLM asked %q, want only the question", llm.questions)
+	}
+}
+
+func TestHandleInterruption_CommandsDisabled(t *testing.T) {
+	// 关闭语音命令后，"pause" 当作问题回答，而不是被丢掉
+	llm := &answerLLM{}
+	o := New(llm, &voiceTTS{speed: 1, volume: 1}, nil, &fakePositionedPlayer{}, nil)
+	o.SetCommandGrammar(nil)
+
+	o.handleInterruption(ai.VADEvent{Type: ai.SpeechStart, Time: time.Now()}, "pause")
+	if s := o.GetState(); s != PLAYING {
+		t.Errorf("state %s, want PLAYING", s)
+	}
+	if len(llm.questions) != 1 || llm.questions[0] != "pause" {
+		t.Errorf("LLM asked %q, want the utterance answered", llm.questions)
+	}
+}
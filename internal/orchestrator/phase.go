@@ -0,0 +1,158 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+)
+
+// phase is a part of the session that is cancelled on its own: stopping
+// playback for a question must not stop the monitor listening for the
+// next one
+type phase int
+
+const (
+	playbackPhase  phase = iota // speaking the script
+	listeningPhase              // recording the listener
+	answeringPhase              // answering the listener
+)
+
+func (p phase) String() string {
+	switch p {
+	case playbackPhase:
+		return "playback"
+	case listeningPhase:
+		return "listening"
+	case answeringPhase:
+		return "answering"
+	default:
+		return "unknown"
+	}
+}
+
+// phaseRun is one running instance of a phase
+type phaseRun struct {
+	phase  phase
+	cancel context.CancelFunc
+}
+
+// session returns the context of the running session, which Stop cancels.
+// Before Start it is a background context, so parts of the flow can run
+// on their own.
+func (o *Orchestrator) session() context.Context {
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	if o.ctx == nil {
+		return context.Background()
+	}
+	return o.ctx
+}
+
+// startPhase returns a context for running phase p under parent, which is
+// cancelled by cancelPhase(p) as well as with parent. end must be called
+// when the phase is over. While the floor is being taken, playback and
+// answering phases start already cancelled.
+func (o *Orchestrator) startPhase(parent context.Context, p phase) (ctx context.Context, end func()) {
+	ctx, cancel := context.WithCancel(parent)
+	run := &phaseRun{phase: p, cancel: cancel}
+
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	if o.taking > 0 && p != listeningPhase {
+		cancel()
+		return ctx, cancel
+	}
+	if o.phases == nil {
+		o.phases = make(map[*phaseRun]struct{})
+	}
+	o.phases[run] = struct{}{}
+	return ctx, func() {
+		o.phaseMu.Lock()
+		delete(o.phases, run)
+		o.phaseMu.Unlock()
+		cancel()
+	}
+}
+
+// cancelPhase cancels every running instance of phase p
+func (o *Orchestrator) cancelPhase(p phase) {
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	o.cancelPhaseLocked(p)
+}
+
+func (o *Orchestrator) cancelPhaseLocked(p phase) {
+	for run := range o.phases {
+		if run.phase == p {
+			run.cancel()
+			delete(o.phases, run)
+		}
+	}
+}
+
+// takeFloor stops the script and any answer being spoken, and waits until
+// nothing else plays. The caller speaks next and must call the returned
+// release when done, so the script carries on.
+func (o *Orchestrator) takeFloor() (release func()) {
+	o.phaseMu.Lock()
+	o.taking++
+	o.cancelPhaseLocked(playbackPhase)
+	o.cancelPhaseLocked(answeringPhase)
+	o.phaseMu.Unlock()
+
+	o.player.Stop()
+
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	for o.floorBusy {
+		o.floorFree.Wait()
+	}
+	o.floorBusy = true
+	o.taking--
+	return o.releaseFloor
+}
+
+// acquireFloor waits until nothing else plays and playback is not paused,
+// for the next script segment. Interruptions waiting for the floor go
+// first. It reports false when the session ends.
+func (o *Orchestrator) acquireFloor() bool {
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	logged := false
+	for o.floorBusy || o.taking > 0 || o.paused != nil {
+		if o.ctx != nil && o.ctx.Err() != nil {
+			return false
+		}
+		if o.paused != nil && !logged {
+			log.Println("[Orchestrator] Playback paused, waiting to resume")
+			logged = true
+		}
+		o.floorFree.Wait()
+	}
+	if o.ctx != nil && o.ctx.Err() != nil {
+		return false
+	}
+	o.floorBusy = true
+	return true
+}
+
+func (o *Orchestrator) releaseFloor() {
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	o.floorBusy = false
+	o.floorFree.Broadcast()
+}
+
+// setPaused pauses playback at at, or resumes it when at is nil
+func (o *Orchestrator) setPaused(at *resumePoint) {
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	o.paused = at
+	o.floorFree.Broadcast()
+}
+
+// pausedAt returns where playback was paused, nil when it is not
+func (o *Orchestrator) pausedAt() *resumePoint {
+	o.phaseMu.Lock()
+	defer o.phaseMu.Unlock()
+	return o.paused
+}
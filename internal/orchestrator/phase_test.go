@@ -0,0 +1,232 @@
+package orchestrator
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+func TestPhases_CancelIndependently(t *testing.T) {
+	o := New(nil, nil, &chanVAD{}, &fakePositionedPlayer{}, nil)
+	o.ctx, o.cancelFunc = context.WithCancel(context.Background())
+
+	playback, endPlayback := o.startPhase(o.session(), playbackPhase)
+	defer endPlayback()
+	answering, endAnswering := o.startPhase(o.session(), answeringPhase)
+	defer endAnswering()
+	listening, endListening := o.startPhase(answering, listeningPhase)
+	defer endListening()
+
+	// 取消播放阶段不影响会话和其他阶段
+	o.cancelPhase(playbackPhase)
+	if playback.Err() == nil {
+		t.Error("playback phase not cancelled")
+	}
+	if o.session().Err() != nil || answering.Err() != nil || listening.Err() != nil {
+		t.Error("cancelling playback cancelled other phases")
+	}
+
+	// 停止会话结束所有阶段
+	o.Stop()
+	if answering.Err() == nil || listening.Err() == nil {
+		t.Error("phases still running after Stop")
+	}
+}
+
+// scriptLLM 逐段给出脚本，每段单独一行，回答时复述问题。deltas 为真时
+// chunks 是原样发送的 token 片段
+type scriptLLM struct {
+	chunks []string
+	deltas bool
+
+	mu        sync.Mutex
+	questions []string
+}
+
+func (s *scriptLLM) GenerateStream(ctx context.Context, prompt string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, c := range s.chunks {
+			if !s.deltas {
+				c += "\n"
+			}
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func (s *scriptLLM) GenerateResponse(ctx context.Context, question, context string) <-chan string {
+	s.mu.Lock()
+	s.questions = append(s.questions, question)
+	s.mu.Unlock()
+	ch := make(chan string, 1)
+	ch <- "answer: " + question
+	close(ch)
+	return ch
+}
+
+// textTTS 把文本本身当作音频
+type textTTS struct{}
+
+func (textTTS) SynthesizeStream(ctx context.Context, text string) <-chan []byte {
+	ch := make(chan []byte, 1)
+	ch <- []byte(text)
+	close(ch)
+	return ch
+}
+
+// played 是假播放器播放过的一段
+type played struct {
+	text        string
+	interrupted bool
+}
+
+// timedPlayer 每段播放固定时长，可被 Stop 或 ctx 打断
+type timedPlayer struct {
+	length  time.Duration
+	started chan string
+
+	mu   sync.Mutex
+	stop chan struct{}
+	log  []played
+}
+
+func newTimedPlayer(length time.Duration) *timedPlayer {
+	return &timedPlayer{length: length, started: make(chan string, 16)}
+}
+
+func (p *timedPlayer) PlayStream(ctx context.Context, audioStream <-chan []byte) error {
+	var text []byte
+	for chunk := range audioStream {
+		text = append(text, chunk...)
+	}
+	p.mu.Lock()
+	stop := make(chan struct{})
+	p.stop = stop
+	p.mu.Unlock()
+	p.started <- string(text)
+
+	timer := time.NewTimer(p.length)
+	defer timer.Stop()
+	interrupted := true
+	var err error
+	select {
+	case <-timer.C:
+		interrupted = false
+	case <-stop:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	p.log = append(p.log, played{string(text), interrupted})
+	p.mu.Unlock()
+	return err
+}
+
+func (p *timedPlayer) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		close(p.stop)
+		p.stop = nil
+	}
+	return nil
+}
+
+func (p *timedPlayer) played() []played {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]played(nil), p.log...)
+}
+
+// chanVAD 由测试发送语音事件
+type chanVAD struct {
+	events chan ai.VADEvent
+}
+
+func (v *chanVAD) Start(ctx context.Context) <-chan ai.VADEvent {
+	return v.events
+}
+
+func (v *chanVAD) Stop() error { return nil }
+
+// fixedRecorder 立即返回固定的问题
+type fixedRecorder struct {
+	text string
+}
+
+func (r fixedRecorder) Record(ctx context.Context) (string, error) {
+	return r.text, ctx.Err()
+}
+
+func TestSession_ContinuesAfterInterruption(t *testing.T) {
+	llm := &scriptLLM{chunks: []string{"one", "two", "three"}}
+	player := newTimedPlayer(200 * time.Millisecond)
+	vad := &chanVAD{events: make(chan ai.VADEvent)}
+	o := New(llm, textTTS{}, vad, player, fixedRecorder{"why?"})
+	o.SetTurnDetector(nil)
+	o.SetAcknowledgements()
+
+	waitFor := func(want string) {
+		t.Helper()
+		select {
+		case got := <-player.started:
+			if got != want {
+				t.Fatalf("played %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%q never played", want)
+		}
+	}
+	interrupt := func() {
+		vad.events <- ai.VADEvent{Type: ai.SpeechStart, Time: time.Now(), Probability: 1}
+	}
+
+	if err := o.Start("topic"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 打断只取消播放阶段：回答之后脚本从下一段继续，监听也仍在
+	waitFor("one")
+	interrupt()
+	waitFor("answer: why?")
+	waitFor("two")
+	interrupt()
+	waitFor("answer: why?")
+	waitFor("three")
+
+	o.Stop()
+	if s := o.GetState(); s != IDLE {
+		t.Errorf("state %s after Stop", s)
+	}
+	select {
+	case text := <-player.started:
+		t.Errorf("played %q after Stop", text)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	want := []played{
+		{"one", true},
+		{"answer: why?", false},
+		{"two", true},
+		{"answer: why?", false},
+		{"three", true},
+	}
+	if got := player.played(); !reflect.DeepEqual(got, want) {
+		t.Errorf("played %+v, want %+v", got, want)
+	}
+	if len(llm.questions) != 2 {
+		t.Errorf("LLM asked %q, want two questions", llm.questions)
+	}
+}
@@ -0,0 +1,202 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/textnorm"
+)
+
+// Policy decides when a question asked during playback is answered
+type Policy int
+
+const (
+	AnswerImmediately   Policy = iota // stop playback at once
+	AnswerAtSentenceEnd               // finish the sentence being spoken
+	AnswerAtSegmentEnd                // finish the script paragraph being spoken
+	AnswerQueued                      // save questions for a blank line in the script
+)
+
+func (p Policy) String() string {
+	switch p {
+	case AnswerImmediately:
+		return "Immediately"
+	case AnswerAtSentenceEnd:
+		return "AtSentenceEnd"
+	case AnswerAtSegmentEnd:
+		return "AtSegmentEnd"
+	case AnswerQueued:
+		return "Queued"
+	default:
+		return "Unknown"
+	}
+}
+
+// sentenceCheck is how often playback is checked for the end of a sentence
+const sentenceCheck = 20 * time.Millisecond
+
+// segment is the script text the playback loop is speaking
+type segment struct {
+	cancel context.CancelFunc
+	cut    bool   // stopped early to answer questions
+	rest   string // what remains to be spoken when cut
+}
+
+// SetInterruptionPolicy sets when questions asked during playback are
+// answered. With any policy but AnswerImmediately, playback goes on while
+// the question is recorded, and every question asked before the break is
+// answered together in one reply. Voice commands still act at once.
+func (o *Orchestrator) SetInterruptionPolicy(p Policy) {
+	o.policy = p
+}
+
+// interrupt acts on speech that interrupted playback. question is what
+// the user already said, if anything.
+func (o *Orchestrator) interrupt(onset ai.VADEvent, question string) {
+	if o.policy == AnswerImmediately || o.GetState() != PLAYING {
+		o.handleInterruption(onset, question)
+		return
+	}
+
+	// Back to full volume after a soft interruption
+	defer o.duck(1)
+
+	if question == "" {
+		log.Printf("[Orchestrator] Recording question while playing (answer %s)", o.policy)
+		var err error
+		question, _, _, err = o.recordQuestion(o.session(), "", false)
+		if err != nil {
+			log.Printf("[Orchestrator] Recording error: %v", err)
+			return
+		}
+	}
+
+	if cmd, ok := o.matchCommand(question); ok {
+		if o.adjustVoice(cmd.Action) {
+			log.Printf("[Orchestrator] Command %s (%q), continuing playback", cmd.Action, cmd.Phrase)
+			return
+		}
+		o.handleInterruption(onset, question)
+		return
+	}
+	if kind := o.classify(o.session(), question); !kind.Answered() {
+		log.Printf("[Orchestrator] User said %q (%s), not answering", question, kind)
+		return
+	}
+
+	o.queueMu.Lock()
+	o.queue = append(o.queue, question)
+	n := len(o.queue)
+	o.queueMu.Unlock()
+	log.Printf("[Orchestrator] Question %d queued: %s", n, question)
+
+	if o.policy == AnswerAtSentenceEnd {
+		o.spawn(o.cutAtSentenceEnd)
+	}
+}
+
+// cutAtSentenceEnd stops the segment being played once the listener has
+// heard the end of the current sentence
+func (o *Orchestrator) cutAtSentenceEnd() {
+	o.playMu.Lock()
+	seg := o.current
+	o.playMu.Unlock()
+	if seg == nil {
+		return
+	}
+
+	end := o.transcript.SentenceEnd(o.heardSample())
+	for {
+		o.playMu.Lock()
+		playing := o.current == seg && !o.playStart.IsZero()
+		o.playMu.Unlock()
+		if !playing {
+			// The segment ended first; its end is the break
+			return
+		}
+		if o.heardSample() >= end {
+			break
+		}
+		select {
+		case <-o.clock.After(sentenceCheck):
+		case <-o.session().Done():
+			return
+		}
+	}
+
+	log.Println("[Orchestrator] Sentence finished, stopping for questions")
+	o.cutSegment(seg, end)
+}
+
+// cutSegment stops seg at timeline position at, keeping what was not
+// heard to be spoken after the answer
+func (o *Orchestrator) cutSegment(seg *segment, at int64) {
+	rest := o.unheardText(at)
+	o.playMu.Lock()
+	seg.cut = true
+	seg.rest = rest
+	o.playMu.Unlock()
+	seg.cancel()
+}
+
+// atBreak reports whether queued questions are answered after p has
+// been spoken
+func (o *Orchestrator) atBreak(p paragraph) bool {
+	switch o.policy {
+	case AnswerAtSentenceEnd, AnswerAtSegmentEnd:
+		return true
+	case AnswerQueued:
+		return p.chapterEnd
+	default:
+		return false
+	}
+}
+
+// answerQueued answers every queued question in one reply. An
+// interruption meanwhile cancels the answer; questions not yet taken
+// wait for the next break.
+func (o *Orchestrator) answerQueued() {
+	ctx, end := o.startPhase(o.session(), answeringPhase)
+	defer end()
+	if ctx.Err() != nil {
+		return
+	}
+
+	o.queueMu.Lock()
+	questions := o.queue
+	o.queue = nil
+	o.queueMu.Unlock()
+	if len(questions) == 0 {
+		return
+	}
+
+	o.setState(THINKING)
+	log.Printf("[Orchestrator] Answering %d queued question(s)", len(questions))
+	heardText := o.transcript.HeardText(o.heardSample())
+	responses := textnorm.Sentences(ctx, o.llm.GenerateResponse(ctx, combineQuestions(questions), heardText))
+	for text := range o.acknowledge(ctx, questions[0], responses) {
+		log.Printf("[Orchestrator] Response chunk: %s", text)
+		if err := o.speak(ctx, text); err != nil && ctx.Err() == nil {
+			log.Printf("[Orchestrator] Response playback error: %v", err)
+		}
+	}
+	o.setState(PLAYING)
+}
+
+// combineQuestions turns the questions asked before a break into one
+// prompt, so they get a single answer
+func combineQuestions(questions []string) string {
+	if len(questions) == 1 {
+		return questions[0]
+	}
+	var b strings.Builder
+	b.WriteString("The listener asked several questions. Answer them together in one reply:")
+	for i, q := range questions {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, q)
+	}
+	return b.String()
+}
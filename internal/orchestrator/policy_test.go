@@ -0,0 +1,180 @@
+package orchestrator
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/audio"
+)
+
+// answerLLM 记录问题，回答一句后结束
+type answerLLM struct {
+	mu        sync.Mutex
+	questions []string
+}
+
+func (a *answerLLM) GenerateStream(ctx context.Context, prompt string) <-chan string {
+	return a.GenerateResponse(ctx, prompt, "")
+}
+
+func (a *answerLLM) GenerateResponse(ctx context.Context, question, context string) <-chan string {
+	a.mu.Lock()
+	a.questions = append(a.questions, question)
+	a.mu.Unlock()
+	ch := make(chan string, 1)
+	ch <- "ok"
+	close(ch)
+	return ch
+}
+
+// alignedTTS 每个字符合成 50ms 静音，并报告整段文本的对齐。和 GLM 一样，
+// 对齐在音频全部交出后才发送；estimate 时先发送估计的对齐
+type alignedTTS struct {
+	estimate bool
+
+	mu    sync.Mutex
+	texts []string
+}
+
+const ttsRate = 24000
+
+func (a *alignedTTS) SynthesizeStream(ctx context.Context, text string) <-chan []byte {
+	audio, _ := a.SynthesizeAligned(ctx, text)
+	return audio
+}
+
+func (a *alignedTTS) SynthesizeAligned(ctx context.Context, text string) (<-chan []byte, <-chan ai.Segment) {
+	a.mu.Lock()
+	a.texts = append(a.texts, text)
+	a.mu.Unlock()
+
+	samples := int64(len([]rune(text))) * ttsRate / 20
+	seg := ai.Segment{Text: text, End: len(text), StartSample: 0, EndSample: samples, SampleRate: ttsRate}
+	audioCh := make(chan []byte)
+	segments := make(chan ai.Segment, 2)
+	if a.estimate {
+		estimate := seg
+		estimate.Estimated = true
+		segments <- estimate
+	}
+	go func() {
+		defer close(audioCh)
+		defer close(segments)
+		block := make([]byte, ttsRate/100*2)
+		for sent := int64(0); sent < samples; sent += ttsRate / 100 {
+			select {
+			case audioCh <- block:
+			case <-ctx.Done():
+				return
+			}
+		}
+		segments <- seg
+	}()
+	return audioCh, segments
+}
+
+func (a *alignedTTS) spoken() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.texts...)
+}
+
+func TestPolicy_SentenceEnd(t *testing.T) {
+	out, err := audio.NewFileOutput(filepath.Join(t.TempDir(), "out.wav"), ttsRate, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	out.SetRealtime(true)
+	player := audio.NewPlayer()
+	player.SetOutput(out)
+
+	// 和 GLM 一样先发估计的对齐，播放中才能找到句末
+	llm, tts := &answerLLM{}, &alignedTTS{estimate: true}
+	o := New(llm, tts, nil, player, nil)
+	o.SetAcknowledgements() // 只记录脚本和回答
+	o.SetInterruptionPolicy(AnswerAtSentenceEnd)
+	o.setState(PLAYING)
+
+	const text = "One two. Three four."
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		o.playSegment(paragraph{text: text})
+	}()
+
+	// 在第一句中提问：播完第一句才回答，然后继续第二句
+	time.Sleep(100 * time.Millisecond)
+	o.interrupt(ai.VADEvent{Type: ai.SpeechStart, Time: time.Now()}, "why?")
+	if got := tts.spoken(); len(got) != 1 {
+		t.Fatalf("answered before the sentence ended: %q", got)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("segment never finished")
+	}
+	if want := []string{text, "ok", "Three four."}; !reflect.DeepEqual(tts.spoken(), want) {
+		t.Errorf("spoke %q, want %q", tts.spoken(), want)
+	}
+	if !reflect.DeepEqual(llm.questions, []string{"why?"}) {
+		t.Errorf("asked %q", llm.questions)
+	}
+}
+
+// tokens 把脚本切成 LLM 流式输出那样的 n 个字符一段
+func tokens(text string, n int) <-chan string {
+	runes := []rune(text)
+	ch := make(chan string, len(runes)/n+1)
+	for i := 0; i < len(runes); i += n {
+		ch <- string(runes[i:min(i+n, len(runes))])
+	}
+	close(ch)
+	return ch
+}
+
+func TestPolicy_Breaks(t *testing.T) {
+	const script = "First part. Still the first.\nEnd of chapter.\n\nNext chapter."
+	tests := []struct {
+		policy Policy
+		want   []string // 说出的内容，"ok" 是回答
+	}{
+		{AnswerAtSegmentEnd, []string{"First part. Still the first.", "ok", "End of chapter.", "Next chapter."}},
+		{AnswerQueued, []string{"First part. Still the first.", "End of chapter.", "ok", "Next chapter."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			llm, tts := &answerLLM{}, &alignedTTS{}
+			o := New(llm, tts, nil, &fakePositionedPlayer{}, nil)
+			o.SetAcknowledgements() // 只记录脚本和回答
+			o.SetInterruptionPolicy(tt.policy)
+			o.setState(PLAYING)
+
+			// 附和不排队，两个问题合并为一次回答
+			for _, said := range []string{"What is a qubit?", "yeah", "Who built the first one?"} {
+				o.interrupt(ai.VADEvent{Type: ai.SpeechStart, Time: time.Now()}, said)
+			}
+			// 脚本按 token 到达，断点仍在整段之后
+			ctx := context.Background()
+			o.playbackLoop(ctx, paragraphs(ctx, tokens(script, 3)))
+
+			if got := tts.spoken(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("spoke %q, want %q", got, tt.want)
+			}
+			if len(llm.questions) != 1 {
+				t.Fatalf("asked the LLM %d times, want once", len(llm.questions))
+			}
+			q := llm.questions[0]
+			if !strings.Contains(q, "1. What is a qubit?") || !strings.Contains(q, "2. Who built the first one?") || strings.Contains(q, "yeah") {
+				t.Errorf("combined question %q", q)
+			}
+		})
+	}
+}
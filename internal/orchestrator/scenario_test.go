@@ -0,0 +1,207 @@
+package orchestrator_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai/aitest"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/orchestrator"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/turn"
+)
+
+const (
+	welcome = "Welcome to the show."
+	topic   = "Today we talk about quantum computers and how they work."
+	qubits  = "Qubits can be zero and one at once. They are also very fragile."
+	goodbye = "That is all for today."
+)
+
+// newOrchestrator 在假引擎上创建编排器，并把状态变化记入 trace
+func newOrchestrator(env *aitest.Env) *orchestrator.Orchestrator {
+	o := orchestrator.New(env.LLM, env.TTS, env.VAD, env.Player, env.Recorder)
+	o.SetClock(env.Clock)
+	turns := turn.NewDetector()
+	turns.SetClock(env.Clock)
+	o.SetTurnDetector(turns)
+	o.SetStateObserver(func(s orchestrator.State) {
+		env.Trace.Add("state", s.String())
+	})
+	return o
+}
+
+func TestScenarios(t *testing.T) {
+	tests := []struct {
+		name string
+		aitest.Scenario
+		plays []string // 依次开始播放的全部内容，nil 不检查
+
+		lateTiming bool // TTS 不发估计，对齐在音频全部交出后才到
+	}{
+		{
+			// 第二段播到 1.5 秒时提问：停顿后轮次结束，先播确认语再回答，
+			// 回答后从正在说的词 "computers" 续播
+			name: "question",
+			Scenario: aitest.Scenario{
+				Script:  []string{welcome, topic, goodbye},
+				Answers: map[string][]string{"What is a qubit?": {"A qubit is a quantum bit."}},
+				Actions: []aitest.Action{aitest.Interrupt(2, 1500*time.Millisecond, "What is a qubit?")},
+				Want: []string{
+					"state: PLAYING",
+					"played: " + welcome,
+					"play: " + topic,
+					"speech start",
+					"state: INTERRUPTED",
+					"stopped: " + topic,
+					"state: THINKING",
+					"record: What is a qubit?",
+					"ask: What is a qubit?",
+					"played: audio", // 确认语
+					"played: A qubit is a quantum bit.",
+					"state: PLAYING",
+					"played: computers and how they work.",
+					"played: " + goodbye,
+					"state: IDLE",
+				},
+			},
+		},
+		{
+			// 附和不触发回答
+			name: "backchannel",
+			Scenario: aitest.Scenario{
+				Script:  []string{welcome, topic, goodbye},
+				Actions: []aitest.Action{aitest.Interrupt(1, 450*time.Millisecond, "mm-hm")},
+				Want: []string{
+					"stopped: " + welcome,
+					"record: mm-hm",
+					"state: PLAYING",
+					"played: to the show.",
+					"played: " + topic,
+					"played: " + goodbye,
+				},
+				Never: []string{"ask..."},
+			},
+		},
+		{
+			// 第二句中说 "skip"：跳过整段剩下的部分，而不只是当前的 token 或句子
+			name: "skip",
+			Scenario: aitest.Scenario{
+				Script:  []string{welcome, qubits, goodbye},
+				Actions: []aitest.Action{aitest.Interrupt(2, 2500*time.Millisecond, "skip")},
+				Want: []string{
+					"play: " + qubits,
+					"stopped: " + qubits,
+					"record: skip",
+					"state: PLAYING",
+					"play: " + goodbye,
+					"state: IDLE",
+				},
+				Never: []string{"ask..."},
+			},
+			plays: []string{welcome, qubits, goodbye},
+		},
+		{
+			// 第二句中说 "repeat that"：从段首重播整段
+			name: "repeat",
+			Scenario: aitest.Scenario{
+				Script:  []string{welcome, qubits, goodbye},
+				Actions: []aitest.Action{aitest.Interrupt(2, 2500*time.Millisecond, "repeat that")},
+				Want: []string{
+					"stopped: " + qubits,
+					"record: repeat that",
+					"played: " + qubits,
+					"played: " + goodbye,
+					"state: IDLE",
+				},
+				Never: []string{"ask..."},
+			},
+			plays: []string{welcome, qubits, qubits, goodbye},
+		},
+		{
+			// 对齐还没到时在第二句中提问：已播的部分没有时间信息，
+			// 回答后从未对齐的第一句开始重播，不能丢掉没听到的内容
+			name: "question before timing",
+			Scenario: aitest.Scenario{
+				Script:  []string{welcome, qubits, goodbye},
+				Answers: map[string][]string{"Why?": {"Because of superposition."}},
+				Actions: []aitest.Action{aitest.Interrupt(2, 2500*time.Millisecond, "Why?")},
+				Want: []string{
+					"play: " + qubits,
+					"stopped: " + qubits,
+					"ask: Why?",
+					"played: Because of superposition.",
+					"played: " + qubits,
+					"played: " + goodbye,
+					"state: IDLE",
+				},
+			},
+			plays:      []string{welcome, qubits, "audio", "Because of superposition.", qubits, goodbye},
+			lateTiming: true,
+		},
+		{
+			// 录音失败时跳过回答，脚本继续
+			name: "recording error",
+			Scenario: aitest.Scenario{
+				Script: []string{welcome, topic, goodbye},
+				Actions: []aitest.Action{aitest.At(2, time.Second, "unplug the microphone", func(env *aitest.Env) {
+					env.Recorder.FailNext(errors.New("microphone unplugged"))
+					env.VAD.SpeechStart(1)
+				})},
+				Want: []string{
+					"stopped: " + topic,
+					"record failed: microphone unplugged",
+					"played: " + goodbye,
+					"state: IDLE",
+				},
+				Never: []string{"ask..."},
+			},
+		},
+		{
+			// 回答时 LLM 断开：没有回答，但仍续播
+			name: "llm error",
+			Scenario: aitest.Scenario{
+				Script: []string{welcome, topic},
+				Actions: []aitest.Action{
+					aitest.At(2, time.Second, "drop the LLM", func(env *aitest.Env) { env.LLM.FailNext() }),
+					aitest.Interrupt(2, time.Second, "Why?"),
+				},
+				Want: []string{
+					"stopped: " + topic,
+					"ask: Why?",
+					"llm failed",
+					"played: quantum computers and how they work.",
+					"state: IDLE",
+				},
+				Never: []string{"play: Answer..."},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				env := tt.Env()
+				env.LLM.FirstToken = 300 * time.Millisecond
+				env.LLM.ChunkDelay = 20 * time.Millisecond
+				env.LLM.TokenSize = 4
+				env.TTS.Latency = 200 * time.Millisecond
+				env.TTS.Estimate = !tt.lateTiming // 和 GLM 一样先发估计的对齐
+				env.Recorder.WaitForStop = true   // 问题何时说完由轮次检测决定
+				tt.Run(t, env, newOrchestrator(env))
+
+				if tt.plays != nil {
+					var plays []string
+					for _, ev := range env.Trace.Events() {
+						if ev.Kind == "play" {
+							plays = append(plays, ev.Text)
+						}
+					}
+					if !slices.Equal(plays, tt.plays) {
+						t.Errorf("played %q, want %q", plays, tt.plays)
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/textnorm"
+)
+
+// maxParagraph caps the length of a script segment in bytes, so a
+// paragraph without line breaks does not hold up playback. Longer ones
+// are split after a sentence.
+const maxParagraph = 1024
+
+// paragraph is a segment of the podcast script: the text up to a line
+// break, collected from the LLM's token deltas
+type paragraph struct {
+	text       string
+	chapterEnd bool // a blank line follows, a natural break in the script
+}
+
+// paragraphs collects the script's token deltas into paragraphs. Skip,
+// Repeat and the break policies act on whole paragraphs, and the TTS
+// engine normalizes whole sentences.
+func paragraphs(ctx context.Context, deltas <-chan string) <-chan paragraph {
+	ch := make(chan paragraph)
+	go func() {
+		defer close(ch)
+		var (
+			c    textnorm.Collector
+			text string
+		)
+		send := func(chapterEnd bool) bool {
+			if text == "" {
+				return true
+			}
+			p := paragraph{text: text, chapterEnd: chapterEnd}
+			text = ""
+			select {
+			case ch <- p:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		add := func(piece textnorm.Piece) bool {
+			text = joinSentences(text, piece.Text)
+			switch {
+			case piece.Break == textnorm.EndOfParagraph:
+				return send(true)
+			case piece.Break == textnorm.EndOfLine || len(text) >= maxParagraph:
+				return send(false)
+			}
+			return true
+		}
+
+		for delta := range deltas {
+			for _, piece := range c.Write(delta) {
+				if !add(piece) {
+					return
+				}
+			}
+		}
+		if piece, ok := c.Flush(); ok && !add(piece) {
+			return
+		}
+		send(false)
+	}()
+	return ch
+}
+
+// joinSentences appends a sentence to the ones before it in a paragraph,
+// with a space between Latin sentences
+func joinSentences(text, sentence string) string {
+	if text == "" {
+		return sentence
+	}
+	if last, _ := utf8.DecodeLastRuneInString(text); last >= utf8.RuneSelf {
+		return text + sentence
+	}
+	return text + " " + sentence
+}
@@ -0,0 +1,124 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"unicode"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Speculation settings
+const (
+	prefetchBuffer = 256 // response chunks generated ahead of playback
+)
+
+// speculation is a response generated from a partial transcript before
+// the user finished speaking
+type speculation struct {
+	question  string
+	responses <-chan string
+	cancel    context.CancelFunc
+}
+
+// speculate starts generating the answer to question in the background
+func (o *Orchestrator) speculate(ctx context.Context, question, heardText string) *speculation {
+	ctx, cancel := context.WithCancel(ctx)
+	return &speculation{
+		question:  question,
+		responses: prefetch(ctx, o.llm.GenerateResponse(ctx, question, heardText)),
+		cancel:    cancel,
+	}
+}
+
+// askStreaming records the question with partial transcripts and starts
+// answering once two partials in a row agree, so the LLM works while the
+// user is still talking. A final transcript that differs from the
+// speculated question in more than punctuation, case or spacing cancels
+// the speculative answer and starts over.
+// Recording ends when the capture context of record is done; answers
+// live until ctx is. It returns the question and its responses; release
+// must be called once the responses are no longer read.
+func (o *Orchestrator) askStreaming(ctx, record context.Context, rec ai.StreamingRecorder, heardText string) (question string, responses <-chan string, release func(), err error) {
+	var spec *speculation
+	prev := ""
+	for h := range rec.RecordStream(record) {
+		if h.Final {
+			if h.Err != nil {
+				if spec != nil {
+					spec.cancel()
+				}
+				return "", nil, nil, h.Err
+			}
+			if spec != nil && sameQuestion(spec.question, h.Text) {
+				log.Printf("[Orchestrator] Final transcript matches the speculative answer")
+				return h.Text, spec.responses, spec.cancel, nil
+			}
+			if spec != nil {
+				log.Printf("[Orchestrator] Final transcript %q differs from %q, restarting answer", h.Text, spec.question)
+				spec.cancel()
+			}
+			ctx, cancel := context.WithCancel(ctx)
+			return h.Text, o.llm.GenerateResponse(ctx, h.Text, heardText), cancel, nil
+		}
+
+		if o.turns != nil {
+			o.turns.Partial(h.Text)
+		}
+		stable := prev != "" && sameQuestion(prev, h.Text)
+		prev = h.Text
+		if !stable || spec != nil && sameQuestion(spec.question, h.Text) {
+			continue
+		}
+		if spec != nil {
+			spec.cancel()
+		}
+		log.Printf("[Orchestrator] Partial transcript %q is stable, answering speculatively", h.Text)
+		spec = o.speculate(ctx, h.Text, heardText)
+	}
+
+	if spec != nil {
+		spec.cancel()
+	}
+	return "", nil, nil, errors.New("recording ended without a final transcript")
+}
+
+// prefetch reads responses into a buffer as fast as they are generated,
+// so a speculative answer is ready when the question is confirmed
+func prefetch(ctx context.Context, in <-chan string) <-chan string {
+	out := make(chan string, prefetchBuffer)
+	go func() {
+		defer close(out)
+		for text := range in {
+			select {
+			case out <- text:
+			case <-ctx.Done():
+				// Let the generator see the cancellation and finish
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// sameQuestion reports whether two transcripts differ only in
+// punctuation, case or spacing. Any other change to a letter, digit or
+// character, however small, can change what is asked: 今天 and 明天, 3
+// and 8, why and who.
+func sameQuestion(a, b string) bool {
+	return slices.Equal(questionRunes(a), questionRunes(b))
+}
+
+func questionRunes(s string) []rune {
+	var out []rune
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			out = append(out, unicode.ToLower(r))
+		}
+	}
+	return out
+}
@@ -0,0 +1,229 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/turn"
+)
+
+// fakeLLM 记录每次 GenerateResponse 的问题，回答即问题本身
+type fakeLLM struct {
+	mu        sync.Mutex
+	questions []string
+	cancelled []bool
+	wg        sync.WaitGroup
+}
+
+func (f *fakeLLM) GenerateStream(ctx context.Context, prompt string) <-chan string {
+	ch := make(chan string)
+	close(ch)
+	return ch
+}
+
+func (f *fakeLLM) GenerateResponse(ctx context.Context, question, context string) <-chan string {
+	f.mu.Lock()
+	i := len(f.questions)
+	f.questions = append(f.questions, question)
+	f.cancelled = append(f.cancelled, false)
+	f.mu.Unlock()
+
+	ch := make(chan string)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		defer close(ch)
+		select {
+		case ch <- "answer: " + question:
+		case <-ctx.Done():
+		}
+		<-ctx.Done()
+		f.mu.Lock()
+		f.cancelled[i] = true
+		f.mu.Unlock()
+	}()
+	return ch
+}
+
+// fakeStreamingRecorder 依次发出给定的假设
+type fakeStreamingRecorder struct {
+	hypotheses []ai.Hypothesis
+}
+
+func (f *fakeStreamingRecorder) Record(ctx context.Context) (string, error) {
+	return f.hypotheses[len(f.hypotheses)-1].Text, nil
+}
+
+func (f *fakeStreamingRecorder) RecordStream(ctx context.Context) <-chan ai.Hypothesis {
+	ch := make(chan ai.Hypothesis)
+	go func() {
+		defer close(ch)
+		for _, h := range f.hypotheses {
+			ch <- h
+		}
+	}()
+	return ch
+}
+
+func partials(texts ...string) []ai.Hypothesis {
+	var hs []ai.Hypothesis
+	for _, t := range texts {
+		hs = append(hs, ai.Hypothesis{Text: t})
+	}
+	return hs
+}
+
+func TestAskStreaming(t *testing.T) {
+	tests := []struct {
+		name       string
+		hypotheses []ai.Hypothesis
+		questions  []string // GenerateResponse 收到的问题
+		answer     string
+	}{
+		{
+			name:       "speculation kept",
+			hypotheses: append(partials("这个观点", "这个观点有什么依据", "这个观点有什么依据？"), ai.Hypothesis{Text: "这个观点有什么依据？", Final: true}),
+			questions:  []string{"这个观点有什么依据？"},
+			answer:     "answer: 这个观点有什么依据？",
+		},
+		{
+			name:       "final differs",
+			hypotheses: append(partials("why is that", "why is that"), ai.Hypothesis{Text: "why is that a problem for small teams", Final: true}),
+			questions:  []string{"why is that", "why is that a problem for small teams"},
+			answer:     "answer: why is that a problem for small teams",
+		},
+		{
+			name:       "one character differs",
+			hypotheses: append(partials("今天天气怎么样", "今天天气怎么样"), ai.Hypothesis{Text: "明天天气怎么样？", Final: true}),
+			questions:  []string{"今天天气怎么样", "明天天气怎么样？"},
+			answer:     "answer: 明天天气怎么样？",
+		},
+		{
+			name:       "never stable",
+			hypotheses: append(partials("what", "what about"), ai.Hypothesis{Text: "what about costs", Final: true}),
+			questions:  []string{"what about costs"},
+			answer:     "answer: what about costs",
+		},
+		{
+			name:       "speculation replaced",
+			hypotheses: append(partials("how", "how", "how does it scale", "how does it scale."), ai.Hypothesis{Text: "How does it scale?", Final: true}),
+			questions:  []string{"how", "how does it scale."},
+			answer:     "answer: how does it scale.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm := &fakeLLM{}
+			o := New(llm, nil, nil, nil, nil)
+			rec := &fakeStreamingRecorder{hypotheses: tt.hypotheses}
+
+			question, responses, release, err := o.askStreaming(context.Background(), context.Background(), rec, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if final := tt.hypotheses[len(tt.hypotheses)-1].Text; question != final {
+				t.Errorf("question %q, want the final transcript %q", question, final)
+			}
+			if got := <-responses; got != tt.answer {
+				t.Errorf("answer %q, want %q", got, tt.answer)
+			}
+			release()
+			llm.wg.Wait()
+
+			if len(llm.questions) != len(tt.questions) {
+				t.Fatalf("GenerateResponse called with %q, want %q", llm.questions, tt.questions)
+			}
+			for i, q := range tt.questions {
+				if llm.questions[i] != q {
+					t.Errorf("question %d = %q, want %q", i, llm.questions[i], q)
+				}
+				// 被替换的推测回答立即取消，最终使用的回答在 release 后取消
+				if !llm.cancelled[i] {
+					t.Errorf("response %d not cancelled", i)
+				}
+			}
+		})
+	}
+}
+
+func TestAskStreaming_Error(t *testing.T) {
+	llm := &fakeLLM{}
+	o := New(llm, nil, nil, nil, nil)
+	rec := &fakeStreamingRecorder{hypotheses: append(partials("hello", "hello"), ai.Hypothesis{Final: true, Err: errors.New("stt failed")})}
+
+	if _, _, _, err := o.askStreaming(context.Background(), context.Background(), rec, ""); err == nil {
+		t.Fatal("recognition error not returned")
+	}
+	llm.wg.Wait()
+	if len(llm.cancelled) != 1 || !llm.cancelled[0] {
+		t.Error("speculative answer not cancelled after the recording failed")
+	}
+}
+
+func TestSameQuestion(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"这个观点有什么依据", "这个观点有什么依据？", true},
+		{"How does it scale?", "how does it scale", true},
+		{"why is that", "Why is that...", true},
+		// 任何实义字符的改动都是另一个问题
+		{"what does the data say about it", "what does the date say about it", false},
+		{"今天天气怎么样", "明天天气怎么样", false},
+		{"他是谁", "你是谁", false},
+		{"5 plus 3", "5 plus 8", false},
+		{"why", "who", false},
+		{"why is that", "why is that a problem", false},
+		{"这个观点", "这个观点有什么依据", false},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		if got := sameQuestion(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameQuestion(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// blockingRecorder 一直录音直到采集结束
+type blockingRecorder struct{}
+
+func (blockingRecorder) Record(ctx context.Context) (string, error) {
+	<-ai.CaptureContext(ctx).Done()
+	return "why?", ctx.Err()
+}
+
+func TestRecordQuestion_EndOfTurn(t *testing.T) {
+	o := New(&fakeLLM{}, nil, nil, nil, blockingRecorder{})
+	d := turn.NewDetector()
+	d.SetSilence(10*time.Millisecond, 50*time.Millisecond, time.Second)
+	o.SetTurnDetector(d)
+
+	events := make(chan ai.VADEvent, 2)
+	o.vadEvents = events
+	o.observeSpeech(ai.VADEvent{Type: ai.SpeechStart, Time: time.Now(), Probability: 1})
+
+	// 录音期间的 SpeechEnd 由 recordQuestion 转给轮次检测
+	events <- ai.VADEvent{Type: ai.SpeechEnd, Time: time.Now()}
+
+	done := make(chan string)
+	go func() {
+		question, _, _, _ := o.recordQuestion(context.Background(), "", true)
+		done <- question
+	}()
+	select {
+	case q := <-done:
+		if q != "why?" {
+			t.Errorf("question %q", q)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("recording did not end after the user stopped speaking")
+	}
+	if o.UserSpeaking() {
+		t.Error("user still marked as speaking")
+	}
+}
@@ -9,6 +9,7 @@ const (
 	INTERRUPTED
 	THINKING
 	UPDATING
+	PAUSED
 )
 
 // String returns the string representation of the state
@@ -24,6 +25,8 @@ func (s State) String() string {
 		return "THINKING"
 	case UPDATING:
 		return "UPDATING"
+	case PAUSED:
+		return "PAUSED"
 	default:
 		return "UNKNOWN"
 	}
@@ -0,0 +1,155 @@
+package textnorm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Lexicon maps terms such as acronyms and proper nouns to how they should
+// be pronounced. Latin terms only match on word boundaries; terms that
+// contain CJK characters match anywhere.
+type Lexicon struct {
+	entries map[string]string
+	terms   []string // sorted longest first so longer terms win
+}
+
+// NewLexicon creates an empty lexicon
+func NewLexicon() *Lexicon {
+	return &Lexicon{entries: make(map[string]string)}
+}
+
+// LoadLexicon reads a lexicon file, see ParseLexicon for the format
+func LoadLexicon(path string) (*Lexicon, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open lexicon: %w", err)
+	}
+	defer file.Close()
+
+	return ParseLexicon(file)
+}
+
+// ParseLexicon reads "term = pronunciation" lines. Empty lines and lines
+// starting with # are ignored, so the file can be edited by hand.
+func ParseLexicon(r io.Reader) (*Lexicon, error) {
+	lexicon := NewLexicon()
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("lexicon line %d: missing '='", lineNo)
+		}
+
+		term := strings.TrimSpace(parts[0])
+		if term == "" {
+			return nil, fmt.Errorf("lexicon line %d: empty term", lineNo)
+		}
+		lexicon.Add(term, strings.TrimSpace(parts[1]))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read lexicon: %w", err)
+	}
+	return lexicon, nil
+}
+
+// Add registers or replaces the pronunciation of a term
+func (l *Lexicon) Add(term, pronunciation string) {
+	if term == "" {
+		return
+	}
+	if _, exists := l.entries[term]; !exists {
+		l.terms = append(l.terms, term)
+		sort.SliceStable(l.terms, func(i, j int) bool {
+			return len(l.terms[i]) > len(l.terms[j])
+		})
+	}
+	l.entries[term] = pronunciation
+}
+
+// Remove deletes a term from the lexicon
+func (l *Lexicon) Remove(term string) {
+	if _, exists := l.entries[term]; !exists {
+		return
+	}
+	delete(l.entries, term)
+	for i, t := range l.terms {
+		if t == term {
+			l.terms = append(l.terms[:i], l.terms[i+1:]...)
+			break
+		}
+	}
+}
+
+// Len returns the number of entries
+func (l *Lexicon) Len() int {
+	return len(l.entries)
+}
+
+// Apply replaces every lexicon term in s with its pronunciation
+func (l *Lexicon) Apply(s string) string {
+	if len(l.terms) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		term, ok := l.matchAt(s, i)
+		if !ok {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			i += size
+			continue
+		}
+		b.WriteString(l.entries[term])
+		i += len(term)
+	}
+	return b.String()
+}
+
+// matchAt returns the longest term that starts at s[i:] and respects
+// word boundaries
+func (l *Lexicon) matchAt(s string, i int) (string, bool) {
+	for _, term := range l.terms {
+		if !strings.HasPrefix(s[i:], term) {
+			continue
+		}
+
+		first, _ := utf8.DecodeRuneInString(term)
+		last, _ := utf8.DecodeLastRuneInString(term)
+		if isWordRune(first) && i > 0 {
+			prev, _ := utf8.DecodeLastRuneInString(s[:i])
+			if isWordRune(prev) {
+				continue
+			}
+		}
+		if end := i + len(term); isWordRune(last) && end < len(s) {
+			next, _ := utf8.DecodeRuneInString(s[end:])
+			if isWordRune(next) {
+				continue
+			}
+		}
+		return term, true
+	}
+	return "", false
+}
+
+// isWordRune reports whether r is part of a Latin word. Han characters are
+// not, so "用GLM生成" still matches the term "GLM".
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
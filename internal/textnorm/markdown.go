@@ -0,0 +1,93 @@
+package textnorm
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	fencePattern      = regexp.MustCompile("(?s)(?:```|~~~).*?(?:```|~~~)")
+	inlineCodePattern = regexp.MustCompile("`([^`\n]*)`")
+	imagePattern      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkPattern       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	boldPattern       = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	italicPattern     = regexp.MustCompile(`(^|[^\w*])[*_](\S(?:[^*_\n]*?\S)?)[*_]($|[^\w*])`)
+	strikePattern     = regexp.MustCompile(`~~(.+?)~~`)
+	headingPattern    = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*$`)
+	bulletPattern     = regexp.MustCompile(`^\s*(?:[-*+•]|\d+[.)])\s+`)
+	quotePattern      = regexp.MustCompile(`^\s*>+\s?`)
+	rulePattern       = regexp.MustCompile(`^\s*(?:[-*_]\s*){3,}$`)
+	tableRulePattern  = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(?:\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+)
+
+// stripMarkdown removes markdown syntax while keeping the readable text.
+// Code blocks are dropped entirely because reading code aloud is useless;
+// headings and list items get sentence punctuation so the TTS pauses.
+func stripMarkdown(s string) string {
+	s = fencePattern.ReplaceAllString(s, "")
+	s = inlineCodePattern.ReplaceAllString(s, "$1")
+	s = imagePattern.ReplaceAllString(s, "$1")
+	s = linkPattern.ReplaceAllString(s, "$1")
+	s = boldPattern.ReplaceAllString(s, "$2")
+	s = strikePattern.ReplaceAllString(s, "$1")
+	s = italicPattern.ReplaceAllString(s, "$1$2$3")
+
+	lines := strings.Split(s, "\n")
+	out := lines[:0]
+	for _, line := range lines {
+		if rulePattern.MatchString(line) || tableRulePattern.MatchString(line) {
+			continue
+		}
+
+		line = quotePattern.ReplaceAllString(line, "")
+
+		if m := headingPattern.FindStringSubmatch(line); m != nil {
+			line = endSentence(m[1])
+		} else if bulletPattern.MatchString(line) {
+			line = endSentence(bulletPattern.ReplaceAllString(line, ""))
+		}
+
+		if strings.Contains(line, "|") {
+			line = tableRow(line)
+		}
+
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// tableRow turns "| a | b |" into "a, b"
+func tableRow(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "|") && !strings.HasSuffix(trimmed, "|") {
+		return line
+	}
+
+	var cells []string
+	for _, cell := range strings.Split(strings.Trim(trimmed, "|"), "|") {
+		if cell = strings.TrimSpace(cell); cell != "" {
+			cells = append(cells, cell)
+		}
+	}
+	return endSentence(strings.Join(cells, ", "))
+}
+
+// endSentence appends a full stop matching the script of the line, unless
+// it already ends with punctuation
+func endSentence(line string) string {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return line
+	}
+
+	last, _ := utf8.DecodeLastRuneInString(line)
+	if unicode.IsPunct(last) {
+		return line
+	}
+	if unicode.Is(unicode.Han, last) {
+		return line + "。"
+	}
+	return line + "."
+}
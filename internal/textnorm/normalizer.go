@@ -0,0 +1,231 @@
+package textnorm
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Language selects the reading rules used when verbalizing numbers and symbols
+type Language int
+
+const (
+	// Auto picks Chinese or English per match from the surrounding text
+	Auto Language = iota
+	Chinese
+	English
+)
+
+// URLMode controls how URLs in the input are read
+type URLMode int
+
+const (
+	// URLSkip drops URLs from the text
+	URLSkip URLMode = iota
+	// URLSpell reads URLs out, e.g. "example dot com slash docs"
+	URLSpell
+)
+
+// contextWindow is the number of runes inspected on each side of a match
+// when the language is detected automatically
+const contextWindow = 8
+
+var (
+	urlPattern        = regexp.MustCompile(`(?:https?://|www\.)[^\s<>"'，。！？、；：）》」】]+`)
+	multiSpacePattern = regexp.MustCompile(`[ \t]{2,}`)
+	multiLinePattern  = regexp.MustCompile(`\n{3,}`)
+	cjkSpacePattern   = regexp.MustCompile(`(\p{Han}) +(\p{Han})`)
+	punctSpacePattern = regexp.MustCompile(` +([,.!?;:，。！？；：、）)])`)
+)
+
+// Normalizer rewrites LLM output into text that reads naturally when spoken
+type Normalizer struct {
+	lang    Language
+	urlMode URLMode
+	lexicon *Lexicon
+}
+
+// New creates a Normalizer with automatic language detection
+func New() *Normalizer {
+	return &Normalizer{
+		lang:    Auto,
+		urlMode: URLSkip,
+		lexicon: NewLexicon(),
+	}
+}
+
+// SetLanguage forces the reading rules instead of detecting them
+func (n *Normalizer) SetLanguage(lang Language) {
+	n.lang = lang
+}
+
+// SetURLMode sets how URLs are read
+func (n *Normalizer) SetURLMode(mode URLMode) {
+	n.urlMode = mode
+}
+
+// SetLexicon replaces the pronunciation lexicon
+func (n *Normalizer) SetLexicon(lexicon *Lexicon) {
+	if lexicon == nil {
+		lexicon = NewLexicon()
+	}
+	n.lexicon = lexicon
+}
+
+// Lexicon returns the pronunciation lexicon so entries can be added in place
+func (n *Normalizer) Lexicon() *Lexicon {
+	return n.lexicon
+}
+
+// Normalize strips markdown, URLs and emoji, applies the lexicon and
+// verbalizes numbers, currencies, percentages, dates, times and units
+func (n *Normalizer) Normalize(text string) string {
+	if text == "" {
+		return ""
+	}
+
+	text = stripEmoji(text)
+	text = stripMarkdown(text)
+	text = n.replaceURLs(text)
+	text = n.lexicon.Apply(text)
+	text = n.verbalize(text)
+
+	text = multiSpacePattern.ReplaceAllString(text, " ")
+	text = punctSpacePattern.ReplaceAllString(text, "$1")
+	text = multiLinePattern.ReplaceAllString(text, "\n\n")
+	// 中文之间不需要空格，去掉替换过程中留下的空格
+	for cjkSpacePattern.MatchString(text) {
+		text = cjkSpacePattern.ReplaceAllString(text, "$1$2")
+	}
+	return strings.TrimSpace(text)
+}
+
+// replaceURLs removes or spells out every URL in s
+func (n *Normalizer) replaceURLs(s string) string {
+	return replaceMatches(urlPattern, s, func(s string, m []int) string {
+		// 句末标点不属于 URL，保留下来让 TTS 停顿
+		url := strings.TrimRight(s[m[0]:m[1]], ".,;:!?)")
+		trailing := s[m[0]+len(url) : m[1]]
+
+		if n.urlMode == URLSkip {
+			return trailing
+		}
+		return spellURL(url, n.languageAt(s, m[0], m[1])) + trailing
+	})
+}
+
+// spellURL reads a URL symbol by symbol, dropping the scheme
+func spellURL(url string, lang Language) string {
+	url = strings.TrimPrefix(url, "https://")
+	url = strings.TrimPrefix(url, "http://")
+	url = strings.TrimSuffix(url, "/")
+
+	symbols := map[rune]string{
+		'.': " dot ", '/': " slash ", '-': " dash ", '_': " underscore ",
+		'?': " question mark ", '=': " equals ", '&': " and ", ':': " colon ",
+	}
+	if lang == Chinese {
+		symbols = map[rune]string{
+			'.': " 点 ", '/': " 斜杠 ", '-': " 横杠 ", '_': " 下划线 ",
+			'?': " 问号 ", '=': " 等于 ", '&': " 和 ", ':': " 冒号 ",
+		}
+	}
+
+	var b strings.Builder
+	for _, r := range url {
+		if word, ok := symbols[r]; ok {
+			b.WriteString(word)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return " " + strings.TrimSpace(multiSpacePattern.ReplaceAllString(b.String(), " ")) + " "
+}
+
+// stripEmoji removes emoji, pictographs and their joiners/selectors
+func stripEmoji(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isEmoji(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // 表情、象形符号、国旗
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // 杂项符号与装饰符号
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF:
+		return true
+	case r == 0x200D || r == 0xFE0F || r == 0x20E3:
+		return true
+	}
+	return false
+}
+
+// languageAt returns the reading language for the match s[start:end]
+func (n *Normalizer) languageAt(s string, start, end int) Language {
+	if n.lang != Auto {
+		return n.lang
+	}
+	return detectLanguage(s, start, end)
+}
+
+// detectLanguage looks at the nearest letters around s[start:end] and
+// reads the match as Chinese when Han characters are closer than Latin ones
+func detectLanguage(s string, start, end int) Language {
+	before := []rune(s[:start])
+	after := []rune(s[end:])
+
+	for i := 0; i < contextWindow; i++ {
+		if i < len(before) {
+			if lang, ok := letterLanguage(before[len(before)-1-i]); ok {
+				return lang
+			}
+		}
+		if i < len(after) {
+			if lang, ok := letterLanguage(after[i]); ok {
+				return lang
+			}
+		}
+	}
+
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return Chinese
+		}
+	}
+	return English
+}
+
+func letterLanguage(r rune) (Language, bool) {
+	switch {
+	case unicode.Is(unicode.Han, r):
+		return Chinese, true
+	case r < unicode.MaxASCII && unicode.IsLetter(r):
+		return English, true
+	}
+	return Auto, false
+}
+
+// replaceMatches is like Regexp.ReplaceAllStringFunc but passes the whole
+// string and the submatch indexes, so replacements can inspect context
+func replaceMatches(re *regexp.Regexp, s string, fn func(s string, m []int) string) string {
+	matches := re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(s[last:m[0]])
+		b.WriteString(fn(s, m))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
@@ -0,0 +1,233 @@
+package textnorm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "中文货币、单位和百分比",
+			input: "我花了$5买了3.5kg苹果，涨了20%。",
+			want:  "我花了五美元买了三点五公斤苹果，涨了百分之二十。",
+		},
+		{
+			name:  "中文日期和时间",
+			input: "会议在2024年3月5日14:30开始，共2个小时。",
+			want:  "会议在二零二四年三月五日十四点三十分开始，共两个小时。",
+		},
+		{
+			name:  "序数词的 2 读二",
+			input: "第2个问题有2个答案。",
+			want:  "第二个问题有两个答案。",
+		},
+		{
+			name:  "中文零下温度和版本号",
+			input: "今天气温-5℃，版本1.2.3发布。",
+			want:  "今天气温零下五摄氏度，版本一点二点三发布。",
+		},
+		{
+			name:  "中文大数",
+			input: "这个数是10005，还有100000和120000000。",
+			want:  "这个数是一万零五，还有十万和一亿二千万。",
+		},
+		{
+			name:  "English currency, percent and ISO date",
+			input: "I paid $3.50 for 2 coffees, up 12.5% since 2024-03-05.",
+			want:  "I paid three dollars and fifty cents for two coffees, up twelve point five percent since March fifth, twenty twenty-four.",
+		},
+		{
+			name:  "English time with meridiem",
+			input: "The meeting starts at 9:05 am on 2023/11/21.",
+			want:  "The meeting starts at nine oh five a m on November twenty-first, twenty twenty-three.",
+		},
+		{
+			name:  "English ordinals, years and thousands separators",
+			input: "The 1st launch in 1969 drew 1,234 people.",
+			want:  "The first launch in nineteen sixty-nine drew one thousand two hundred thirty-four people.",
+		},
+		{
+			name:  "English units and negative numbers",
+			input: "It was -5°C and 1 km away.",
+			want:  "It was minus five degrees Celsius and one kilometer away.",
+		},
+		{
+			name:  "中英混排按上下文选择读法",
+			input: "他说 it costs $20，我觉得3%太高。",
+			want:  "他说 it costs twenty dollars，我觉得百分之三太高。",
+		},
+		{
+			name:  "range dash is not a minus sign",
+			input: "from 3-5 items",
+			want:  "from three - five items",
+		},
+		{
+			name:  "leading zero reads digit by digit",
+			input: "电话010",
+			want:  "电话零一零",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New().Normalize(tt.input); got != tt.want {
+				t.Errorf("Normalize(%q)\n got: %q\nwant: %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize_Markdown(t *testing.T) {
+	input := strings.Join([]string{
+		"# 今日话题",
+		"",
+		"- **第一点**：*重要*的事情",
+		"- 第二点 😀",
+		"1. Read the [docs](https://example.com) first",
+		"> 引用内容",
+		"---",
+		"```go",
+		"fmt.Println(42)",
+		"```",
+		"| Name | Price |",
+		"|---|---|",
+		"| Tea | ¥12 |",
+		"Run `go test` now.",
+	}, "\n")
+
+	want := strings.Join([]string{
+		"今日话题。",
+		"",
+		"第一点：重要的事情。",
+		"第二点。",
+		"Read the docs first.",
+		"引用内容",
+		"",
+		"Name, Price.",
+		"Tea, twelve yuan.",
+		"Run go test now.",
+	}, "\n")
+
+	if got := New().Normalize(input); got != want {
+		t.Errorf("Normalize markdown\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestNormalize_URLs(t *testing.T) {
+	n := New()
+	if got := n.Normalize("详见 https://example.com/docs。"); got != "详见。" {
+		t.Errorf("skip mode: got %q", got)
+	}
+
+	n.SetURLMode(URLSpell)
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"see https://example.com/a-b now.", "see example dot com slash a dash b now."},
+		{"详见 https://example.com/docs。", "详见 example 点 com 斜杠 docs。"},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.input); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize_ForcedLanguage(t *testing.T) {
+	n := New()
+	n.SetLanguage(English)
+	if got := n.Normalize("共有3个"); got != "共有 three 个" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestLexicon(t *testing.T) {
+	lexicon, err := ParseLexicon(strings.NewReader(`
+# 缩写
+GLM = G L M
+NASA = 纳萨
+GLM-TTS = G L M T T S
+通义 = tōng yì
+`))
+	if err != nil {
+		t.Fatalf("ParseLexicon failed: %v", err)
+	}
+	if lexicon.Len() != 4 {
+		t.Fatalf("expected 4 entries, got %d", lexicon.Len())
+	}
+
+	n := New()
+	n.SetLexicon(lexicon)
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"用GLM生成", "用G L M生成"},
+		{"GLM-TTS is fast", "G L M T T S is fast"},
+		{"NASA and NASAL", "纳萨 and NASAL"},
+		{"通义千问", "tōng yì千问"},
+	}
+	for _, tt := range tests {
+		if got := n.Normalize(tt.input); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	lexicon.Remove("NASA")
+	if got := n.Normalize("NASA"); got != "NASA" {
+		t.Errorf("after Remove got %q", got)
+	}
+}
+
+func TestParseLexicon_Invalid(t *testing.T) {
+	if _, err := ParseLexicon(strings.NewReader("GLM G L M")); err == nil {
+		t.Error("expected error for line without '='")
+	}
+	if _, err := ParseLexicon(strings.NewReader(" = x")); err == nil {
+		t.Error("expected error for empty term")
+	}
+}
+
+func TestZhInteger(t *testing.T) {
+	tests := map[string]string{
+		"0":        "零",
+		"10":       "十",
+		"15":       "十五",
+		"110":      "一百一十",
+		"1005":     "一千零五",
+		"20000":    "二万",
+		"100010":   "十万零一十",
+		"10000000": "一千万",
+		"20230401": "二千零二十三万零四百零一",
+	}
+	for in, want := range tests {
+		if got := zhInteger(in); got != want {
+			t.Errorf("zhInteger(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestEnglishNumbers(t *testing.T) {
+	if got := enInteger("1000001"); got != "one million one" {
+		t.Errorf("enInteger = %q", got)
+	}
+	ordinals := map[int]string{1: "first", 2: "second", 12: "twelfth", 20: "twentieth", 23: "twenty-third", 101: "one hundred first"}
+	for in, want := range ordinals {
+		if got := enOrdinal(in); got != want {
+			t.Errorf("enOrdinal(%d) = %q, want %q", in, got, want)
+		}
+	}
+	years := map[int]string{1905: "nineteen oh five", 1900: "nineteen hundred", 2000: "two thousand", 2024: "twenty twenty-four"}
+	for in, want := range years {
+		if got := enYear(in); got != want {
+			t.Errorf("enYear(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
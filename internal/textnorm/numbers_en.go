@@ -0,0 +1,131 @@
+package textnorm
+
+import (
+	"strconv"
+	"strings"
+)
+
+var (
+	enOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	enTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	enScales = []string{"", "thousand", "million", "billion", "trillion", "quadrillion", "quintillion"}
+	enMonths = []string{"January", "February", "March", "April", "May", "June",
+		"July", "August", "September", "October", "November", "December"}
+)
+
+// enDigits reads every digit on its own: "42" -> "four two"
+func enDigits(digits string) string {
+	words := make([]string, 0, len(digits))
+	for _, c := range digits {
+		if c >= '0' && c <= '9' {
+			words = append(words, enOnes[c-'0'])
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// enInteger reads a non-negative integer given as decimal digits:
+// "1205" -> "one thousand two hundred five"
+func enInteger(digits string) string {
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return "zero"
+	}
+	if len(digits) > 18 {
+		return enDigits(digits)
+	}
+
+	n, _ := strconv.ParseUint(digits, 10, 64)
+
+	var parts []string
+	for scale := 0; n > 0; scale++ {
+		group := int(n % 1000)
+		n /= 1000
+		if group == 0 {
+			continue
+		}
+		words := enHundreds(group)
+		if enScales[scale] != "" {
+			words += " " + enScales[scale]
+		}
+		parts = append([]string{words}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
+
+// enHundreds reads 1..999
+func enHundreds(n int) string {
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, enOnes[n/100]+" hundred")
+		n %= 100
+	}
+	if n == 0 {
+		return strings.Join(parts, " ")
+	}
+	if n < 20 {
+		parts = append(parts, enOnes[n])
+	} else if n%10 == 0 {
+		parts = append(parts, enTens[n/10])
+	} else {
+		parts = append(parts, enTens[n/10]+"-"+enOnes[n%10])
+	}
+	return strings.Join(parts, " ")
+}
+
+// enNumber reads a number with an optional fraction: "3.14" -> "three point one four"
+func enNumber(intPart, frac string) string {
+	s := enInteger(intPart)
+	if frac != "" {
+		s += " point " + enDigits(frac)
+	}
+	return s
+}
+
+// enOrdinal reads n as an ordinal: 21 -> "twenty-first"
+func enOrdinal(n int) string {
+	words := enInteger(strconv.Itoa(n))
+
+	irregular := map[string]string{
+		"one": "first", "two": "second", "three": "third", "five": "fifth",
+		"eight": "eighth", "nine": "ninth", "twelve": "twelfth",
+	}
+
+	// 只需要改写最后一个词（连字符后的部分也算一个词）
+	cut := strings.LastIndexAny(words, " -") + 1
+	head, last := words[:cut], words[cut:]
+	if w, ok := irregular[last]; ok {
+		return head + w
+	}
+	if strings.HasSuffix(last, "y") {
+		return head + strings.TrimSuffix(last, "y") + "ieth"
+	}
+	return head + last + "th"
+}
+
+// enYear reads a year the way it is spoken: 1905 -> "nineteen oh five",
+// 2000 -> "two thousand", 2024 -> "twenty twenty-four"
+func enYear(year int) string {
+	if year < 1000 || year > 9999 || (year >= 2000 && year < 2010) {
+		return enInteger(strconv.Itoa(year))
+	}
+
+	hi, lo := year/100, year%100
+	switch {
+	case lo == 0:
+		return enHundreds(hi) + " hundred"
+	case lo < 10:
+		return enHundreds(hi) + " oh " + enOnes[lo]
+	default:
+		return enHundreds(hi) + " " + enHundreds(lo)
+	}
+}
+
+// enPlural picks the singular form when the amount reads as exactly one
+func enPlural(intPart, frac, singular, plural string) string {
+	if strings.TrimLeft(intPart, "0") == "1" && strings.Trim(frac, "0") == "" {
+		return singular
+	}
+	return plural
+}
@@ -0,0 +1,119 @@
+package textnorm
+
+import (
+	"strconv"
+	"strings"
+)
+
+const zhDigitChars = "零一二三四五六七八九"
+
+var (
+	zhDigitRunes   = []rune(zhDigitChars)
+	zhSectionUnits = []string{"", "十", "百", "千"}
+	zhGroupUnits   = []string{"", "万", "亿", "万亿"}
+)
+
+// zhDigits reads every digit on its own: "2024" -> "二零二四"
+func zhDigits(digits string) string {
+	var b strings.Builder
+	for _, c := range digits {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(zhDigitRunes[c-'0'])
+		}
+	}
+	return b.String()
+}
+
+// zhInteger reads a non-negative integer given as decimal digits:
+// "10" -> "十", "1005" -> "一千零五", "120000" -> "十二万"
+func zhInteger(digits string) string {
+	digits = strings.TrimLeft(digits, "0")
+	if digits == "" {
+		return "零"
+	}
+	// 超过万亿的数字按位读
+	if len(digits) > 16 {
+		return zhDigits(digits)
+	}
+
+	n, _ := strconv.ParseUint(digits, 10, 64)
+
+	var groups []int
+	for n > 0 {
+		groups = append(groups, int(n%10000))
+		n /= 10000
+	}
+
+	var b strings.Builder
+	needZero := false
+	for i := len(groups) - 1; i >= 0; i-- {
+		g := groups[i]
+		if g == 0 {
+			needZero = b.Len() > 0
+			continue
+		}
+		if b.Len() > 0 && (needZero || g < 1000) {
+			b.WriteRune('零')
+		}
+		b.WriteString(zhSection(g))
+		b.WriteString(zhGroupUnits[i])
+		needZero = false
+	}
+
+	s := b.String()
+	// 10-19 读作"十几"而不是"一十几"
+	if strings.HasPrefix(s, "一十") {
+		s = strings.TrimPrefix(s, "一")
+	}
+	return s
+}
+
+// zhSection reads 1..9999 without group units
+func zhSection(g int) string {
+	var b strings.Builder
+	pendingZero := false
+	for pos := 3; pos >= 0; pos-- {
+		div := 1
+		for i := 0; i < pos; i++ {
+			div *= 10
+		}
+		d := (g / div) % 10
+		if d == 0 {
+			pendingZero = b.Len() > 0
+			continue
+		}
+		if pendingZero {
+			b.WriteRune('零')
+			pendingZero = false
+		}
+		b.WriteRune(zhDigitRunes[d])
+		b.WriteString(zhSectionUnits[pos])
+	}
+	return b.String()
+}
+
+// zhNumber reads a number with an optional fraction: "3.14" -> "三点一四"
+func zhNumber(intPart, frac string) string {
+	s := zhInteger(intPart)
+	if frac != "" {
+		s += "点" + zhDigits(frac)
+	}
+	return s
+}
+
+// zhMeasureWords are the measure words after which 2 is read as "两"
+var zhMeasureWords = []string{"个", "位", "种", "次", "天", "年", "条", "只", "本", "件", "台", "点", "分钟", "小时", "周", "倍", "张"}
+
+// zhTwo reports whether a standalone "2" between before and rest should
+// be read "两". Ordinals keep "二": "第2个" is "第二个".
+func zhTwo(before, rest string) bool {
+	if strings.HasSuffix(before, "第") {
+		return false
+	}
+	for _, w := range zhMeasureWords {
+		if strings.HasPrefix(rest, w) {
+			return true
+		}
+	}
+	return false
+}
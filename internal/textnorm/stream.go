@@ -0,0 +1,225 @@
+package textnorm
+
+import (
+	"context"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Break is what ends a piece of streamed text
+type Break int
+
+const (
+	EndOfSentence  Break = iota // sentence-ending punctuation, or the end of the stream
+	EndOfLine                   // a line break
+	EndOfParagraph              // a blank line
+)
+
+// Piece is a sentence or line of streamed text, without surrounding
+// whitespace
+type Piece struct {
+	Text  string
+	Break Break
+}
+
+// abbreviations end in a period that does not end the sentence
+var abbreviations = []string{"Mr", "Mrs", "Ms", "Dr", "Prof", "St", "vs", "e.g", "i.e"}
+
+// Collector gathers streamed text, such as LLM token deltas, into whole
+// sentences and lines. Markdown, numbers, dates and units split across
+// deltas are then normalized together instead of being read literally.
+// A fenced code block is one piece, complete once the fence closes.
+type Collector struct {
+	pending string
+}
+
+// Write adds a delta and returns the pieces it completes. A piece is
+// only complete once the text after it is known, so "3." waits for
+// "5" and "**bo" for "ld**".
+func (c *Collector) Write(delta string) []Piece {
+	c.pending += delta
+	var pieces []Piece
+	for {
+		end, next, brk, ok := findBreak(c.pending)
+		if !ok {
+			return pieces
+		}
+		if text := strings.TrimSpace(c.pending[:end]); text != "" {
+			pieces = append(pieces, Piece{Text: text, Break: brk})
+		}
+		c.pending = c.pending[next:]
+	}
+}
+
+// Flush returns the text written since the last complete piece, at the
+// end of the stream
+func (c *Collector) Flush() (Piece, bool) {
+	text := strings.TrimSpace(c.pending)
+	c.pending = ""
+	return Piece{Text: text}, text != ""
+}
+
+// Sentences reads deltas and sends them on as whole sentences and lines,
+// see Collector. The channel is closed when deltas is or ctx is done.
+func Sentences(ctx context.Context, deltas <-chan string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		send := func(p Piece) bool {
+			select {
+			case ch <- p.Text:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var c Collector
+		for delta := range deltas {
+			for _, p := range c.Write(delta) {
+				if !send(p) {
+					return
+				}
+			}
+		}
+		if p, ok := c.Flush(); ok {
+			send(p)
+		}
+	}()
+	return ch
+}
+
+// findBreak finds the first complete piece in s. It returns where the
+// piece's text ends, where the next one starts and what separates them;
+// ok is false while more text is needed to tell.
+func findBreak(s string) (end, next int, brk Break, ok bool) {
+	for i := 0; i < len(s); {
+		// A code block is held until it is closed, so it is stripped whole
+		if i == 0 || s[i-1] == '\n' {
+			if fence := fenceAt(s[i:]); fence != "" {
+				closed := fenceEnd(s, i, fence)
+				if closed < 0 {
+					return 0, 0, 0, false
+				}
+				i = closed
+				continue
+			}
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == '\n' {
+			return breakAfter(s, i)
+		}
+		i += size
+		if !isSentenceEnd(r) {
+			continue
+		}
+
+		// Closing quotes and further marks belong to the sentence
+		j := i
+		latin := r < utf8.RuneSelf
+		for j < len(s) {
+			n, size := utf8.DecodeRuneInString(s[j:])
+			if !isSentenceEnd(n) && !isCloser(n) {
+				break
+			}
+			latin = latin && n < utf8.RuneSelf
+			j += size
+		}
+		if j == len(s) {
+			return 0, 0, 0, false
+		}
+
+		// "3.5", "example.com" and "e.g. this" go on
+		n, _ := utf8.DecodeRuneInString(s[j:])
+		if latin && !unicode.IsSpace(n) && !unicode.Is(unicode.Han, n) {
+			continue
+		}
+		if r == '.' && !endsSentence(s[:i-size]) {
+			continue
+		}
+		return breakAfter(s, j)
+	}
+	return 0, 0, 0, false
+}
+
+// fenceAt returns the marker of a code fence opening line, "```" or "~~~",
+// or "" when line does not open one
+func fenceAt(line string) string {
+	line = strings.TrimLeft(line, " \t")
+	for _, fence := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, fence) {
+			return fence
+		}
+	}
+	return ""
+}
+
+// fenceEnd returns where the line closing the code block opened at i
+// ends, or -1 while the block is still open
+func fenceEnd(s string, i int, fence string) int {
+	open := strings.IndexByte(s[i:], '\n')
+	if open < 0 {
+		return -1
+	}
+	for line := i + open + 1; line < len(s); {
+		end := strings.IndexByte(s[line:], '\n')
+		if end < 0 {
+			end = len(s) - line
+		}
+		if strings.HasPrefix(strings.TrimLeft(s[line:], " \t"), fence) {
+			return line + end
+		}
+		line += end + 1
+	}
+	return -1
+}
+
+// breakAfter returns the break at i, where the whitespace following the
+// piece starts, once the first text after it has arrived
+func breakAfter(s string, i int) (end, next int, brk Break, ok bool) {
+	lines := 0
+	for j, r := range s[i:] {
+		if r == '\n' {
+			lines++
+			continue
+		}
+		if unicode.IsSpace(r) {
+			continue
+		}
+		brk = EndOfSentence
+		switch {
+		case lines > 1:
+			brk = EndOfParagraph
+		case lines == 1:
+			brk = EndOfLine
+		}
+		return i, i + j, brk, true
+	}
+	return 0, 0, 0, false
+}
+
+// endsSentence reports whether a period after text ends the sentence,
+// unlike the one in a list marker such as "2." or after "Dr"
+func endsSentence(text string) bool {
+	line := text[strings.LastIndexByte(text, '\n')+1:]
+	if strings.TrimLeft(line, " \t0123456789") == "" {
+		return false
+	}
+	word := line[strings.LastIndexFunc(line, unicode.IsSpace)+1:]
+	for _, a := range abbreviations {
+		if word == a {
+			return false
+		}
+	}
+	return true
+}
+
+func isSentenceEnd(r rune) bool {
+	return strings.ContainsRune("。！？.!?…", r)
+}
+
+func isCloser(r rune) bool {
+	return strings.ContainsRune("\"'”’」』）)]", r)
+}
@@ -0,0 +1,121 @@
+package textnorm
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []string
+		want   []Piece
+	}{
+		{
+			name:   "跨 delta 的 markdown 和数字",
+			deltas: []string{"**bo", "ld** 1", "23"},
+			want:   []Piece{{Text: "**bold** 123"}},
+		},
+		{
+			name:   "小数点和网址不是句末",
+			deltas: []string{"It costs 3.", "5 dollars at example.", "com. Buy ", "it!"},
+			want: []Piece{
+				{Text: "It costs 3.5 dollars at example.com."},
+				{Text: "Buy it!"},
+			},
+		},
+		{
+			name:   "中文句末和引号",
+			deltas: []string{"他说：“好。", "”然后", "走了。明天见"},
+			want: []Piece{
+				{Text: "他说：“好。”"},
+				{Text: "然后走了。"},
+				{Text: "明天见"},
+			},
+		},
+		{
+			name:   "列表序号和缩写",
+			deltas: []string{"1. Ask Dr. Smith\n", "2. Wait"},
+			want: []Piece{
+				{Text: "1. Ask Dr. Smith", Break: EndOfLine},
+				{Text: "2. Wait"},
+			},
+		},
+		{
+			name:   "代码块整体输出",
+			deltas: []string{"Run:\n``", "`sh\nls -a.", "\n``", "`\nOk"},
+			want: []Piece{
+				{Text: "Run:", Break: EndOfLine},
+				{Text: "```sh\nls -a.\n```", Break: EndOfLine},
+				{Text: "Ok"},
+			},
+		},
+		{
+			name:   "换行和空行",
+			deltas: []string{"# Part one\n", "\nIntro.\n", "\n\n", "Next"},
+			want: []Piece{
+				{Text: "# Part one", Break: EndOfParagraph},
+				{Text: "Intro.", Break: EndOfParagraph},
+				{Text: "Next"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Collector
+			var got []Piece
+			for _, d := range tt.deltas {
+				got = append(got, c.Write(d)...)
+			}
+			if p, ok := c.Flush(); ok {
+				got = append(got, p)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pieces = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentences_Normalize(t *testing.T) {
+	deltas := make(chan string, 3)
+	for _, d := range []string{"**bo", "ld** 1", "23"} {
+		deltas <- d
+	}
+	close(deltas)
+
+	n := New()
+	n.SetLanguage(English)
+	var got []string
+	for s := range Sentences(context.Background(), deltas) {
+		got = append(got, n.Normalize(s))
+	}
+	want := []string{"bold one hundred twenty-three"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalized = %q, want %q", got, want)
+	}
+}
+
+func TestSentences_CodeBlock(t *testing.T) {
+	const script = "Here is the code:\n```go\nfmt.Println(\"hi\")\nx := 42\n```\nIt prints hi.\n~~~\nTODO. Done.\n~~~"
+	// 逐 token 到达，代码块跨越多个 delta
+	deltas := make(chan string, len(script))
+	for i := 0; i < len(script); i += 3 {
+		deltas <- script[i:min(i+3, len(script))]
+	}
+	close(deltas)
+
+	n := New()
+	n.SetLanguage(English)
+	var got []string
+	for s := range Sentences(context.Background(), deltas) {
+		if text := n.Normalize(s); text != "" {
+			got = append(got, text)
+		}
+	}
+	want := []string{"Here is the code:", "It prints hi."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalized = %q, want %q", got, want)
+	}
+}
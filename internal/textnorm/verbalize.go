@@ -0,0 +1,366 @@
+package textnorm
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const numberExpr = `(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d+))?`
+
+var (
+	datePattern     = regexp.MustCompile(`(\d{4})[-/.](\d{1,2})[-/.](\d{1,2})(?:[日号])?`)
+	zhYearPattern   = regexp.MustCompile(`(\d{4})年`)
+	timePattern     = regexp.MustCompile(`(\d{1,2}):(\d{2})(?::(\d{2}))?(?:\s?([aApP])\.?[mM]\.?)?`)
+	currencyPattern = regexp.MustCompile(`(-)?([$¥￥€£])\s?` + numberExpr)
+	percentPattern  = regexp.MustCompile(`(-)?` + numberExpr + `\s?[%％]`)
+	unitPattern     = regexp.MustCompile(`(-)?` + numberExpr + `\s?(km/h|km|kg|cm|mm|mg|ml|GB|MB|KB|TB|kHz|Hz|ms|°C|℃|°F)`)
+	ordinalPattern  = regexp.MustCompile(`(\d+)(st|nd|rd|th)`)
+	versionPattern  = regexp.MustCompile(`\d+(?:\.\d+){2,}`)
+	numberPattern   = regexp.MustCompile(`(-)?` + numberExpr)
+)
+
+// currency holds the readings of a currency symbol
+type currency struct {
+	zh              string
+	enOne, enMany   string
+	subOne, subMany string // 英文读法中两位小数读作辅币
+}
+
+var currencies = map[string]currency{
+	"$": {zh: "美元", enOne: "dollar", enMany: "dollars", subOne: "cent", subMany: "cents"},
+	"¥": {zh: "元", enOne: "yuan", enMany: "yuan", subOne: "fen", subMany: "fen"},
+	"￥": {zh: "元", enOne: "yuan", enMany: "yuan", subOne: "fen", subMany: "fen"},
+	"€": {zh: "欧元", enOne: "euro", enMany: "euros", subOne: "cent", subMany: "cents"},
+	"£": {zh: "英镑", enOne: "pound", enMany: "pounds", subOne: "penny", subMany: "pence"},
+}
+
+// unit holds the readings of a measurement unit
+type unit struct {
+	zh            string
+	enOne, enMany string
+}
+
+var units = map[string]unit{
+	"km/h": {"公里每小时", "kilometer per hour", "kilometers per hour"},
+	"km":   {"公里", "kilometer", "kilometers"},
+	"kg":   {"公斤", "kilogram", "kilograms"},
+	"cm":   {"厘米", "centimeter", "centimeters"},
+	"mm":   {"毫米", "millimeter", "millimeters"},
+	"mg":   {"毫克", "milligram", "milligrams"},
+	"ml":   {"毫升", "milliliter", "milliliters"},
+	"GB":   {"G", "gigabyte", "gigabytes"},
+	"MB":   {"兆", "megabyte", "megabytes"},
+	"KB":   {"K", "kilobyte", "kilobytes"},
+	"TB":   {"T", "terabyte", "terabytes"},
+	"kHz":  {"千赫兹", "kilohertz", "kilohertz"},
+	"Hz":   {"赫兹", "hertz", "hertz"},
+	"ms":   {"毫秒", "millisecond", "milliseconds"},
+	"°C":   {"摄氏度", "degree Celsius", "degrees Celsius"},
+	"℃":    {"摄氏度", "degree Celsius", "degrees Celsius"},
+	"°F":   {"华氏度", "degree Fahrenheit", "degrees Fahrenheit"},
+}
+
+// verbalize rewrites every number-like token in s. The passes go from the
+// most to the least specific pattern; each pass replaces digits with words
+// so later passes never see them again.
+func (n *Normalizer) verbalize(s string) string {
+	s = replaceMatches(datePattern, s, n.date)
+	s = replaceMatches(zhYearPattern, s, n.zhYear)
+	s = replaceMatches(timePattern, s, n.clock)
+	s = replaceMatches(currencyPattern, s, n.currency)
+	s = replaceMatches(percentPattern, s, n.percent)
+	s = replaceMatches(unitPattern, s, n.unit)
+	s = replaceMatches(ordinalPattern, s, n.ordinal)
+	s = replaceMatches(versionPattern, s, n.version)
+	s = replaceMatches(numberPattern, s, n.number)
+	return s
+}
+
+func (n *Normalizer) date(s string, m []int) string {
+	year, _ := strconv.Atoi(s[m[2]:m[3]])
+	month, _ := strconv.Atoi(s[m[4]:m[5]])
+	day, _ := strconv.Atoi(s[m[6]:m[7]])
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return s[m[0]:m[1]]
+	}
+
+	if n.languageAt(s, m[0], m[1]) == Chinese {
+		return zhDigits(s[m[2]:m[3]]) + "年" + zhInteger(strconv.Itoa(month)) + "月" + zhInteger(strconv.Itoa(day)) + "日"
+	}
+	return " " + enMonths[month-1] + " " + enOrdinal(day) + ", " + enYear(year) + " "
+}
+
+// zhYear reads the year in "2024年" digit by digit
+func (n *Normalizer) zhYear(s string, m []int) string {
+	return zhDigits(s[m[2]:m[3]]) + "年"
+}
+
+func (n *Normalizer) clock(s string, m []int) string {
+	if !standalone(s, m[0], m[1]) {
+		return s[m[0]:m[1]]
+	}
+
+	hour, _ := strconv.Atoi(s[m[2]:m[3]])
+	minute, _ := strconv.Atoi(s[m[4]:m[5]])
+	second := -1
+	if m[6] >= 0 {
+		second, _ = strconv.Atoi(s[m[6]:m[7]])
+	}
+	if hour > 24 || minute > 59 || second > 59 {
+		return s[m[0]:m[1]]
+	}
+	meridiem := ""
+	if m[8] >= 0 {
+		meridiem = strings.ToLower(s[m[8]:m[9]])
+	}
+
+	if n.languageAt(s, m[0], m[1]) == Chinese {
+		var b strings.Builder
+		switch meridiem {
+		case "a":
+			b.WriteString("上午")
+		case "p":
+			b.WriteString("下午")
+		}
+		b.WriteString(zhInteger(strconv.Itoa(hour)) + "点")
+		if minute > 0 {
+			if minute < 10 {
+				b.WriteString("零")
+			}
+			b.WriteString(zhInteger(strconv.Itoa(minute)) + "分")
+		}
+		if second > 0 {
+			b.WriteString(zhInteger(strconv.Itoa(second)) + "秒")
+		}
+		return b.String()
+	}
+
+	words := enInteger(strconv.Itoa(hour))
+	switch {
+	case minute == 0 && meridiem == "":
+		words += " o'clock"
+	case minute > 0 && minute < 10:
+		words += " oh " + enOnes[minute]
+	case minute >= 10:
+		words += " " + enHundreds(minute)
+	}
+	if second > 0 {
+		words += " and " + enInteger(strconv.Itoa(second)) + " " + enPlural(strconv.Itoa(second), "", "second", "seconds")
+	}
+	if meridiem != "" {
+		words += " " + meridiem + " m"
+	}
+	return " " + words + " "
+}
+
+func (n *Normalizer) currency(s string, m []int) string {
+	cur := currencies[s[m[4]:m[5]]]
+	intPart, frac := numberParts(s, m[6], m[7], m[8], m[9])
+	negative := m[2] >= 0 && signAllowed(s, m[0])
+
+	if n.languageAt(s, m[0], m[1]) == Chinese {
+		return signPrefix(s, m, negative) + zhSign(negative) + zhNumber(intPart, frac) + cur.zh
+	}
+
+	var words string
+	if len(frac) == 2 {
+		words = enInteger(intPart) + " " + enPlural(intPart, "", cur.enOne, cur.enMany)
+		if cents := strings.TrimLeft(frac, "0"); cents != "" {
+			words += " and " + enInteger(cents) + " " + enPlural(cents, "", cur.subOne, cur.subMany)
+		}
+	} else {
+		words = enNumber(intPart, frac) + " " + enPlural(intPart, frac, cur.enOne, cur.enMany)
+	}
+	return signPrefix(s, m, negative) + " " + enSign(negative) + words + " "
+}
+
+func (n *Normalizer) percent(s string, m []int) string {
+	intPart, frac := numberParts(s, m[4], m[5], m[6], m[7])
+	negative := m[2] >= 0 && signAllowed(s, m[0])
+
+	if n.languageAt(s, m[0], m[1]) == Chinese {
+		return signPrefix(s, m, negative) + zhSign(negative) + "百分之" + zhNumber(intPart, frac)
+	}
+	return signPrefix(s, m, negative) + " " + enSign(negative) + enNumber(intPart, frac) + " percent "
+}
+
+func (n *Normalizer) unit(s string, m []int) string {
+	// 单位后面紧跟字母说明并不是单位（例如 "5 kmph"），交给普通数字处理
+	if next, _ := utf8.DecodeRuneInString(s[m[1]:]); m[1] < len(s) && isWordRune(next) {
+		return s[m[0]:m[1]]
+	}
+
+	u := units[s[m[8]:m[9]]]
+	intPart, frac := numberParts(s, m[4], m[5], m[6], m[7])
+	negative := m[2] >= 0 && signAllowed(s, m[0])
+
+	if n.languageAt(s, m[0], m[1]) == Chinese {
+		sign := zhSign(negative)
+		if negative && (u.zh == "摄氏度" || u.zh == "华氏度") {
+			sign = "零下"
+		}
+		return signPrefix(s, m, negative) + sign + zhNumber(intPart, frac) + u.zh
+	}
+	return signPrefix(s, m, negative) + " " + enSign(negative) + enNumber(intPart, frac) + " " + enPlural(intPart, frac, u.enOne, u.enMany) + " "
+}
+
+func (n *Normalizer) ordinal(s string, m []int) string {
+	if !standalone(s, m[0], m[1]) || n.languageAt(s, m[0], m[1]) == Chinese {
+		return s[m[0]:m[1]]
+	}
+
+	value, err := strconv.Atoi(s[m[2]:m[3]])
+	if err != nil {
+		return s[m[0]:m[1]]
+	}
+
+	// 只有后缀与数字匹配时才按序数词读（1st、2nd、3rd、4th）
+	want := "th"
+	if value%100 < 11 || value%100 > 13 {
+		switch value % 10 {
+		case 1:
+			want = "st"
+		case 2:
+			want = "nd"
+		case 3:
+			want = "rd"
+		}
+	}
+	if strings.ToLower(s[m[4]:m[5]]) != want {
+		return s[m[0]:m[1]]
+	}
+	return " " + enOrdinal(value) + " "
+}
+
+// version reads dotted sequences such as "1.2.3" part by part
+func (n *Normalizer) version(s string, m []int) string {
+	parts := strings.Split(s[m[0]:m[1]], ".")
+
+	if n.languageAt(s, m[0], m[1]) == Chinese {
+		for i, p := range parts {
+			parts[i] = zhInteger(p)
+		}
+		return strings.Join(parts, "点")
+	}
+	for i, p := range parts {
+		parts[i] = enInteger(p)
+	}
+	return " " + strings.Join(parts, " point ") + " "
+}
+
+func (n *Normalizer) number(s string, m []int) string {
+	intPart, frac := numberParts(s, m[4], m[5], m[6], m[7])
+	negative := m[2] >= 0 && signAllowed(s, m[0])
+	lang := n.languageAt(s, m[0], m[1])
+	// 以 0 开头的长数字（电话、编号）逐位读
+	byDigit := len(intPart) > 1 && intPart[0] == '0' && frac == ""
+
+	if lang == Chinese {
+		var words string
+		switch {
+		case byDigit:
+			words = zhDigits(intPart)
+		case intPart == "2" && frac == "" && zhTwo(s[:m[0]], s[m[1]:]):
+			words = "两"
+		default:
+			words = zhNumber(intPart, frac)
+		}
+		return signPrefix(s, m, negative) + zhSign(negative) + words
+	}
+
+	words := enNumber(intPart, frac)
+	switch {
+	case byDigit:
+		words = enDigits(intPart)
+	case frac == "" && !negative && looksLikeYear(s, m[0], intPart):
+		year, _ := strconv.Atoi(intPart)
+		words = enYear(year)
+	}
+	return signPrefix(s, m, negative) + " " + enSign(negative) + words + " "
+}
+
+// yearPrepositions are the words after which a four-digit number is read
+// as a year in English: "in 1969" -> "in nineteen sixty-nine"
+var yearPrepositions = []string{"in", "since", "by", "from", "until", "of", "year", "circa", "before", "after"}
+
+// looksLikeYear reports whether the English number at s[start:] is a year
+func looksLikeYear(s string, start int, digits string) bool {
+	if len(digits) != 4 || digits < "1100" || digits > "2099" {
+		return false
+	}
+
+	fields := strings.Fields(strings.ToLower(s[:start]))
+	if len(fields) == 0 {
+		return false
+	}
+	prev := fields[len(fields)-1]
+	for _, w := range yearPrepositions {
+		if prev == w {
+			return true
+		}
+	}
+	return false
+}
+
+// numberParts extracts the integer digits (without thousands separators)
+// and the fraction digits from the given submatch bounds
+func numberParts(s string, intStart, intEnd, fracStart, fracEnd int) (string, string) {
+	intPart := strings.ReplaceAll(s[intStart:intEnd], ",", "")
+	frac := ""
+	if fracStart >= 0 {
+		frac = s[fracStart:fracEnd]
+	}
+	return intPart, frac
+}
+
+// signAllowed reports whether a "-" at s[i] is a minus sign rather than a
+// hyphen or range dash, i.e. it is not glued to a preceding word or number
+func signAllowed(s string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(s[:i])
+	return !isWordRune(prev)
+}
+
+// signPrefix keeps a "-" that matched the pattern but is not a minus sign
+func signPrefix(s string, m []int, negative bool) string {
+	if m[2] >= 0 && !negative {
+		return "-"
+	}
+	return ""
+}
+
+func zhSign(negative bool) string {
+	if negative {
+		return "负"
+	}
+	return ""
+}
+
+func enSign(negative bool) string {
+	if negative {
+		return "minus "
+	}
+	return ""
+}
+
+// standalone reports whether s[start:end] is not glued to other letters or
+// digits, so "A3:B4" or "v2nd" are left alone
+func standalone(s string, start, end int) bool {
+	if start > 0 {
+		prev, _ := utf8.DecodeLastRuneInString(s[:start])
+		if isWordRune(prev) {
+			return false
+		}
+	}
+	if end < len(s) {
+		next, _ := utf8.DecodeRuneInString(s[end:])
+		if isWordRune(next) {
+			return false
+		}
+	}
+	return true
+}
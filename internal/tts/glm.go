@@ -7,31 +7,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/align"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/textnorm"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
 )
 
 const (
-	maxInputLength = 1024 // GLM-TTS API 限制
+	maxInputLength     = 1024 // GLM-TTS API 限制
+	defaultConcurrency = 3    // 同时合成的分段数
+
+	// 流式返回的 PCM 格式：24kHz、16 位、单声道
+	glmSampleRate     = 24000
+	glmBytesPerSample = 2
+
+	// glmSyllableTime is how long a syllable of speech takes at speed 1,
+	// for estimating timing before any audio has arrived
+	glmSyllableTime = 220 * time.Millisecond
 )
 
 // GLM implements TTSEngine using Zhipu AI GLM-TTS API
 type GLM struct {
-	apiKey  string
-	voice   string
-	speed   float64
-	volume  float64
-	baseURL string
+	apiKey      string
+	voice       string
+	baseURL     string
+	normalizer  *textnorm.Normalizer
+	concurrency int
+	client      *http.Client
+
+	// speed and volume may change while text is being synthesized
+	mu     sync.RWMutex
+	speed  float64
+	volume float64
 }
 
 // NewGLM creates a new GLM-TTS client
 func NewGLM(apiKey string) *GLM {
 	return &GLM{
-		apiKey:  apiKey,
-		voice:   "tongtong", // 默认音色
-		speed:   1.0,
-		volume:  1.0,
-		baseURL: "https://open.bigmodel.cn/api/paas/v4/audio/speech",
+		apiKey:      apiKey,
+		voice:       "tongtong", // 默认音色
+		speed:       1.0,
+		volume:      1.0,
+		baseURL:     "https://open.bigmodel.cn/api/paas/v4/audio/speech",
+		normalizer:  textnorm.New(),
+		concurrency: defaultConcurrency,
+		client:      &http.Client{},
 	}
 }
 
@@ -40,6 +66,11 @@ func (g *GLM) SetVoice(voice string) {
 	g.voice = voice
 }
 
+// Voice returns the current voice
+func (g *GLM) Voice() string {
+	return g.voice
+}
+
 // SetSpeed sets the speech speed [0.5, 2.0]
 func (g *GLM) SetSpeed(speed float64) {
 	if speed < 0.5 {
@@ -47,14 +78,62 @@ func (g *GLM) SetSpeed(speed float64) {
 	} else if speed > 2.0 {
 		speed = 2.0
 	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.speed = speed
 }
 
+// Speed returns the speech speed
+func (g *GLM) Speed() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.speed
+}
+
 // SetVolume sets the volume (0, 10]
 func (g *GLM) SetVolume(volume float64) {
+	if volume < 0.1 {
+		volume = 0.1
+	} else if volume > 10 {
+		volume = 10
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.volume = volume
 }
 
+// Volume returns the volume
+func (g *GLM) Volume() float64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.volume
+}
+
+// SetNormalizer sets the text normalizer applied before synthesis,
+// nil disables normalization
+func (g *GLM) SetNormalizer(normalizer *textnorm.Normalizer) {
+	g.normalizer = normalizer
+}
+
+// SetConcurrency sets how many text chunks are synthesized in parallel,
+// audio is still emitted in order
+func (g *GLM) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	g.concurrency = n
+}
+
+// normalize prepares LLM output for reading aloud. Each call is
+// normalized on its own, so streamed LLM output must be passed in whole
+// sentences, as textnorm.Collector gathers them.
+func (g *GLM) normalize(text string) string {
+	if g.normalizer == nil {
+		return text
+	}
+	return g.normalizer.Normalize(text)
+}
+
 // glmRequest represents the request body for GLM-TTS API
 type glmRequest struct {
 	Model          string  `json:"model"`
@@ -130,180 +209,280 @@ func (g *GLM) SynthesizeStream(ctx context.Context, text string) <-chan []byte {
 	go func() {
 		defer close(ch)
 
-		// 如果文本为空，直接返回
+		// 去掉 markdown、数字转读法等，再判断是否为空
+		text := g.normalize(text)
 		if len(text) == 0 {
 			return
 		}
 
-		// 分段处理长文本
-		chunks := splitText(text)
+		g.synthesizeStream(ctx, text, ch, nil, nil)
+	}()
 
-		for _, chunk := range chunks {
-			// 检查 context 是否已取消
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
+	return ch
+}
 
-			// 构建请求
-			reqBody := glmRequest{
-				Model:          "glm-tts",
-				Input:          chunk,
-				Voice:          g.voice,
-				ResponseFormat: "pcm",
-				Stream:         true,
-				EncodeFormat:   "base64",
-			}
-			if g.speed != 1.0 {
-				reqBody.Speed = g.speed
-			}
-			if g.volume != 1.0 {
-				reqBody.Volume = g.volume
-			}
+// SynthesizeAligned converts text to an audio stream and reports one
+// segment per sentence. GLM-TTS returns no timestamps, so each chunk's
+// PCM length is split among its sentences by estimated speaking time.
+// As the length is only known once a chunk is complete, its sentences
+// are first sent as estimates when its audio starts, timed at the
+// speaking rate measured so far.
+func (g *GLM) SynthesizeAligned(ctx context.Context, text string) (<-chan []byte, <-chan ai.Segment) {
+	text = g.normalize(text)
+	chunks := splitText(text)
 
-			jsonData, err := json.Marshal(reqBody)
-			if err != nil {
-				return
-			}
+	// 预先切好句子，segment channel 的容量足够放下全部句子的估计和最终时间，调用方不会被阻塞
+	sentences := make([][]align.Span, len(chunks))
+	total := 0
+	for i, chunk := range chunks {
+		sentences[i] = align.SplitSentences(chunk)
+		total += len(sentences[i])
+	}
 
-			// 创建 HTTP 请求
-			req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL, strings.NewReader(string(jsonData)))
-			if err != nil {
-				return
-			}
+	ch := make(chan []byte)
+	segments := make(chan ai.Segment, 2*total)
 
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	go func() {
+		defer close(ch)
+		defer close(segments)
 
-			// 发送请求
-			client := &http.Client{}
-			resp, err := client.Do(req)
-			if err != nil {
-				return
-			}
+		if len(text) == 0 {
+			return
+		}
 
-			if resp.StatusCode != http.StatusOK {
-				resp.Body.Close()
-				return
+		var (
+			received    int64 // 已收到的 PCM 字节数
+			current     int   // 正在接收的段
+			started     bool  // 当前段的估计已发送
+			chunkStart  int64 // 当前段的起始采样点
+			chunkOffset int   // 当前段在文本中的字节偏移
+			doneWeight  float64
+		)
+		// 每个音节的采样数：有已完成的段时按实测语速，否则按默认语速
+		rate := func() float64 {
+			if doneWeight > 0 && chunkStart > 0 {
+				return float64(chunkStart) / doneWeight
+			}
+			return glmSyllableTime.Seconds() * glmSampleRate / g.Speed()
+		}
+		send := func(i int, end int64, estimated bool) {
+			ranges := align.Distribute(chunks[i], sentences[i], chunkStart, end)
+			for j, span := range sentences[i] {
+				segments <- ai.Segment{
+					Text:        chunks[i][span.Start:span.End],
+					Start:       chunkOffset + span.Start,
+					End:         chunkOffset + span.End,
+					StartSample: ranges[j][0],
+					EndSample:   ranges[j][1],
+					SampleRate:  glmSampleRate,
+					Estimated:   estimated,
+				}
+			}
+		}
+		g.synthesizeStream(ctx, text, ch, func(audioData []byte) {
+			if !started {
+				send(current, chunkStart+int64(align.Weight(chunks[current])*rate()), true)
+				started = true
 			}
+			received += int64(len(audioData))
+		}, func(i int) {
+			chunkEnd := received / glmBytesPerSample
+			send(i, chunkEnd, false)
+			doneWeight += align.Weight(chunks[i])
+			chunkStart = chunkEnd
+			chunkOffset += len(chunks[i])
+			current, started = i+1, false
+		})
+	}()
 
-			// 手动读取 SSE 流（因为行可能很长，超过 scanner 的默认缓冲区）
-			reader := bufio.NewReader(resp.Body)
+	return ch, segments
+}
 
-			for {
-				line, err := reader.ReadString('\n')
-				if err != nil {
-					break
-				}
+// synthesizeStream streams the audio of already normalized text into ch.
+// Chunks are requested in parallel but emitted in order: the first chunk
+// plays while it downloads and later chunks are fetched ahead of time.
+// A failed chunk ends the stream early, which is logged. onAudio sees
+// each piece before it is sent.
+func (g *GLM) synthesizeStream(ctx context.Context, text string, ch chan<- []byte, onAudio func([]byte), chunkDone func(int)) {
+	err := synthesizeOrdered(ctx, splitText(text), g.concurrency, g.streamChunk, func(audioData []byte) error {
+		if onAudio != nil {
+			onAudio(audioData)
+		}
+		// 发送到 channel（需要检查 context 是否已取消）
+		select {
+		case ch <- audioData:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}, chunkDone)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[GLM] Synthesis failed, audio cut short: %v", err)
+	}
+}
 
-				line = strings.TrimSuffix(line, "\n")
+// newRequest builds the HTTP request for one text chunk
+func (g *GLM) newRequest(ctx context.Context, chunk, format string, stream bool) (*http.Request, error) {
+	reqBody := glmRequest{
+		Model:          "glm-tts",
+		Input:          chunk,
+		Voice:          g.voice,
+		ResponseFormat: format,
+		Stream:         stream,
+	}
+	if stream {
+		reqBody.EncodeFormat = "base64"
+	}
+	if speed := g.Speed(); speed != 1.0 {
+		reqBody.Speed = speed
+	}
+	if volume := g.Volume(); volume != 1.0 {
+		reqBody.Volume = volume
+	}
 
-				// SSE 格式: "data: {json}"
-				if !strings.HasPrefix(line, "data: ") {
-					continue
-				}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
 
-				jsonStr := strings.TrimPrefix(line, "data: ")
-				if jsonStr == "" || jsonStr == "[DONE]" {
-					continue
-				}
+	req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
 
-				// 解析 JSON，提取 content 字段
-				var data struct {
-					Choices []struct {
-						Delta struct {
-							Content string `json:"content"`
-						} `json:"delta"`
-					} `json:"choices"`
-				}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	return req, nil
+}
 
-				if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
-					continue
-				}
+// do sends the request and checks the response status
+func (g *GLM) do(req *http.Request) (*http.Response, error) {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
 
-				if len(data.Choices) == 0 {
-					continue
-				}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
 
-				base64Data := data.Choices[0].Delta.Content
-				if base64Data == "" {
-					continue
-				}
+// streamChunk synthesizes one chunk as streaming PCM, pushing each decoded
+// SSE event into buf as it arrives
+func (g *GLM) streamChunk(ctx context.Context, chunk string, buf *chunkBuffer) error {
+	req, err := g.newRequest(ctx, chunk, "pcm", true)
+	if err != nil {
+		return err
+	}
 
-				// 解码 base64
-				audioData, err := base64.StdEncoding.DecodeString(base64Data)
-				if err != nil {
-					continue
-				}
+	resp, err := g.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-				// 发送到 channel（需要检查 context 是否已取消）
-				select {
-				case ch <- audioData:
-				case <-ctx.Done():
-					resp.Body.Close()
-					return
-				}
-			}
+	// 手动读取 SSE 流（因为行可能很长，超过 scanner 的默认缓冲区）
+	reader := bufio.NewReader(resp.Body)
 
-			resp.Body.Close()
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read stream: %w", err)
 		}
-	}()
 
-	return ch
-}
+		line = strings.TrimSuffix(line, "\n")
 
-// Synthesize converts text to audio (non-streaming, returns all data at once)
-func (g *GLM) Synthesize(ctx context.Context, text string) ([]byte, error) {
-	if len(text) == 0 {
-		return nil, fmt.Errorf("empty text")
-	}
-
-	// 分段处理长文本
-	chunks := splitText(text)
-	var allAudioData []byte
-
-	for _, chunk := range chunks {
-		reqBody := glmRequest{
-			Model:          "glm-tts",
-			Input:          chunk,
-			Voice:          g.voice,
-			ResponseFormat: "wav",
-			Stream:         false,
+		// SSE 格式: "data: {json}"
+		if !strings.HasPrefix(line, "data: ") {
+			continue
 		}
 
-		jsonData, err := json.Marshal(reqBody)
-		if err != nil {
-			return nil, fmt.Errorf("marshal request: %w", err)
+		jsonStr := strings.TrimPrefix(line, "data: ")
+		if jsonStr == "" || jsonStr == "[DONE]" {
+			continue
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL, strings.NewReader(string(jsonData)))
-		if err != nil {
-			return nil, fmt.Errorf("create request: %w", err)
+		// 解析 JSON，提取 content 字段
+		var data struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
 		}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+g.apiKey)
+		if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+			continue
+		}
 
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("send request: %w", err)
+		if len(data.Choices) == 0 {
+			continue
 		}
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			return nil, fmt.Errorf("API error: status=%d, body=%s", resp.StatusCode, string(body))
+		base64Data := data.Choices[0].Delta.Content
+		if base64Data == "" {
+			continue
 		}
 
-		audioData, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
+		// 解码 base64
+		audioData, err := base64.StdEncoding.DecodeString(base64Data)
 		if err != nil {
-			return nil, fmt.Errorf("read response: %w", err)
+			continue
 		}
 
-		allAudioData = append(allAudioData, audioData...)
+		buf.push(audioData)
+	}
+}
+
+// fetchChunk synthesizes one chunk as a complete WAV file
+func (g *GLM) fetchChunk(ctx context.Context, chunk string, buf *chunkBuffer) error {
+	req, err := g.newRequest(ctx, chunk, "wav", false)
+	if err != nil {
+		return err
+	}
+
+	resp, err := g.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	audioData, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+
+	buf.push(audioData)
+	return nil
+}
+
+// Synthesize converts text to audio (non-streaming, returns all data at once)
+func (g *GLM) Synthesize(ctx context.Context, text string) ([]byte, error) {
+	text = g.normalize(text)
+	if len(text) == 0 {
+		return nil, fmt.Errorf("empty text")
+	}
+
+	// 分段并发处理长文本，每段都是完整的 WAV 文件
+	var files [][]byte
+	err := synthesizeOrdered(ctx, splitText(text), g.concurrency, g.fetchChunk, func(audioData []byte) error {
+		files = append(files, audioData)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// 不能直接拼接字节，否则会有多个 RIFF 头且长度错误
+	allAudioData, err := wav.Merge(files...)
+	if err != nil {
+		return nil, fmt.Errorf("merge wav: %w", err)
 	}
 
 	return allAudioData, nil
@@ -1,11 +1,25 @@
 package tts
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/align"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
 )
 
 func TestGLM_SynthesizeStream(t *testing.T) {
@@ -123,3 +137,323 @@ func TestSplitText(t *testing.T) {
 		})
 	}
 }
+
+// newTestGLM creates a GLM client pointed at a local test server
+func newTestGLM(url string) *GLM {
+	glm := NewGLM("test-key")
+	glm.baseURL = url
+	glm.SetNormalizer(nil)
+	return glm
+}
+
+// sseEvent encodes audio as one GLM-TTS stream event
+func sseEvent(audio []byte) string {
+	return fmt.Sprintf("data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", base64.StdEncoding.EncodeToString(audio))
+}
+
+func TestGLM_SynthesizeStream_ParallelOrdered(t *testing.T) {
+	var inFlight, maxInFlight, arrivals int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req glmRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		cur := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if cur <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, cur) {
+				break
+			}
+		}
+
+		// 第一个到达的请求最慢，后面的段会先下载完
+		if atomic.AddInt32(&arrivals, 1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+		} else {
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		// 把输入文本分两次作为"音频"返回，方便校验顺序
+		half := len(req.Input) / 2
+		fmt.Fprint(w, sseEvent([]byte(req.Input[:half])))
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, sseEvent([]byte(req.Input[half:])))
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	glm := newTestGLM(server.URL)
+	glm.SetConcurrency(2)
+
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		sb.WriteString(fmt.Sprintf("第%d段：", i))
+		sb.WriteString(strings.Repeat("这是用于测试并发合成的句子。", 30))
+	}
+	text := sb.String()
+	chunks := splitText(text)
+	if len(chunks) < 4 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var got []byte
+	for piece := range glm.SynthesizeStream(ctx, text) {
+		got = append(got, piece...)
+	}
+
+	if string(got) != text {
+		t.Errorf("audio out of order or incomplete: got %d bytes, want %d", len(got), len(text))
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 2 {
+		t.Errorf("max in-flight requests = %d, want <= 2", max)
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max < 2 {
+		t.Errorf("chunks were not synthesized in parallel (max in-flight %d)", max)
+	}
+}
+
+func TestGLM_SynthesizeStream_CancelStopsInFlight(t *testing.T) {
+	var started, cancelled int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&started, 1)
+		fmt.Fprint(w, sseEvent([]byte("pcm")))
+		w.(http.Flusher).Flush()
+
+		// 模拟一直没有合成完的请求
+		select {
+		case <-r.Context().Done():
+			atomic.AddInt32(&cancelled, 1)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	glm := newTestGLM(server.URL)
+	glm.SetConcurrency(3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := glm.SynthesizeStream(ctx, strings.Repeat("这是一段很长的文本，用于测试取消。", 200))
+
+	if _, ok := <-stream; !ok {
+		t.Fatal("expected first audio piece")
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for range stream {
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not close after cancellation")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&cancelled) < atomic.LoadInt32(&started) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if s, c := atomic.LoadInt32(&started), atomic.LoadInt32(&cancelled); c != s {
+		t.Errorf("%d requests started but only %d were cancelled", s, c)
+	}
+}
+
+func TestGLM_SynthesizeStream_StopsAtFailedChunk(t *testing.T) {
+	var arrivals int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req glmRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasPrefix(req.Input, "B") {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		atomic.AddInt32(&arrivals, 1)
+		fmt.Fprint(w, sseEvent([]byte(req.Input[:1])))
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	glm := newTestGLM(server.URL)
+	text := "A" + strings.Repeat("a", 1000) + "." + "B" + strings.Repeat("b", 1000) + "." + "C" + strings.Repeat("c", 10)
+
+	var got []byte
+	for piece := range glm.SynthesizeStream(context.Background(), text) {
+		got = append(got, piece...)
+	}
+
+	// 第二段失败后不能跳过它继续播放后面的内容
+	if string(got) != "A" {
+		t.Errorf("got %q, want only the audio before the failed chunk", got)
+	}
+	// 失败要记录下来，不能像正常结束一样悄无声息
+	if !strings.Contains(logs.String(), "[GLM] Synthesis failed") {
+		t.Errorf("failure not logged, log: %q", logs.String())
+	}
+}
+
+func TestGLM_Synthesize_MergesWAV(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req glmRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.ResponseFormat != "wav" || req.Stream {
+			t.Errorf("unexpected request: format=%s stream=%v", req.ResponseFormat, req.Stream)
+		}
+
+		// 每段返回一个完整的 WAV，样本值用输入的第一个字节标记
+		data, _ := wav.Encode(wav.PCM16(24000, 1), []byte{req.Input[0], 0, req.Input[0], 0})
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	glm := newTestGLM(server.URL)
+	text := "A" + strings.Repeat("a", 1000) + "." + "B" + strings.Repeat("b", 1000) + "." + "C" + strings.Repeat("c", 10)
+
+	data, err := glm.Synthesize(context.Background(), text)
+	if err != nil {
+		t.Fatalf("Synthesize failed: %v", err)
+	}
+
+	file, err := wav.Decode(data)
+	if err != nil {
+		t.Fatalf("result is not a valid WAV: %v", err)
+	}
+	if want := []byte{'A', 0, 'A', 0, 'B', 0, 'B', 0}; string(file.Data) != string(want) {
+		t.Errorf("merged data %v, want %v", file.Data, want)
+	}
+}
+
+func TestGLM_Synthesize_FormatMismatch(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rate := 24000
+		if atomic.AddInt32(&calls, 1) > 1 {
+			rate = 16000
+		}
+		data, _ := wav.Encode(wav.PCM16(rate, 1), []byte{0, 0})
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	glm := newTestGLM(server.URL)
+	glm.SetConcurrency(1)
+	text := strings.Repeat("a", 1000) + "." + strings.Repeat("b", 1000)
+
+	if _, err := glm.Synthesize(context.Background(), text); !errors.Is(err, wav.ErrFormatMismatch) {
+		t.Errorf("expected ErrFormatMismatch, got %v", err)
+	}
+}
+
+func TestGLM_SynthesizeAligned(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req glmRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		// 每个字节的输入对应 10 个采样点（20 字节 PCM）
+		fmt.Fprint(w, sseEvent(make([]byte, len(req.Input)*20)))
+	}))
+	defer server.Close()
+
+	glm := newTestGLM(server.URL)
+	text := strings.Repeat("第一句话。", 150) + strings.Repeat("第二句。", 10)
+	chunks := splitText(text)
+	if len(chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(chunks))
+	}
+
+	audio, segments := glm.SynthesizeAligned(context.Background(), text)
+
+	// 第一段音频到达时，它的句子已有估计时间
+	first := len(align.SplitSentences(chunks[0]))
+	var order []bool // 依次收到的段是否为估计
+	var got, estimates []ai.Segment
+	collect := func(seg ai.Segment) {
+		order = append(order, seg.Estimated)
+		if seg.Estimated {
+			estimates = append(estimates, seg)
+		} else {
+			got = append(got, seg)
+		}
+	}
+	var totalBytes int
+	for piece := range audio {
+		if totalBytes == 0 {
+			for len(segments) > 0 {
+				collect(<-segments)
+			}
+			if len(estimates) < first {
+				t.Errorf("%d estimates with the first audio, want at least %d", len(estimates), first)
+			}
+		}
+		totalBytes += len(piece)
+	}
+	for seg := range segments {
+		collect(seg)
+	}
+
+	// 每段先发估计，再发最终时间
+	var want []bool
+	for _, chunk := range chunks {
+		n := len(align.SplitSentences(chunk))
+		want = append(append(want, slices.Repeat([]bool{true}, n)...), slices.Repeat([]bool{false}, n)...)
+	}
+	if !slices.Equal(order, want) {
+		t.Errorf("segments not sent as estimates then final timing per chunk")
+	}
+
+	if len(got) != 160 || len(estimates) != 160 {
+		t.Fatalf("got %d final and %d estimated segments, want one of each per sentence (160)", len(got), len(estimates))
+	}
+	// 第一段之后按实测语速估计，与最终时间相差不大
+	for i, seg := range estimates {
+		final := got[i]
+		if seg.Text != final.Text || seg.Start != final.Start || seg.End != final.End {
+			t.Fatalf("estimate %d %+v is not for the text of %+v", i, seg, final)
+		}
+		if diff := seg.EndSample - final.EndSample; i >= first && max(diff, -diff) > final.EndSample/20 {
+			t.Errorf("estimate %d ends at %d, final at %d", i, seg.EndSample, final.EndSample)
+		}
+	}
+
+	// 每段音频结束处必须正好是某一句的结束
+	chunkEnds := make(map[int64]bool)
+	var samples int64
+	for _, chunk := range chunks {
+		samples += int64(len(chunk) * 10)
+		chunkEnds[samples] = true
+	}
+
+	var prevEnd int64
+	for i, seg := range got {
+		if text[seg.Start:seg.End] != seg.Text {
+			t.Fatalf("segment %d span %d:%d does not match text %q", i, seg.Start, seg.End, seg.Text)
+		}
+		if seg.StartSample != prevEnd || seg.EndSample <= seg.StartSample {
+			t.Fatalf("segment %d samples [%d, %d) not contiguous after %d", i, seg.StartSample, seg.EndSample, prevEnd)
+		}
+		if seg.SampleRate != glmSampleRate {
+			t.Errorf("segment %d sample rate %d", i, seg.SampleRate)
+		}
+		delete(chunkEnds, seg.EndSample)
+		prevEnd = seg.EndSample
+	}
+
+	if want := int64(totalBytes / glmBytesPerSample); prevEnd != want {
+		t.Errorf("last segment ends at %d, want %d", prevEnd, want)
+	}
+	if len(chunkEnds) != 0 {
+		t.Errorf("chunk boundaries %v do not line up with segment ends", chunkEnds)
+	}
+}
@@ -0,0 +1,148 @@
+package tts
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// chunkBuffer collects the audio pieces of one text chunk while it is being
+// fetched, so later chunks can download ahead of the one being played
+type chunkBuffer struct {
+	mu     sync.Mutex
+	pieces [][]byte
+	done   bool
+	err    error
+	ready  chan struct{}
+}
+
+func newChunkBuffer() *chunkBuffer {
+	return &chunkBuffer{ready: make(chan struct{}, 1)}
+}
+
+// push appends a piece of audio
+func (b *chunkBuffer) push(piece []byte) {
+	b.mu.Lock()
+	b.pieces = append(b.pieces, piece)
+	b.mu.Unlock()
+	b.signal()
+}
+
+// finish marks the chunk as complete, err is reported after the buffered pieces
+func (b *chunkBuffer) finish(err error) {
+	b.mu.Lock()
+	if !b.done {
+		b.done = true
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.signal()
+}
+
+func (b *chunkBuffer) signal() {
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next blocks until a piece is available. It returns io.EOF once the chunk
+// finished successfully and all pieces were consumed.
+func (b *chunkBuffer) next(ctx context.Context) ([]byte, error) {
+	for {
+		b.mu.Lock()
+		if len(b.pieces) > 0 {
+			piece := b.pieces[0]
+			b.pieces[0] = nil
+			b.pieces = b.pieces[1:]
+			b.mu.Unlock()
+			return piece, nil
+		}
+		if b.done {
+			err := b.err
+			b.mu.Unlock()
+			if err == nil {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-b.ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// fetchFunc downloads the audio of one text chunk into buf
+type fetchFunc func(ctx context.Context, chunk string, buf *chunkBuffer) error
+
+// synthesizeOrdered fetches chunks with at most concurrency of them in flight
+// and calls emit with their audio strictly in chunk order. The first chunk is
+// emitted while it is still downloading; a slot is only freed once a chunk
+// has been fully emitted, which also bounds how much audio is buffered ahead.
+// chunkDone, if not nil, is called with the index of every chunk after its
+// last piece was emitted. Any error, or emit returning one, cancels all
+// in-flight requests.
+func synthesizeOrdered(ctx context.Context, chunks []string, concurrency int, fetch fetchFunc, emit func([]byte) error, chunkDone func(int)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	// 先取消再等待，保证所有请求 goroutine 都已退出
+	defer wg.Wait()
+	defer cancel()
+
+	bufs := make([]*chunkBuffer, len(chunks))
+	for i := range bufs {
+		bufs[i] = newChunkBuffer()
+	}
+
+	slots := make(chan struct{}, concurrency)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i, chunk := range chunks {
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+				for _, buf := range bufs[i:] {
+					buf.finish(ctx.Err())
+				}
+				return
+			}
+
+			wg.Add(1)
+			go func(buf *chunkBuffer, chunk string) {
+				defer wg.Done()
+				buf.finish(fetch(ctx, chunk, buf))
+			}(bufs[i], chunk)
+		}
+	}()
+
+	for i, buf := range bufs {
+		for {
+			piece, err := buf.next(ctx)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if err := emit(piece); err != nil {
+				return err
+			}
+		}
+		if chunkDone != nil {
+			chunkDone(i)
+		}
+		<-slots
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package turn
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// completenessPrompt asks the LLM for a one-word verdict
+const completenessPrompt = `A listener is speaking to a podcast host and paused. Has the listener finished their question or remark, or are they likely to continue? Answer with exactly one word: "complete" or "incomplete".
+
+Listener so far: %q`
+
+// LLMClassifier asks an LLM whether an utterance is finished. Only the
+// first words of the answer are read, so a small, fast model is enough.
+type LLMClassifier struct {
+	llm ai.LLMEngine
+}
+
+// NewLLMClassifier creates a classifier backed by llm
+func NewLLMClassifier(llm ai.LLMEngine) *LLMClassifier {
+	return &LLMClassifier{llm: llm}
+}
+
+// Complete reports whether the LLM judges text to be a finished utterance
+func (c *LLMClassifier) Complete(ctx context.Context, text string) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var answer strings.Builder
+	for chunk := range c.llm.GenerateResponse(ctx, fmt.Sprintf(completenessPrompt, text), "") {
+		answer.WriteString(chunk)
+		word := strings.ToLower(strings.TrimSpace(answer.String()))
+		switch {
+		case strings.HasPrefix(word, "incomplete"):
+			return false, nil
+		case strings.HasPrefix(word, "complete"):
+			return true, nil
+		case len(word) >= len("incomplete"):
+			return false, fmt.Errorf("unexpected classifier answer %q", word)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return false, fmt.Errorf("unexpected classifier answer %q", answer.String())
+}
@@ -0,0 +1,146 @@
+// Package turn decides when the listener has finished speaking, combining
+// the silence reported by the VAD with how complete the transcript sounds.
+package turn
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Completeness is how finished an utterance sounds
+type Completeness int
+
+const (
+	Unknown    Completeness = iota // no strong cue either way
+	Incomplete                     // trails off: a conjunction, a filler or "..."
+	Complete                       // a finished question or sentence
+)
+
+func (c Completeness) String() string {
+	switch c {
+	case Incomplete:
+		return "Incomplete"
+	case Complete:
+		return "Complete"
+	default:
+		return "Unknown"
+	}
+}
+
+// trailing words after which the speaker is still going
+var (
+	englishTrailing = map[string]bool{
+		// hesitations
+		"um": true, "uh": true, "er": true, "erm": true, "hmm": true, "like": true,
+		// conjunctions
+		"and": true, "but": true, "or": true, "so": true, "because": true, "if": true,
+		"then": true, "which": true, "while": true,
+		// articles and prepositions
+		"the": true, "a": true, "an": true, "of": true, "to": true, "with": true,
+		"about": true, "for": true, "in": true, "on": true, "my": true,
+	}
+	chineseTrailing = []string{
+		"嗯", "呃", "那个", "就是", "这个",
+		"和", "跟", "但是", "可是", "然后", "所以", "因为", "如果", "而且", "或者", "还有", "的话",
+	}
+)
+
+// complete on their own: "why?", "really", "为什么"
+var (
+	shortQuestions   = map[string]bool{"why": true, "how": true, "what": true, "really": true, "seriously": true, "where": true, "when": true, "who": true}
+	chineseParticles = []string{"吗", "呢", "么", "为什么", "怎么样", "是吧", "对吧"}
+)
+
+// Analyze judges from the text alone whether an utterance is finished.
+// Punctuation comes from the recognizer, so a question mark counts as a
+// strong cue and a period as a weaker one.
+func Analyze(text string) Completeness {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Unknown
+	}
+	if strings.HasSuffix(text, "...") || strings.HasSuffix(text, "…") || strings.HasSuffix(text, "——") {
+		return Incomplete
+	}
+
+	body := strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+	end := []rune(text)[len([]rune(text))-1]
+	if end == '?' || end == '？' {
+		return Complete
+	}
+
+	words := strings.Fields(strings.ToLower(body))
+	if len(words) == 0 {
+		return Unknown
+	}
+	last := strings.TrimFunc(words[len(words)-1], unicode.IsPunct)
+	if englishTrailing[last] {
+		return Incomplete
+	}
+	for _, w := range chineseTrailing {
+		if strings.HasSuffix(body, w) {
+			return Incomplete
+		}
+	}
+
+	for _, p := range chineseParticles {
+		if strings.HasSuffix(body, p) {
+			return Complete
+		}
+	}
+	if len(words) == 1 && shortQuestions[last] {
+		return Complete
+	}
+	switch end {
+	case '.', '!', '。', '！':
+		return Complete
+	}
+	return Unknown
+}
+
+// words that open a question
+var (
+	questionOpeners = map[string]bool{
+		"what": true, "why": true, "how": true, "when": true, "where": true, "who": true, "which": true,
+		"is": true, "are": true, "was": true, "do": true, "does": true, "did": true,
+		"can": true, "could": true, "would": true, "should": true, "will": true,
+		"wait": true, "sorry": true, "pardon": true,
+	}
+	chineseQuestionWords = []string{"什么", "为什么", "怎么", "哪", "谁", "多少", "几", "是不是", "能不能", "有没有"}
+)
+
+// IsQuestion judges from the text alone whether an utterance asks
+// something, as opposed to a backchannel like "mm-hm" or "对" or a cough
+// transcribed as noise
+func IsQuestion(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return false
+	}
+	if strings.HasSuffix(text, "?") || strings.HasSuffix(text, "？") {
+		return true
+	}
+
+	body := strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+	for _, p := range chineseParticles {
+		if strings.HasSuffix(body, p) {
+			return true
+		}
+	}
+	for _, w := range chineseQuestionWords {
+		if strings.Contains(body, w) {
+			return true
+		}
+	}
+
+	words := strings.Fields(strings.ToLower(body))
+	if len(words) == 0 {
+		return false
+	}
+	first := strings.TrimFunc(words[0], unicode.IsPunct)
+	return questionOpeners[first] || shortQuestions[first] && len(words) == 1
+}
@@ -0,0 +1,213 @@
+package turn
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Detector defaults
+const (
+	defaultCompleteSilence   = 250 * time.Millisecond  // after "why?"
+	defaultUnknownSilence    = 800 * time.Millisecond  // no cue in the transcript
+	defaultIncompleteSilence = 2500 * time.Millisecond // after "and, um..."
+	defaultClassifierBudget  = 300 * time.Millisecond
+)
+
+// Classifier judges whether an utterance is finished when the text
+// heuristics are not sure
+type Classifier interface {
+	Complete(ctx context.Context, text string) (bool, error)
+}
+
+// Detector ends the listener's turn once they have been silent long
+// enough. How long depends on the latest transcript: a finished question
+// ends the turn almost immediately, while a trailing conjunction or
+// hesitation waits much longer.
+//
+// Feed it every VAD event with Speech and every partial transcript with
+// Partial; Watch returns a context that is cancelled when the turn ends.
+type Detector struct {
+	silence    [3]time.Duration // indexed by Completeness
+	classifier Classifier
+	budget     time.Duration
+	clock      ai.Clock
+
+	mu          sync.Mutex
+	speaking    bool
+	silentSince time.Time
+	text        string
+	verdict     Completeness
+	classifying string // text sent to the classifier, if any
+	timer       ai.Timer
+	end         context.CancelFunc // ends the watched turn, nil when none
+	turn        int                // counts watched turns
+}
+
+// NewDetector creates a detector using text heuristics only
+func NewDetector() *Detector {
+	d := &Detector{budget: defaultClassifierBudget, clock: ai.SystemClock}
+	d.silence[Complete] = defaultCompleteSilence
+	d.silence[Unknown] = defaultUnknownSilence
+	d.silence[Incomplete] = defaultIncompleteSilence
+	return d
+}
+
+// SetSilence sets the silence that ends a turn for each kind of transcript
+func (d *Detector) SetSilence(complete, unknown, incomplete time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.silence[Complete] = complete
+	d.silence[Unknown] = unknown
+	d.silence[Incomplete] = incomplete
+}
+
+// SetClock sets the clock measuring silence, the system clock by default
+func (d *Detector) SetClock(c ai.Clock) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.clock = c
+}
+
+// SetClassifier consults c for transcripts the heuristics cannot judge.
+// Answers taking longer than budget are ignored.
+func (d *Detector) SetClassifier(c Classifier, budget time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.classifier = c
+	d.budget = budget
+}
+
+// Speech records a VAD event. Silence is measured from where speech
+// ended, not from when the VAD noticed.
+func (d *Detector) Speech(ev ai.VADEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch ev.Type {
+	case ai.SpeechStart:
+		d.speaking = true
+		d.stopTimer()
+	case ai.SpeechEnd:
+		d.speaking = false
+		d.silentSince = ev.Time.Add(-ev.Delay())
+		d.arm()
+	}
+}
+
+// Partial records the latest transcript of the current turn
+func (d *Detector) Partial(text string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	text = strings.TrimSpace(text)
+	if text == d.text {
+		return
+	}
+	d.text = text
+	d.verdict = Analyze(text)
+	if d.verdict == Unknown && d.classifier != nil && d.end != nil {
+		d.classify(text)
+	}
+	d.arm()
+}
+
+// Watch starts a new turn and returns a context cancelled when it ends or
+// ctx is done. The returned cancel function stops watching.
+func (d *Detector) Watch(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.end != nil {
+		d.end()
+	}
+	d.stopTimer()
+	d.turn++
+	turn := d.turn
+	d.end = cancel
+	d.text = ""
+	d.verdict = Unknown
+	d.classifying = ""
+	if !d.speaking {
+		d.silentSince = d.clock.Now()
+		d.arm()
+	}
+
+	return ctx, func() {
+		d.mu.Lock()
+		if d.turn == turn && d.end != nil {
+			d.stopTimer()
+			d.end = nil
+		}
+		d.mu.Unlock()
+		cancel()
+	}
+}
+
+// arm schedules the end of the turn for the current transcript, counting
+// the silence already elapsed
+func (d *Detector) arm() {
+	if d.end == nil || d.speaking {
+		return
+	}
+	d.stopTimer()
+	wait := d.silence[d.verdict] - d.clock.Now().Sub(d.silentSince)
+	d.timer = d.clock.AfterFunc(max(wait, 0), d.fire)
+}
+
+func (d *Detector) stopTimer() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
+
+func (d *Detector) fire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// The transcript or speech may have changed since the timer was set
+	silent := d.clock.Now().Sub(d.silentSince)
+	if d.end == nil || d.speaking || silent < d.silence[d.verdict] {
+		return
+	}
+	log.Printf("[Turn] End of turn after %v of silence (%s: %q)", silent.Round(time.Millisecond), d.verdict, d.text)
+	d.end()
+	d.end = nil
+	d.timer = nil
+}
+
+// classify asks the classifier about text in the background. Until it
+// answers the unknown silence applies, so a slow classifier costs nothing.
+func (d *Detector) classify(text string) {
+	if d.classifying == text {
+		return
+	}
+	d.classifying = text
+	c, budget, clock := d.classifier, d.budget, d.clock
+
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		timeout := clock.AfterFunc(budget, cancel)
+		defer timeout.Stop()
+		complete, err := c.Complete(ctx, text)
+
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		if err != nil || d.text != text {
+			return
+		}
+		if complete {
+			d.verdict = Complete
+		} else {
+			d.verdict = Incomplete
+		}
+		d.arm()
+	}()
+}
@@ -0,0 +1,266 @@
+package turn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/synctest"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai/aitest"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		text string
+		want Completeness
+	}{
+		{"why?", Complete},
+		{"Why", Complete},
+		{"为什么？", Complete},
+		{"这个观点有什么依据", Unknown},
+		{"这个观点有依据吗", Complete},
+		{"How does it scale to, um...", Incomplete},
+		{"I was wondering about the, um", Incomplete},
+		{"what about the cost and", Incomplete},
+		{"我想问一下，就是", Incomplete},
+		{"因为我觉得这个观点，但是", Incomplete},
+		{"So?", Complete},
+		{"That makes sense.", Complete},
+		{"why is that", Unknown},
+		{"", Unknown},
+	}
+	for _, tt := range tests {
+		if got := Analyze(tt.text); got != tt.want {
+			t.Errorf("Analyze(%q) = %s, want %s", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIsQuestion(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"why?", true},
+		{"Really", true},
+		{"What do you mean by that", true},
+		{"Wait, is that true", true},
+		{"这个有依据吗", true},
+		{"为什么这么说", true},
+		{"mm-hm", false},
+		{"Mm-hmm.", false},
+		{"yeah", false},
+		{"对对对", false},
+		{"嗯", false},
+		{"(coughs)", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsQuestion(tt.text); got != tt.want {
+			t.Errorf("IsQuestion(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+// testDetector 使用很短的静音阈值，方便测试
+func testDetector() *Detector {
+	d := NewDetector()
+	d.SetSilence(20*time.Millisecond, 80*time.Millisecond, 300*time.Millisecond)
+	return d
+}
+
+func speech(typ ai.VADEventType) ai.VADEvent {
+	return ai.VADEvent{Type: typ, Time: time.Now()}
+}
+
+// turnLength 测量从说话结束到本轮结束的时间
+func turnLength(t *testing.T, d *Detector, partial string) time.Duration {
+	t.Helper()
+	d.Speech(speech(ai.SpeechStart))
+	ctx, cancel := d.Watch(context.Background())
+	defer cancel()
+
+	d.Partial(partial)
+	start := time.Now()
+	d.Speech(speech(ai.SpeechEnd))
+
+	select {
+	case <-ctx.Done():
+		return time.Since(start)
+	case <-time.After(2 * time.Second):
+		t.Fatal("turn never ended")
+		return 0
+	}
+}
+
+func TestDetector_SilenceByCompleteness(t *testing.T) {
+	d := testDetector()
+
+	short := turnLength(t, d, "why?")
+	unknown := turnLength(t, d, "why is that")
+	long := turnLength(t, d, "why is that, um...")
+
+	if short >= 80*time.Millisecond {
+		t.Errorf("complete question waited %v", short)
+	}
+	if unknown < 80*time.Millisecond || unknown >= 300*time.Millisecond {
+		t.Errorf("unknown utterance waited %v, want about 80ms", unknown)
+	}
+	if long < 300*time.Millisecond {
+		t.Errorf("trailing hesitation waited only %v", long)
+	}
+}
+
+func TestDetector_Clock(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		clock := aitest.NewClock()
+		d := NewDetector()
+		d.SetClock(clock)
+		d.Speech(ai.VADEvent{Type: ai.SpeechStart, Time: clock.Now()})
+		ctx, cancel := d.Watch(context.Background())
+		defer cancel()
+
+		// 静音按虚拟时钟计算：未到阈值不结束，到了立即结束
+		d.Partial("why is that")
+		d.Speech(ai.VADEvent{Type: ai.SpeechEnd, Time: clock.Now()})
+		clock.Advance(defaultUnknownSilence - time.Millisecond)
+		synctest.Wait()
+		if ctx.Err() != nil {
+			t.Fatal("turn ended before the silence was long enough")
+		}
+		clock.Advance(time.Millisecond)
+		synctest.Wait()
+		if ctx.Err() == nil {
+			t.Fatal("turn did not end after the silence")
+		}
+	})
+}
+
+func TestDetector_SpeechResumes(t *testing.T) {
+	d := testDetector()
+	d.Speech(speech(ai.SpeechStart))
+	ctx, cancel := d.Watch(context.Background())
+	defer cancel()
+
+	// 停顿后继续说话，本轮不应结束
+	d.Partial("I think, um")
+	d.Speech(speech(ai.SpeechEnd))
+	time.Sleep(150 * time.Millisecond)
+	d.Speech(speech(ai.SpeechStart))
+	time.Sleep(200 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("turn ended while the user kept speaking")
+	}
+
+	d.Partial("I think, um, what's the source?")
+	d.Speech(speech(ai.SpeechEnd))
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("turn did not end after the question")
+	}
+}
+
+func TestDetector_PartialAfterSilence(t *testing.T) {
+	d := testDetector()
+	d.Speech(speech(ai.SpeechStart))
+	ctx, cancel := d.Watch(context.Background())
+	defer cancel()
+
+	// 转写晚于静音到达时，已过去的静音也计入
+	d.Partial("and")
+	d.Speech(speech(ai.SpeechEnd))
+	time.Sleep(50 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("turn ended during a trailing conjunction")
+	}
+	start := time.Now()
+	d.Partial("and that's it.")
+	<-ctx.Done()
+	if waited := time.Since(start); waited > 20*time.Millisecond {
+		t.Errorf("waited %v more after the late transcript", waited)
+	}
+}
+
+type fakeClassifier struct {
+	complete bool
+	delay    time.Duration
+	err      error
+}
+
+func (f fakeClassifier) Complete(ctx context.Context, text string) (bool, error) {
+	select {
+	case <-time.After(f.delay):
+		return f.complete, f.err
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+func TestDetector_Classifier(t *testing.T) {
+	tests := []struct {
+		name       string
+		classifier fakeClassifier
+		min, max   time.Duration
+	}{
+		{"complete", fakeClassifier{complete: true, delay: 5 * time.Millisecond}, 0, 60 * time.Millisecond},
+		{"incomplete", fakeClassifier{complete: false, delay: 5 * time.Millisecond}, 300 * time.Millisecond, time.Second},
+		{"over budget", fakeClassifier{complete: false, delay: time.Second}, 80 * time.Millisecond, 300 * time.Millisecond},
+		{"error", fakeClassifier{err: errors.New("offline")}, 80 * time.Millisecond, 300 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := testDetector()
+			d.SetClassifier(tt.classifier, 30*time.Millisecond)
+			got := turnLength(t, d, "why is that")
+			if got < tt.min || got > tt.max {
+				t.Errorf("turn ended after %v, want between %v and %v", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+// fakeLLM 以固定文本分块回答
+type fakeLLM struct {
+	answer []string
+}
+
+func (f fakeLLM) GenerateStream(ctx context.Context, prompt string) <-chan string {
+	return f.GenerateResponse(ctx, prompt, "")
+}
+
+func (f fakeLLM) GenerateResponse(ctx context.Context, question, context string) <-chan string {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for _, s := range f.answer {
+			select {
+			case ch <- s:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+func TestLLMClassifier(t *testing.T) {
+	tests := []struct {
+		answer  []string
+		want    bool
+		wantErr bool
+	}{
+		{[]string{"Comp", "lete"}, true, false},
+		{[]string{" Incomplete", "."}, false, false},
+		{[]string{"I think the listener is done"}, false, true},
+		{nil, false, true},
+	}
+	for _, tt := range tests {
+		got, err := NewLLMClassifier(fakeLLM{tt.answer}).Complete(context.Background(), "why is that")
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("answer %q: Complete() = %v, %v", tt.answer, got, err)
+		}
+	}
+}
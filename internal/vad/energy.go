@@ -0,0 +1,251 @@
+package vad
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// Energy VAD defaults
+const (
+	energyFrameTime    = 20 * time.Millisecond
+	defaultSensitivity = 0.5
+	defaultHangover    = 300 * time.Millisecond
+	defaultMinSpeech   = 150 * time.Millisecond
+
+	initialNoiseFloor = -60.0 // dBFS, a quiet room
+	maxSpeechFlatness = 0.45  // white noise sits around 0.56
+	maxSpeechZCR      = 0.45  // hiss crosses zero on almost every sample
+	continueMarginDB  = 3.0   // speech continues a little below the onset threshold
+
+	floorDropTime   = 60 * time.Millisecond // the floor follows quieter frames quickly
+	floorRiseTime   = 2 * time.Second       // and louder non-speech frames slowly
+	floorSpeechTime = 10 * time.Second      // so a steady hum is eventually absorbed
+)
+
+// Energy implements VADMonitor without a model. Each 20ms frame of PCM16
+// mono audio read from source is scored by energy above an adaptive noise
+// floor, zero-crossing rate and spectral flatness. Speech must last
+// minSpeech before it triggers, and ends after hangover of silence.
+type Energy struct {
+	source      io.Reader
+	sampleRate  int
+	sensitivity float64
+	hangover    time.Duration
+	minSpeech   time.Duration
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the monitor has exited
+}
+
+// NewEnergy creates an energy VAD reading PCM16 little-endian mono audio
+// at sampleRate from source
+func NewEnergy(source io.Reader, sampleRate int) *Energy {
+	return &Energy{
+		source:      source,
+		sampleRate:  sampleRate,
+		sensitivity: defaultSensitivity,
+		hangover:    defaultHangover,
+		minSpeech:   defaultMinSpeech,
+	}
+}
+
+// SetSensitivity sets the sensitivity [0, 1]. Higher values trigger on
+// quieter speech.
+func (e *Energy) SetSensitivity(sensitivity float64) {
+	e.sensitivity = math.Max(0, math.Min(1, sensitivity))
+}
+
+// SetHangover sets how long speech continues after the last speech frame
+func (e *Energy) SetHangover(d time.Duration) {
+	e.hangover = d
+}
+
+// SetMinSpeech sets how long speech must last before it triggers
+func (e *Energy) SetMinSpeech(d time.Duration) {
+	e.minSpeech = d
+}
+
+// Start begins monitoring for voice activity. Events are sent when speech
+// starts and ends; the channel is closed when the source ends or
+// monitoring stops. Starting again stops the previous monitor, and the
+// new one reads the source once the previous one has exited.
+func (e *Energy) Start(ctx context.Context) <-chan ai.VADEvent {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	e.mu.Lock()
+	prev := e.done
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.cancel, e.done = cancel, done
+	e.mu.Unlock()
+
+	events := make(chan ai.VADEvent, eventBuffer)
+	det := newEnergyDetector(e.sampleRate, e.sensitivity, e.hangover, e.minSpeech)
+
+	go func() {
+		defer close(done)
+		defer close(events)
+		defer cancel()
+
+		// Only one monitor reads the source at a time
+		if prev != nil {
+			select {
+			case <-prev:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		buf := make([]byte, det.frameSize*2)
+		frame := make([]float64, det.frameSize)
+		for ctx.Err() == nil {
+			if _, err := io.ReadFull(e.source, buf); err != nil {
+				return
+			}
+			for i := range frame {
+				frame[i] = float64(int16(binary.LittleEndian.Uint16(buf[i*2:]))) / 32768
+			}
+			if ev := det.process(frame); ev != nil && !sendEvent(ctx, events, ev) {
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// Stop stops the monitoring process. A read already blocked on the source
+// finishes before the monitor exits.
+func (e *Energy) Stop() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cancel != nil {
+		e.cancel()
+	}
+	return nil
+}
+
+// energyDetector is the frame-by-frame decision logic of Energy
+type energyDetector struct {
+	sampleRate  int
+	frameSize   int
+	thresholdDB float64 // onset threshold above the noise floor
+	minLevel    float64 // absolute minimum speech level in dBFS
+	hangover    int     // frames
+	minSpeech   int     // frames
+
+	floor        float64
+	dropCoef     float64
+	riseCoef     float64
+	speechCoef   float64
+	current      int64 // samples processed so far
+	speech       bool
+	speechFrames int   // consecutive speech-like frames before onset
+	silentFrames int   // frames since the last speech frame during speech
+	lastSpeech   int64 // end of the last speech frame
+}
+
+func newEnergyDetector(sampleRate int, sensitivity float64, hangover, minSpeech time.Duration) *energyDetector {
+	frameSize := int(float64(sampleRate) * energyFrameTime.Seconds())
+	frames := func(d time.Duration) int {
+		return int(math.Ceil(float64(d) / float64(energyFrameTime)))
+	}
+	coef := func(d time.Duration) float64 {
+		return 1 - math.Exp(-energyFrameTime.Seconds()/d.Seconds())
+	}
+
+	return &energyDetector{
+		sampleRate:  sampleRate,
+		frameSize:   frameSize,
+		thresholdDB: 20 - 14*sensitivity,
+		minLevel:    -40 - 20*sensitivity,
+		hangover:    frames(hangover),
+		minSpeech:   max(frames(minSpeech), 1),
+		floor:       initialNoiseFloor,
+		dropCoef:    coef(floorDropTime),
+		riseCoef:    coef(floorRiseTime),
+		speechCoef:  coef(floorSpeechTime),
+	}
+}
+
+// process scores one frame of samples in [-1, 1] and returns an event when
+// speech starts or ends with it
+func (d *energyDetector) process(frame []float64) *ai.VADEvent {
+	level := frameEnergy(frame)
+	flatness := spectralFlatness(frame)
+	onset := math.Max(d.floor+d.thresholdDB, d.minLevel)
+
+	// Noise is broadband and flat; voiced speech is harmonic
+	speechLike := level > onset &&
+		flatness < maxSpeechFlatness &&
+		zeroCrossingRate(frame) < maxSpeechZCR
+
+	d.updateFloor(level, speechLike)
+
+	start := d.current
+	d.current += int64(len(frame))
+	event := func(typ ai.VADEventType, offset int64) *ai.VADEvent {
+		return &ai.VADEvent{
+			Type:        typ,
+			Offset:      offset,
+			Detected:    d.current,
+			SampleRate:  d.sampleRate,
+			Probability: speechProbability(level-onset, flatness),
+		}
+	}
+
+	if d.speech {
+		// Unvoiced sounds inside speech only need the energy
+		if level > onset-continueMarginDB {
+			d.silentFrames = 0
+			d.lastSpeech = d.current
+		} else if d.silentFrames++; d.silentFrames >= d.hangover {
+			d.speech = false
+			d.speechFrames = 0
+			return event(ai.SpeechEnd, d.lastSpeech)
+		}
+		return nil
+	}
+
+	if !speechLike {
+		d.speechFrames = 0
+		return nil
+	}
+	d.speechFrames++
+	if d.speechFrames < d.minSpeech {
+		return nil
+	}
+	d.speech = true
+	d.silentFrames = 0
+	d.lastSpeech = d.current
+	return event(ai.SpeechStart, start-int64(d.speechFrames-1)*int64(d.frameSize))
+}
+
+// speechProbability maps the margin above the onset threshold and the
+// spectral flatness to a rough confidence in [0, 1]
+func speechProbability(marginDB, flatness float64) float32 {
+	energy := 1 / (1 + math.Exp(-marginDB/3))
+	tonal := math.Max(0, math.Min(1, 1-flatness/(2*maxSpeechFlatness)))
+	return float32(energy * tonal)
+}
+
+// updateFloor tracks the noise floor: quickly down, slowly up, and only
+// very slowly while speech may be present
+func (d *energyDetector) updateFloor(level float64, speechLike bool) {
+	switch {
+	case level < d.floor:
+		d.floor += (level - d.floor) * d.dropCoef
+	case d.speech || speechLike:
+		d.floor += (level - d.floor) * d.speechCoef
+	default:
+		d.floor += (level - d.floor) * d.riseCoef
+	}
+}
@@ -0,0 +1,352 @@
+package vad
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+const testRate = 16000
+
+func seconds(s float64) int {
+	return int(s * testRate)
+}
+
+func dbfs(db float64) float64 {
+	return math.Pow(10, db/20) * math.Sqrt2
+}
+
+func silence(n int) []float64 {
+	return make([]float64, n)
+}
+
+// whiteNoise 生成指定 RMS 电平的白噪声
+func whiteNoise(rng *rand.Rand, n int, db float64) []float64 {
+	amp := dbfs(db) / math.Sqrt2
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = rng.NormFloat64() * amp
+	}
+	return out
+}
+
+func tone(n int, freq, db float64) []float64 {
+	amp := dbfs(db)
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/testRate)
+	}
+	return out
+}
+
+// speechLike 合成类语音信号：基频缓慢变化的声门脉冲串经过两个共振峰滤波，
+// 按 4Hz 音节节奏调幅，音节之间夹杂短暂的摩擦音
+func speechLike(rng *rand.Rand, n int, db float64) []float64 {
+	out := make([]float64, n)
+	formants := [][2]float64{{700, 1200}, {400, 2000}, {550, 1700}, {300, 2300}}
+
+	type resonator struct{ a1, a2, y1, y2 float64 }
+	newResonator := func(freq float64) resonator {
+		r := math.Exp(-math.Pi * 100 / testRate)
+		return resonator{a1: 2 * r * math.Cos(2*math.Pi*freq/testRate), a2: -r * r}
+	}
+	var f1, f2 resonator
+
+	phase := 0.0
+	syllable := seconds(0.25)
+	for i := range out {
+		s := i / syllable
+		if i%syllable == 0 {
+			f := formants[s%len(formants)]
+			f1, f2 = newResonator(f[0]), newResonator(f[1])
+		}
+
+		pos := float64(i%syllable) / float64(syllable)
+		var x float64
+		if pos < 0.8 {
+			f0 := 120 + 30*math.Sin(2*math.Pi*float64(i)/float64(seconds(1.3)))
+			phase += f0 / testRate
+			if phase >= 1 {
+				phase--
+				x = 1
+			}
+		} else {
+			x = rng.NormFloat64() * 0.05
+		}
+
+		y1 := x + f1.a1*f1.y1 + f1.a2*f1.y2
+		f1.y2, f1.y1 = f1.y1, y1
+		y2 := x + f2.a1*f2.y1 + f2.a2*f2.y2
+		f2.y2, f2.y1 = f2.y1, y2
+
+		env := math.Sin(math.Pi * pos)
+		out[i] = (y1 + 0.5*y2) * env
+	}
+
+	// 归一化到目标电平
+	var sum float64
+	for _, x := range out {
+		sum += x * x
+	}
+	gain := dbfs(db) / math.Sqrt2 / math.Sqrt(sum/float64(n))
+	for i := range out {
+		out[i] *= gain
+	}
+	return out
+}
+
+func concat(parts ...[]float64) []float64 {
+	var out []float64
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func mix(a, b []float64) []float64 {
+	out := make([]float64, len(a))
+	for i := range out {
+		out[i] = a[i] + b[i]
+	}
+	return out
+}
+
+// detect 逐帧运行检测器，返回产生的全部事件
+func detect(d *energyDetector, samples []float64) []ai.VADEvent {
+	var events []ai.VADEvent
+	for i := 0; i+d.frameSize <= len(samples); i += d.frameSize {
+		if ev := d.process(samples[i : i+d.frameSize]); ev != nil {
+			events = append(events, *ev)
+		}
+	}
+	return events
+}
+
+// onsets 返回每次语音起点所在的采样位置
+func onsets(d *energyDetector, samples []float64) []int {
+	var starts []int
+	for _, ev := range detect(d, samples) {
+		if ev.Type == ai.SpeechStart {
+			starts = append(starts, int(ev.Offset))
+		}
+	}
+	return starts
+}
+
+func newTestDetector() *energyDetector {
+	return newEnergyDetector(testRate, defaultSensitivity, defaultHangover, defaultMinSpeech)
+}
+
+func TestEnergyDetector_Silence(t *testing.T) {
+	if got := onsets(newTestDetector(), silence(seconds(3))); len(got) != 0 {
+		t.Errorf("silence triggered at %v", got)
+	}
+}
+
+func TestEnergyDetector_Noise(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := concat(silence(seconds(1)), whiteNoise(rng, seconds(4), -30))
+
+	if got := onsets(newTestDetector(), samples); len(got) != 0 {
+		t.Errorf("white noise triggered at %v", got)
+	}
+}
+
+func TestEnergyDetector_ToneBursts(t *testing.T) {
+	// 短于最短语音时长的提示音不触发
+	var short []float64
+	for i := 0; i < 10; i++ {
+		short = concat(short, tone(seconds(0.06), 440, -20), silence(seconds(0.3)))
+	}
+	if got := onsets(newTestDetector(), short); len(got) != 0 {
+		t.Errorf("short bursts triggered at %v", got)
+	}
+
+	// 间隔短于 hangover 的长音算作同一段
+	var close []float64
+	for i := 0; i < 4; i++ {
+		close = concat(close, tone(seconds(0.4), 220, -20), silence(seconds(0.1)))
+	}
+	if got := onsets(newTestDetector(), close); len(got) != 1 {
+		t.Errorf("bursts within hangover gave %d onsets, want 1", len(got))
+	}
+
+	// 间隔足够长时每段各触发一次
+	var apart []float64
+	for i := 0; i < 3; i++ {
+		apart = concat(apart, tone(seconds(0.4), 220, -20), silence(seconds(0.8)))
+	}
+	if got := onsets(newTestDetector(), apart); len(got) != 3 {
+		t.Errorf("separated bursts gave %d onsets, want 3", len(got))
+	}
+}
+
+func TestEnergyDetector_SpeechInNoise(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	noise := whiteNoise(rng, seconds(5), -40)
+	speech := concat(silence(seconds(2)), speechLike(rng, seconds(2), -20), silence(seconds(1)))
+	samples := mix(noise, speech)
+
+	events := detect(newTestDetector(), samples)
+	if len(events) != 2 {
+		t.Fatalf("got events %+v, want one speech segment", events)
+	}
+	if on := events[0].Offset; on < int64(seconds(2)) || on > int64(seconds(2.4)) {
+		t.Errorf("onset at %.2fs, want shortly after 2s", float64(on)/testRate)
+	}
+	if off := events[1].Offset; off < int64(seconds(3.7)) || off > int64(seconds(4.1)) {
+		t.Errorf("offset at %.2fs, want about 4s", float64(off)/testRate)
+	}
+}
+
+// TestEnergyDetector_Fixture 在 WAV 样本上叠加噪声，检查每段的起止位置
+func TestEnergyDetector_Fixture(t *testing.T) {
+	pcm := loadFixture(t, "speech_16k.wav")
+	speech := make([]float64, len(pcm)/2)
+	for i := range speech {
+		speech[i] = float64(int16(binary.LittleEndian.Uint16(pcm[i*2:]))) / 32768
+	}
+	rng := rand.New(rand.NewSource(6))
+	samples := mix(speech, whiteNoise(rng, len(speech), -45))
+
+	// 两段语音之间的 100ms 提示音短于最短语音时长，不应成段
+	want := [][2]float64{{1, 2.2}, {3.9, 4.8}}
+	events := detect(newTestDetector(), samples)
+	if len(events) != 2*len(want) {
+		t.Fatalf("got %d events %+v, want %d segments", len(events), events, len(want))
+	}
+	const tolerance = 0.1
+	for i, seg := range want {
+		on, off := float64(events[2*i].Offset)/testRate, float64(events[2*i+1].Offset)/testRate
+		if math.Abs(on-seg[0]) > tolerance || math.Abs(off-seg[1]) > tolerance {
+			t.Errorf("segment %d at %.2f-%.2fs, want %.2f-%.2fs", i, on, off, seg[0], seg[1])
+		}
+	}
+}
+
+func TestEnergyDetector_Sensitivity(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	noise := whiteNoise(rng, seconds(4), -45)
+	quiet := concat(silence(seconds(2)), speechLike(rng, seconds(1.5), -38), silence(seconds(0.5)))
+	samples := mix(noise, quiet)
+
+	low := newEnergyDetector(testRate, 0, defaultHangover, defaultMinSpeech)
+	if got := onsets(low, samples); len(got) != 0 {
+		t.Errorf("low sensitivity triggered on quiet speech at %v", got)
+	}
+	high := newEnergyDetector(testRate, 1, defaultHangover, defaultMinSpeech)
+	if got := onsets(high, samples); len(got) != 1 {
+		t.Errorf("high sensitivity gave %d onsets, want 1", len(got))
+	}
+}
+
+func TestEnergyDetector_Events(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	samples := concat(silence(seconds(1)), speechLike(rng, seconds(1.5), -20), silence(seconds(1)))
+
+	events := detect(newTestDetector(), samples)
+	if len(events) != 2 || events[0].Type != ai.SpeechStart || events[1].Type != ai.SpeechEnd {
+		t.Fatalf("got events %+v, want SpeechStart then SpeechEnd", events)
+	}
+	start, end := events[0], events[1]
+
+	// 起点在语音开始附近，判定发生在最短语音时长之后
+	if start.Offset < int64(seconds(1)) || start.Offset > int64(seconds(1.1)) {
+		t.Errorf("start offset %.3fs, want about 1s", float64(start.Offset)/testRate)
+	}
+	if start.Delay() < defaultMinSpeech-energyFrameTime {
+		t.Errorf("start decided after %v, want at least the minimum speech duration", start.Delay())
+	}
+	// 终点在语音结束附近，判定发生在 hangover 之后
+	if end.Offset < int64(seconds(2.3)) || end.Offset > int64(seconds(2.55)) {
+		t.Errorf("end offset %.3fs, want about 2.5s", float64(end.Offset)/testRate)
+	}
+	if end.Delay() < defaultHangover-energyFrameTime {
+		t.Errorf("end decided after %v, want at least the hangover", end.Delay())
+	}
+	if start.Probability < 0.5 || end.Probability > start.Probability {
+		t.Errorf("probabilities start %.2f end %.2f", start.Probability, end.Probability)
+	}
+	if start.SampleRate != testRate {
+		t.Errorf("sample rate %d, want %d", start.SampleRate, testRate)
+	}
+}
+
+func TestEnergy_Start(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	samples := concat(silence(seconds(1)), speechLike(rng, seconds(1), -20), silence(seconds(1)))
+
+	var pcm bytes.Buffer
+	for _, x := range samples {
+		binary.Write(&pcm, binary.LittleEndian, int16(x*32767))
+	}
+
+	v := NewEnergy(&pcm, testRate)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var types []ai.VADEventType
+	var last time.Time
+	for ev := range v.Start(ctx) {
+		types = append(types, ev.Type)
+		if ev.Time.Before(last) {
+			t.Error("event times are not monotonic")
+		}
+		last = ev.Time
+	}
+	if len(types) != 2 || types[0] != ai.SpeechStart || types[1] != ai.SpeechEnd {
+		t.Errorf("got events %v, want [SpeechStart SpeechEnd]", types)
+	}
+	if err := v.Stop(); err != nil {
+		t.Errorf("Stop failed: %v", err)
+	}
+}
+
+// pacedReader 按实时速度返回静音，并记录同时读取的最大数量
+type pacedReader struct {
+	readers atomic.Int32
+	maxSeen atomic.Int32
+}
+
+func (r *pacedReader) Read(p []byte) (int, error) {
+	n := r.readers.Add(1)
+	defer r.readers.Add(-1)
+	for m := r.maxSeen.Load(); n > m && !r.maxSeen.CompareAndSwap(m, n); m = r.maxSeen.Load() {
+	}
+	time.Sleep(energyFrameTime)
+	clear(p)
+	return len(p), nil
+}
+
+func TestEnergy_Restart(t *testing.T) {
+	src := &pacedReader{}
+	v := NewEnergy(src, testRate)
+
+	first := v.Start(context.Background())
+	time.Sleep(50 * time.Millisecond)
+	// Stop 可重复调用
+	v.Stop()
+	v.Stop()
+	for range first {
+	}
+
+	// 重新启动后继续读取同一音源，旧的监听不再读取
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	second := v.Start(ctx)
+	third := v.Start(ctx)
+	for range second {
+	}
+	for range third {
+	}
+	if n := src.maxSeen.Load(); n != 1 {
+		t.Errorf("%d monitors read the source at once", n)
+	}
+}
@@ -0,0 +1,90 @@
+package vad
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// frameEnergy returns the mean power of a frame in dBFS
+func frameEnergy(frame []float64) float64 {
+	var sum float64
+	for _, x := range frame {
+		sum += x * x
+	}
+	if sum == 0 {
+		return -120
+	}
+	return 10 * math.Log10(sum/float64(len(frame)))
+}
+
+// zeroCrossingRate returns the fraction of adjacent samples with a sign change
+func zeroCrossingRate(frame []float64) float64 {
+	if len(frame) < 2 {
+		return 0
+	}
+	crossings := 0
+	for i := 1; i < len(frame); i++ {
+		if (frame[i-1] >= 0) != (frame[i] >= 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) / float64(len(frame)-1)
+}
+
+// spectralFlatness returns the ratio of the geometric to the arithmetic
+// mean of the power spectrum: close to 1 for white noise, close to 0 for
+// tonal or harmonic sounds such as voiced speech
+func spectralFlatness(frame []float64) float64 {
+	n := 1
+	for n < len(frame) {
+		n <<= 1
+	}
+
+	// Hann window, zero padded to a power of two
+	buf := make([]complex128, n)
+	for i, x := range frame {
+		w := 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(len(frame)-1))
+		buf[i] = complex(x*w, 0)
+	}
+	fft(buf)
+
+	var logSum, sum float64
+	bins := n / 2
+	for k := 1; k <= bins; k++ {
+		p := real(buf[k])*real(buf[k]) + imag(buf[k])*imag(buf[k]) + 1e-12
+		logSum += math.Log(p)
+		sum += p
+	}
+	mean := sum / float64(bins)
+	return math.Exp(logSum/float64(bins)) / mean
+}
+
+// fft is an in-place iterative radix-2 FFT; len(a) must be a power of two
+func fft(a []complex128) {
+	n := len(a)
+
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u := a[start+k]
+				v := a[start+k+size/2] * w
+				a[start+k] = u + v
+				a[start+k+size/2] = u - v
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package vad
+
+import (
+	"context"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+)
+
+// eventBuffer absorbs short stalls of the consumer so that reading the
+// audio source keeps up with real time
+const eventBuffer = 16
+
+// sendEvent stamps the event and delivers it, giving up when ctx is done
+func sendEvent(ctx context.Context, ch chan<- ai.VADEvent, ev *ai.VADEvent) bool {
+	ev.Time = time.Now()
+	select {
+	case ch <- *ev:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -2,43 +2,251 @@ package vad
 
 import (
 	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
 )
 
-// Silero implements VADMonitor using Silero VAD model
+// Silero v5 operates on 512-sample windows at 16kHz, each preceded by the
+// last 64 samples of the previous window
+const (
+	SileroSampleRate  = 16000
+	sileroWindowSize  = 512
+	sileroContextSize = 64
+	sileroStateSize   = 2 * 1 * 128 // state tensor [2, 1, 128]
+
+	defaultSileroThreshold  = 0.5
+	sileroNegThresholdDelta = 0.15
+	defaultSileroMinSpeech  = 250 * time.Millisecond
+	defaultSileroMinSilence = 100 * time.Millisecond
+)
+
+// sileroModel runs the Silero network on one window
+type sileroModel interface {
+	// infer returns the speech probability of input (context followed by
+	// the window) and updates the recurrent state in place
+	infer(input, state []float32) (float32, error)
+	close() error
+}
+
+// Silero implements VADMonitor using Silero VAD model. It reads PCM16
+// little-endian mono audio at 16kHz from source. Inference runs on the CPU
+// through ONNX Runtime, which requires building with -tags onnxruntime.
 type Silero struct {
-	modelPath string
-	threshold float32
-	stopCh    chan struct{}
+	modelPath    string
+	source       io.Reader
+	threshold    float32
+	negThreshold float32
+	minSpeech    time.Duration
+	minSilence   time.Duration
+	loadModel    func(path string) (sileroModel, error)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the monitor has exited
 }
 
 // NewSilero creates a new Silero VAD monitor
-func NewSilero(modelPath string) ai.VADMonitor {
+func NewSilero(modelPath string, source io.Reader) *Silero {
 	return &Silero{
-		modelPath: modelPath,
-		threshold: 0.5, // default threshold
-		stopCh:    make(chan struct{}),
+		modelPath:    modelPath,
+		source:       source,
+		threshold:    defaultSileroThreshold,
+		negThreshold: defaultSileroThreshold - sileroNegThresholdDelta,
+		minSpeech:    defaultSileroMinSpeech,
+		minSilence:   defaultSileroMinSilence,
+		loadModel:    loadSileroModel,
 	}
 }
 
-// Start begins monitoring for voice activity
-func (s *Silero) Start(ctx context.Context) <-chan struct{} {
-	signalCh := make(chan struct{})
-	// TODO: Implement audio capture and Silero VAD inference
+// SetThreshold sets the speech probability threshold (0, 1). The negative
+// threshold, below which speech ends, follows 0.15 below it.
+func (s *Silero) SetThreshold(threshold float32) {
+	s.threshold = threshold
+	s.negThreshold = max(threshold-sileroNegThresholdDelta, 0.01)
+}
+
+// SetNegThreshold sets the probability below which speech ends
+func (s *Silero) SetNegThreshold(threshold float32) {
+	s.negThreshold = threshold
+}
+
+// SetMinSpeech sets how long speech must last before it triggers
+func (s *Silero) SetMinSpeech(d time.Duration) {
+	s.minSpeech = d
+}
+
+// SetMinSilence sets how long silence must last before speech ends
+func (s *Silero) SetMinSilence(d time.Duration) {
+	s.minSilence = d
+}
+
+// Start begins monitoring for voice activity. Events are sent when speech
+// starts and ends; the channel is closed when the source ends, inference
+// fails or monitoring stops. Starting again stops the previous monitor,
+// and the new one reads the source once the previous one has exited.
+func (s *Silero) Start(ctx context.Context) <-chan ai.VADEvent {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	s.mu.Lock()
+	prev := s.done
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.cancel, s.done = cancel, done
+	s.mu.Unlock()
+
+	events := make(chan ai.VADEvent, eventBuffer)
+
 	go func() {
-		defer close(signalCh)
-		// Implementation will go here
-		// - Capture audio from microphone
-		// - Run Silero model inference
-		// - Send signal to signalCh when voice is detected
+		defer close(done)
+		defer close(events)
+		defer cancel()
+
+		// Only one monitor reads the source at a time
+		if prev != nil {
+			select {
+			case <-prev:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		model, err := s.loadModel(s.modelPath)
+		if err != nil {
+			log.Printf("[VAD] Failed to load Silero model: %v", err)
+			return
+		}
+		defer model.close()
+
+		det := newSileroDetector(model, s.threshold, s.negThreshold, s.minSpeech, s.minSilence)
+		buf := make([]byte, sileroWindowSize*2)
+		window := make([]float32, sileroWindowSize)
+		for ctx.Err() == nil {
+			if _, err := io.ReadFull(s.source, buf); err != nil {
+				return
+			}
+			for i := range window {
+				window[i] = float32(int16(binary.LittleEndian.Uint16(buf[i*2:]))) / 32768
+			}
+
+			ev, err := det.process(window)
+			if err != nil {
+				log.Printf("[VAD] Silero inference failed: %v", err)
+				return
+			}
+			if ev != nil && !sendEvent(ctx, events, ev) {
+				return
+			}
+		}
 	}()
-	return signalCh
+
+	return events
 }
 
-// Stop stops the monitoring process
+// Stop stops the monitoring process. A read already blocked on the source
+// finishes before the monitor exits.
 func (s *Silero) Stop() error {
-	// TODO: Stop audio capture and cleanup resources
-	close(s.stopCh)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
 	return nil
 }
+
+// sileroDetector carries the model state across windows and turns speech
+// probabilities into onsets with hysteresis and minimum durations
+type sileroDetector struct {
+	model        sileroModel
+	input        []float32 // context followed by the current window
+	state        []float32
+	threshold    float32
+	negThreshold float32
+	minSpeech    int64 // samples
+	minSilence   int64 // samples
+
+	current     int64 // samples processed so far
+	triggered   bool
+	speechStart int64 // start of pending speech, -1 if none
+	silentSince int64 // start of silence during speech, -1 if none
+	prob        float32
+}
+
+func newSileroDetector(model sileroModel, threshold, negThreshold float32, minSpeech, minSilence time.Duration) *sileroDetector {
+	return &sileroDetector{
+		model:        model,
+		input:        make([]float32, sileroContextSize+sileroWindowSize),
+		state:        make([]float32, sileroStateSize),
+		threshold:    threshold,
+		negThreshold: negThreshold,
+		minSpeech:    int64(minSpeech.Seconds() * SileroSampleRate),
+		minSilence:   int64(minSilence.Seconds() * SileroSampleRate),
+		speechStart:  -1,
+		silentSince:  -1,
+	}
+}
+
+// process runs one window of samples in [-1, 1] and returns an event when
+// speech was confirmed or ended with it
+func (d *sileroDetector) process(window []float32) (*ai.VADEvent, error) {
+	copy(d.input[sileroContextSize:], window)
+	prob, err := d.model.infer(d.input, d.state)
+	if err != nil {
+		return nil, err
+	}
+	// The tail of this window is the context of the next one
+	copy(d.input, d.input[len(d.input)-sileroContextSize:])
+
+	start := d.current
+	d.current += int64(len(window))
+	d.prob = prob
+	event := func(typ ai.VADEventType, offset int64) *ai.VADEvent {
+		return &ai.VADEvent{
+			Type:        typ,
+			Offset:      offset,
+			Detected:    d.current,
+			SampleRate:  SileroSampleRate,
+			Probability: prob,
+		}
+	}
+
+	switch {
+	case prob >= d.threshold:
+		d.silentSince = -1
+		if d.speechStart < 0 {
+			d.speechStart = start
+		}
+	case prob < d.negThreshold:
+		if !d.triggered {
+			// Too short to count as speech
+			d.speechStart = -1
+			return nil, nil
+		}
+		if d.silentSince < 0 {
+			d.silentSince = start
+		}
+		if d.current-d.silentSince < d.minSilence {
+			return nil, nil
+		}
+		end := d.silentSince
+		d.triggered = false
+		d.speechStart = -1
+		d.silentSince = -1
+		return event(ai.SpeechEnd, end), nil
+	}
+
+	// Between the thresholds the current state holds
+	if d.triggered || d.speechStart < 0 {
+		return nil, nil
+	}
+	if d.current-d.speechStart < d.minSpeech {
+		return nil, nil
+	}
+	d.triggered = true
+	return event(ai.SpeechStart, d.speechStart), nil
+}
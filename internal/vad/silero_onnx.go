@@ -0,0 +1,121 @@
+//go:build onnxruntime
+
+package vad
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// The ONNX Runtime shared library is located through ONNXRUNTIME_LIB, or
+// found by the system loader as libonnxruntime.so
+var (
+	ortOnce sync.Once
+	ortErr  error
+)
+
+func initONNXRuntime() error {
+	ortOnce.Do(func() {
+		lib := os.Getenv("ONNXRUNTIME_LIB")
+		if lib == "" {
+			lib = "libonnxruntime.so"
+		}
+		ort.SetSharedLibraryPath(lib)
+		ortErr = ort.InitializeEnvironment()
+	})
+	return ortErr
+}
+
+// onnxSilero runs the Silero v5 model with ONNX Runtime on the CPU
+type onnxSilero struct {
+	session *ort.AdvancedSession
+	input   *ort.Tensor[float32]
+	state   *ort.Tensor[float32]
+	sr      *ort.Scalar[int64]
+	output  *ort.Tensor[float32]
+	stateN  *ort.Tensor[float32]
+}
+
+// loadSileroModel loads a Silero v5 ONNX model
+func loadSileroModel(path string) (sileroModel, error) {
+	if err := initONNXRuntime(); err != nil {
+		return nil, fmt.Errorf("failed to initialize ONNX Runtime: %w", err)
+	}
+
+	m := &onnxSilero{}
+	var err error
+	if m.input, err = ort.NewEmptyTensor[float32](ort.NewShape(1, sileroContextSize+sileroWindowSize)); err != nil {
+		return nil, err
+	}
+	if m.state, err = ort.NewEmptyTensor[float32](ort.NewShape(2, 1, 128)); err != nil {
+		m.close()
+		return nil, err
+	}
+	if m.sr, err = ort.NewScalar(int64(SileroSampleRate)); err != nil {
+		m.close()
+		return nil, err
+	}
+	if m.output, err = ort.NewEmptyTensor[float32](ort.NewShape(1, 1)); err != nil {
+		m.close()
+		return nil, err
+	}
+	if m.stateN, err = ort.NewEmptyTensor[float32](ort.NewShape(2, 1, 128)); err != nil {
+		m.close()
+		return nil, err
+	}
+
+	// A single window is tiny, extra threads only add overhead
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+	defer options.Destroy()
+	options.SetIntraOpNumThreads(1)
+	options.SetInterOpNumThreads(1)
+
+	m.session, err = ort.NewAdvancedSession(path,
+		[]string{"input", "state", "sr"}, []string{"output", "stateN"},
+		[]ort.Value{m.input, m.state, m.sr}, []ort.Value{m.output, m.stateN},
+		options)
+	if err != nil {
+		m.close()
+		return nil, fmt.Errorf("failed to load Silero model %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func (m *onnxSilero) infer(input, state []float32) (float32, error) {
+	copy(m.input.GetData(), input)
+	copy(m.state.GetData(), state)
+	if err := m.session.Run(); err != nil {
+		return 0, err
+	}
+	copy(state, m.stateN.GetData())
+	return m.output.GetData()[0], nil
+}
+
+func (m *onnxSilero) close() error {
+	if m.session != nil {
+		m.session.Destroy()
+	}
+	if m.input != nil {
+		m.input.Destroy()
+	}
+	if m.state != nil {
+		m.state.Destroy()
+	}
+	if m.sr != nil {
+		m.sr.Destroy()
+	}
+	if m.output != nil {
+		m.output.Destroy()
+	}
+	if m.stateN != nil {
+		m.stateN.Destroy()
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+//go:build onnxruntime
+
+package vad
+
+import (
+	"os"
+	"testing"
+)
+
+// 需要真实模型：SILERO_MODEL=/path/to/silero_vad.onnx go test -tags onnxruntime
+func TestSileroONNX_Fixture(t *testing.T) {
+	path := os.Getenv("SILERO_MODEL")
+	if path == "" {
+		t.Skip("SILERO_MODEL not set")
+	}
+
+	model, err := loadSileroModel(path)
+	if err != nil {
+		t.Fatalf("failed to load model: %v", err)
+	}
+	defer model.close()
+
+	// 同一输入两次推理结果一致
+	run := func() []float32 {
+		det := newTestSileroDetector(model)
+		var probs []float32
+		for _, w := range windows(loadFixture(t, "speech_16k.wav")) {
+			if _, err := det.process(w); err != nil {
+				t.Fatal(err)
+			}
+			probs = append(probs, det.prob)
+		}
+		return probs
+	}
+	first, second := run(), run()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("window %d: %v != %v, inference is not deterministic", i, first[i], second[i])
+		}
+		if first[i] < 0 || first[i] > 1 {
+			t.Fatalf("window %d: probability %v out of range", i, first[i])
+		}
+	}
+}
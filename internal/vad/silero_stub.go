@@ -0,0 +1,10 @@
+//go:build !onnxruntime
+
+package vad
+
+import "errors"
+
+// loadSileroModel is unavailable without ONNX Runtime
+func loadSileroModel(path string) (sileroModel, error) {
+	return nil, errors.New("silero: built without ONNX Runtime, rebuild with -tags onnxruntime")
+}
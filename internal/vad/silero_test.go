@@ -0,0 +1,225 @@
+package vad
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/ai"
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+// fakeSilero 是确定性的替身模型：概率由窗口能量经递归状态平滑得到，
+// 并记录每次调用的输入以检查上下文拼接
+type fakeSilero struct {
+	inputs [][]float32
+	probs  []float32 // 非空时按顺序返回固定概率
+	closed bool
+}
+
+func (m *fakeSilero) infer(input, state []float32) (float32, error) {
+	m.inputs = append(m.inputs, append([]float32(nil), input...))
+	if len(m.probs) > 0 {
+		p := m.probs[0]
+		m.probs = m.probs[1:]
+		return p, nil
+	}
+
+	var sum float64
+	for _, x := range input[sileroContextSize:] {
+		sum += float64(x) * float64(x)
+	}
+	db := 10 * math.Log10(sum/sileroWindowSize+1e-12)
+	score := float32(math.Max(0, math.Min(1, (db+45)/20)))
+
+	state[0] = 0.5*state[0] + 0.5*score
+	return state[0], nil
+}
+
+func (m *fakeSilero) close() error {
+	m.closed = true
+	return nil
+}
+
+func loadFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	f, err := wav.Decode(data)
+	if err != nil {
+		t.Fatalf("failed to decode fixture: %v", err)
+	}
+	if f.Format != wav.PCM16(SileroSampleRate, 1) {
+		t.Fatalf("fixture format %s, want 16kHz mono PCM16", f.Format)
+	}
+	return f.Data
+}
+
+func windows(pcm []byte) [][]float32 {
+	var out [][]float32
+	for i := 0; i+sileroWindowSize*2 <= len(pcm); i += sileroWindowSize * 2 {
+		w := make([]float32, sileroWindowSize)
+		for j := range w {
+			w[j] = float32(int16(binary.LittleEndian.Uint16(pcm[i+j*2:]))) / 32768
+		}
+		out = append(out, w)
+	}
+	return out
+}
+
+func newTestSileroDetector(m sileroModel) *sileroDetector {
+	return newSileroDetector(m, defaultSileroThreshold, defaultSileroThreshold-sileroNegThresholdDelta,
+		defaultSileroMinSpeech, defaultSileroMinSilence)
+}
+
+func TestSileroDetector_Fixture(t *testing.T) {
+	pcm := loadFixture(t, "speech_16k.wav")
+	det := newTestSileroDetector(&fakeSilero{})
+
+	var events []ai.VADEvent
+	for _, w := range windows(pcm) {
+		ev, err := det.process(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ev != nil {
+			events = append(events, *ev)
+		}
+	}
+
+	// 两段语音各触发一次，中间 100ms 的提示音短于最短语音时长
+	if len(events) != 4 {
+		t.Fatalf("got %d events %+v, want 4", len(events), events)
+	}
+	seconds := func(samples int64) float64 {
+		return float64(samples) / SileroSampleRate
+	}
+	want := []struct {
+		typ      ai.VADEventType
+		from, to float64
+	}{
+		{ai.SpeechStart, 1.0, 1.1},
+		{ai.SpeechEnd, 2.1, 2.3},
+		{ai.SpeechStart, 3.9, 4.0},
+		{ai.SpeechEnd, 4.7, 4.9},
+	}
+	for i, w := range want {
+		ev := events[i]
+		if ev.Type != w.typ || seconds(ev.Offset) < w.from || seconds(ev.Offset) > w.to {
+			t.Errorf("event %d: %s at %.3fs, want %s in [%.2f, %.2f]", i, ev.Type, seconds(ev.Offset), w.typ, w.from, w.to)
+		}
+	}
+	if d := events[0].Delay(); d < defaultSileroMinSpeech {
+		t.Errorf("onset decided after %v, want at least %v", d, defaultSileroMinSpeech)
+	}
+}
+
+func TestSileroDetector_Context(t *testing.T) {
+	model := &fakeSilero{}
+	det := newTestSileroDetector(model)
+
+	w1 := make([]float32, sileroWindowSize)
+	w2 := make([]float32, sileroWindowSize)
+	for i := range w1 {
+		w1[i] = float32(i) / sileroWindowSize
+		w2[i] = -w1[i]
+	}
+	det.process(w1)
+	det.process(w2)
+
+	first, second := model.inputs[0], model.inputs[1]
+	if len(second) != sileroContextSize+sileroWindowSize {
+		t.Fatalf("input length %d, want %d", len(second), sileroContextSize+sileroWindowSize)
+	}
+	for i := 0; i < sileroContextSize; i++ {
+		if first[i] != 0 {
+			t.Fatalf("first context not zero at %d", i)
+		}
+		if second[i] != w1[sileroWindowSize-sileroContextSize+i] {
+			t.Fatalf("context sample %d = %v, want tail of previous window", i, second[i])
+		}
+	}
+	if det.state[0] == 0 {
+		t.Error("recurrent state was not carried over")
+	}
+}
+
+func TestSileroDetector_Hysteresis(t *testing.T) {
+	// 每个窗口 32ms：最短语音 250ms 需要 8 个窗口，最短静音 100ms 需要 4 个窗口
+	probs := []float32{
+		0.9, 0.9, 0.9, 0.9, 0.6, 0.4, 0.4, 0.9, // 8 个窗口未低于负阈值，第 8 个触发
+		0.2, 0.2, 0.4, 0.2, // 介于两阈值之间不打断静音计时，第 4 个结束语音
+		0.9, 0.9, 0.9, 0.2, // 负阈值以下重置未确认的语音
+		0.9, 0.9, 0.9, 0.9, 0.9, 0.9, 0.9, 0.9, // 再次触发
+	}
+	want := map[int]ai.VADEventType{7: ai.SpeechStart, 11: ai.SpeechEnd, 23: ai.SpeechStart}
+
+	det := newTestSileroDetector(&fakeSilero{probs: append([]float32(nil), probs...)})
+	w := make([]float32, sileroWindowSize)
+	for i := range probs {
+		ev, err := det.process(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ, ok := want[i]
+		if (ev != nil) != ok || ok && ev.Type != typ {
+			t.Errorf("window %d (p=%.1f): event %+v, want %v", i, probs[i], ev, want[i])
+		}
+	}
+	// 语音从第 0 个窗口开始，在第 8 个窗口处结束
+	det = newTestSileroDetector(&fakeSilero{probs: probs[:12]})
+	var events []*ai.VADEvent
+	for range 12 {
+		if ev, _ := det.process(w); ev != nil {
+			events = append(events, ev)
+		}
+	}
+	if events[0].Offset != 0 || events[1].Offset != 8*sileroWindowSize {
+		t.Errorf("offsets %d, %d, want 0, %d", events[0].Offset, events[1].Offset, 8*sileroWindowSize)
+	}
+}
+
+func TestSilero_Start(t *testing.T) {
+	model := &fakeSilero{}
+	s := NewSilero("model.onnx", bytes.NewReader(loadFixture(t, "speech_16k.wav")))
+	s.loadModel = func(path string) (sileroModel, error) {
+		return model, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var types []ai.VADEventType
+	for ev := range s.Start(ctx) {
+		types = append(types, ev.Type)
+	}
+	if len(types) != 4 {
+		t.Errorf("got events %v, want 4", types)
+	}
+	if !model.closed {
+		t.Error("model was not closed")
+	}
+}
+
+func TestSilero_LoadError(t *testing.T) {
+	s := NewSilero("missing.onnx", bytes.NewReader(nil))
+	s.loadModel = func(path string) (sileroModel, error) {
+		return nil, errors.New("no model")
+	}
+
+	select {
+	case _, ok := <-s.Start(context.Background()):
+		if ok {
+			t.Error("unexpected signal")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after load error")
+	}
+}
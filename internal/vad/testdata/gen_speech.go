@@ -0,0 +1,160 @@
+//go:build ignore
+
+// gen_speech writes speech_16k.wav, the synthetic fixture of the VAD tests.
+// Run it from this directory:
+//
+//	go run gen_speech.go
+//
+// The output is deterministic, so regenerating leaves the file unchanged.
+package main
+
+import (
+	"encoding/binary"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+
+	"github.com/Lin-Jiong-HDU/hold-my-audio/internal/wav"
+)
+
+const (
+	sampleRate = 16000
+	duration   = 5.25 // seconds
+
+	noiseDB  = -55.0 // room noise under the whole file
+	speechDB = -20.0 // RMS of each utterance
+	beepDB   = -20.0 // RMS of the prompt tone
+)
+
+// utterances are the speech segments in seconds; words are 0.2s apart
+var utterances = [][2]float64{{1.0, 2.2}, {3.9, 4.8}}
+
+// beep is a 1kHz prompt tone between the utterances, shorter than the
+// minimum speech duration of both detectors
+var beep = [2]float64{2.8, 2.9}
+
+func main() {
+	rng := rand.New(rand.NewSource(1))
+	out := make([]float64, int(duration*sampleRate))
+
+	noise := level(noiseDB)
+	for i := range out {
+		out[i] = rng.NormFloat64() * noise
+	}
+	for _, u := range utterances {
+		add(out, u[0], utterance(rng, samples(u[1]-u[0])))
+	}
+	add(out, beep[0], tone(samples(beep[1]-beep[0]), 1000))
+
+	pcm := make([]byte, 2*len(out))
+	for i, x := range out {
+		v := math.Round(math.Max(-1, math.Min(1, x)) * 32767)
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(int16(v)))
+	}
+	data, err := wav.Encode(wav.PCM16(sampleRate, 1), pcm)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("speech_16k.wav", data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// utterance is a source-filter imitation of voiced speech: a glottal pulse
+// train with a gliding pitch through two formant resonators, shaped into
+// 0.2s words whose last quarter is a short unvoiced release
+func utterance(rng *rand.Rand, n int) []float64 {
+	out := make([]float64, n)
+	formants := [][2]float64{{700, 1200}, {400, 2000}, {550, 1700}, {300, 2300}, {650, 1000}}
+	word := samples(0.2)
+
+	var f1, f2 resonator
+	phase := 0.0
+	for i := range out {
+		w := i / word
+		if i%word == 0 {
+			f := formants[w%len(formants)]
+			f1, f2 = newResonator(f[0]), newResonator(f[1])
+		}
+
+		pos := float64(i%word) / float64(word)
+		var x float64
+		if pos < 0.75 {
+			f0 := 130 + 25*math.Sin(2*math.Pi*float64(i)/float64(samples(0.9)))
+			phase += f0 / sampleRate
+			if phase >= 1 {
+				phase--
+				x = 1
+			}
+		} else {
+			x = rng.NormFloat64() * 0.05
+		}
+
+		// Words fade to a third of their peak rather than to silence, as
+		// in connected speech
+		env := 1 - 0.65*math.Pow(math.Cos(math.Pi*pos), 2)
+		out[i] = (f1.next(x) + 0.5*f2.next(x)) * env
+	}
+	ramp(out, samples(0.02))
+	return normalize(out, speechDB)
+}
+
+func tone(n int, freq float64) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.Sin(2 * math.Pi * freq * float64(i) / sampleRate)
+	}
+	ramp(out, samples(0.005))
+	return normalize(out, beepDB)
+}
+
+// resonator is a two-pole filter with a 100Hz bandwidth
+type resonator struct{ a1, a2, y1, y2 float64 }
+
+func newResonator(freq float64) resonator {
+	r := math.Exp(-math.Pi * 100 / sampleRate)
+	return resonator{a1: 2 * r * math.Cos(2*math.Pi*freq/sampleRate), a2: -r * r}
+}
+
+func (r *resonator) next(x float64) float64 {
+	y := x + r.a1*r.y1 + r.a2*r.y2
+	r.y2, r.y1 = r.y1, y
+	return y
+}
+
+// ramp fades the first and last n samples in and out
+func ramp(s []float64, n int) {
+	for i := 0; i < n && i < len(s); i++ {
+		g := 0.5 - 0.5*math.Cos(math.Pi*float64(i)/float64(n))
+		s[i] *= g
+		s[len(s)-1-i] *= g
+	}
+}
+
+func normalize(s []float64, db float64) []float64 {
+	var sum float64
+	for _, x := range s {
+		sum += x * x
+	}
+	gain := level(db) / math.Sqrt(sum/float64(len(s)))
+	for i := range s {
+		s[i] *= gain
+	}
+	return s
+}
+
+func add(dst []float64, at float64, src []float64) {
+	start := samples(at)
+	for i, x := range src {
+		dst[start+i] += x
+	}
+}
+
+func level(db float64) float64 {
+	return math.Pow(10, db/20)
+}
+
+func samples(seconds float64) int {
+	return int(math.Round(seconds * sampleRate))
+}
@@ -0,0 +1,218 @@
+// Package wake matches wake phrases at the start of transcribed speech,
+// tolerating recognition errors in Chinese and English.
+package wake
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Matching defaults
+const (
+	defaultTolerance = 0.25 // edit operations allowed per phrase character
+	maxFillers       = 2    // hesitation words skipped before the phrase
+)
+
+// fillers are hesitations often transcribed before the wake phrase
+var fillers = []string{"嗯", "啊", "呃", "哎", "喂", "um", "uh", "oh", "ok", "okay", "so", "hey"}
+
+// Match is a wake phrase found at the start of a transcript
+type Match struct {
+	Phrase    string // the configured phrase that matched
+	Distance  int    // edit distance between the phrase and the heard text
+	Remainder string // what was said after the phrase, trimmed
+}
+
+// Matcher finds configured wake phrases at the start of a transcript
+type Matcher struct {
+	phrases   []string
+	tolerance float64
+}
+
+// NewMatcher creates a matcher for the given wake phrases
+func NewMatcher(phrases ...string) *Matcher {
+	m := &Matcher{tolerance: defaultTolerance}
+	for _, p := range phrases {
+		if len(normalize(p).runes) > 0 {
+			m.phrases = append(m.phrases, p)
+		}
+	}
+	return m
+}
+
+// SetTolerance sets the edit operations allowed per phrase character
+// [0, 0.5]. At least one error is always tolerated for phrases of three
+// or more characters.
+func (m *Matcher) SetTolerance(tolerance float64) {
+	m.tolerance = max(0, min(0.5, tolerance))
+}
+
+// Phrases returns the configured wake phrases
+func (m *Matcher) Phrases() []string {
+	return m.phrases
+}
+
+// Match reports whether transcript begins with one of the wake phrases.
+// When several match, the closest one wins.
+func (m *Matcher) Match(transcript string) (Match, bool) {
+	text := normalize(transcript)
+
+	var best Match
+	bestGap := 0
+	found := false
+	for _, phrase := range m.phrases {
+		p := normalize(phrase)
+		allowed := m.allowed(len(p.runes))
+
+		// Skip leading hesitations, trying each possible start
+		for _, start := range fillerStarts(text) {
+			dists := prefixDistances(p.runes, text.runes[start:])
+			for n, dist := range dists {
+				end := start + n
+				if dist > allowed || !text.boundary(end) {
+					continue
+				}
+				// Prefer fewer errors, then a length closer to the phrase
+				if found && (dist > best.Distance || dist == best.Distance && abs(n-len(p.runes)) >= bestGap) {
+					continue
+				}
+				best = Match{
+					Phrase:    phrase,
+					Distance:  dist,
+					Remainder: strings.TrimLeftFunc(transcript[text.offsets[end]:], isSeparator),
+				}
+				bestGap = abs(n - len(p.runes))
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+func (m *Matcher) allowed(length int) int {
+	n := int(float64(length) * m.tolerance)
+	if n == 0 && length >= 3 && m.tolerance > 0 {
+		n = 1
+	}
+	return n
+}
+
+// normalized is a transcript reduced to lowercase letters, digits and Han
+// characters, with the byte offset of each rune in the original text
+type normalized struct {
+	runes   []rune
+	offsets []int  // byte offset of each rune, plus the end of the text
+	sep     []bool // whether a separator preceded each rune
+}
+
+// boundary reports whether a phrase may end before rune i: never inside a
+// Latin word
+func (n normalized) boundary(i int) bool {
+	if i == 0 || i >= len(n.runes) {
+		return true
+	}
+	return n.sep[i] || !isLatin(n.runes[i-1]) || !isLatin(n.runes[i])
+}
+
+func normalize(s string) normalized {
+	var n normalized
+	sep := false
+	for i, r := range s {
+		r = unicode.ToLower(foldWidth(r))
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			sep = true
+			continue
+		}
+		n.runes = append(n.runes, r)
+		n.offsets = append(n.offsets, i)
+		n.sep = append(n.sep, sep)
+		sep = false
+	}
+	n.offsets = append(n.offsets, len(s))
+	return n
+}
+
+// foldWidth maps fullwidth ASCII variants to ASCII
+func foldWidth(r rune) rune {
+	if r >= 0xFF01 && r <= 0xFF5E {
+		return r - 0xFEE0
+	}
+	return r
+}
+
+func isLatin(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// fillerStarts returns the rune positions where the phrase may begin:
+// the start, and after up to maxFillers leading hesitation words
+func fillerStarts(text normalized) []int {
+	starts := []int{0}
+	pos := 0
+	for range maxFillers {
+		next := -1
+		for _, f := range fillers {
+			fr := []rune(f)
+			if !hasPrefix(text.runes[pos:], fr) {
+				continue
+			}
+			end := pos + len(fr)
+			if !text.boundary(end) {
+				continue
+			}
+			next = end
+			break
+		}
+		if next < 0 {
+			break
+		}
+		pos = next
+		starts = append(starts, pos)
+	}
+	return starts
+}
+
+func hasPrefix(s, prefix []rune) bool {
+	if len(prefix) > len(s) {
+		return false
+	}
+	for i, r := range prefix {
+		if s[i] != r {
+			return false
+		}
+	}
+	return true
+}
+
+// prefixDistances returns the edit distance between phrase and each
+// prefix of text, indexed by prefix length
+func prefixDistances(phrase, text []rune) []int {
+	prev := make([]int, len(text)+1)
+	cur := make([]int, len(text)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(phrase); i++ {
+		cur[0] = i
+		for j := 1; j <= len(text); j++ {
+			cost := 1
+			if phrase[i-1] == text[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j-1]+cost, prev[j]+1, cur[j-1]+1)
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package wake
+
+import "testing"
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher("hey host", "主持人")
+
+	tests := []struct {
+		name      string
+		text      string
+		match     bool
+		phrase    string
+		remainder string
+	}{
+		{"english exact", "Hey host, what is a transformer?", true, "hey host", "what is a transformer?"},
+		{"english misheard", "Hey ghost. Can you repeat that?", true, "hey host", "Can you repeat that?"},
+		{"english joined", "heyhost stop", true, "hey host", "stop"},
+		{"english filler", "Um, hey host what", true, "hey host", "what"},
+		{"chinese exact", "主持人，这个词是什么意思？", true, "主持人", "这个词是什么意思？"},
+		{"chinese homophone", "主持任你好", true, "主持人", "你好"},
+		{"chinese filler", "嗯，主持人，等一下", true, "主持人", "等一下"},
+		{"fullwidth", "ＨＥＹ　ＨＯＳＴ！ok", true, "hey host", "ok"},
+		{"phrase only", "主持人。", true, "主持人", ""},
+		{"not at start", "我觉得主持人说得对", false, "", ""},
+		{"other speech", "Hey how's it going", false, "", ""},
+		{"chinese chat", "主要是今天太忙了", false, "", ""},
+		{"inside a word", "Hey hostile crowd", false, "", ""},
+		{"empty", "", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.Match(tt.text)
+			if ok != tt.match {
+				t.Fatalf("Match(%q) = %+v, %v; want match %v", tt.text, got, ok, tt.match)
+			}
+			if !ok {
+				return
+			}
+			if got.Phrase != tt.phrase || got.Remainder != tt.remainder {
+				t.Errorf("Match(%q) = %+v, want phrase %q remainder %q", tt.text, got, tt.phrase, tt.remainder)
+			}
+		})
+	}
+}
+
+func TestMatcher_Tolerance(t *testing.T) {
+	m := NewMatcher("hey host")
+	m.SetTolerance(0)
+	if _, ok := m.Match("hey ghost"); ok {
+		t.Error("zero tolerance accepted a misheard phrase")
+	}
+	if _, ok := m.Match("Hey host!"); !ok {
+		t.Error("zero tolerance rejected the exact phrase")
+	}
+}
+
+func TestNewMatcher_SkipsEmpty(t *testing.T) {
+	m := NewMatcher("", "，", "主持人")
+	if len(m.Phrases()) != 1 {
+		t.Errorf("Phrases() = %q, want only 主持人", m.Phrases())
+	}
+	if _, ok := m.Match("随便说点什么"); ok {
+		t.Error("empty phrase matched")
+	}
+}
@@ -0,0 +1,279 @@
+// Package wav reads and writes RIFF/WAVE files holding PCM audio
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Audio format codes used in the fmt chunk
+const (
+	FormatPCM        uint16 = 1
+	FormatIEEEFloat  uint16 = 3
+	formatExtensible uint16 = 0xFFFE
+)
+
+// HeaderSize is the size of the canonical header written by Encode and
+// Write; the sample data follows it
+const HeaderSize = 44
+
+var (
+	// ErrInvalid is returned for data that is not a RIFF/WAVE file
+	ErrInvalid = errors.New("wav: invalid file")
+
+	// ErrFormatMismatch is returned when merging files with different formats
+	ErrFormatMismatch = errors.New("wav: format mismatch")
+)
+
+// Format describes the sample layout of the audio data
+type Format struct {
+	AudioFormat   uint16 // FormatPCM or FormatIEEEFloat
+	Channels      uint16
+	SampleRate    uint32
+	BitsPerSample uint16
+}
+
+// PCM16 returns the format of signed 16-bit little-endian PCM
+func PCM16(sampleRate, channels int) Format {
+	return Format{
+		AudioFormat:   FormatPCM,
+		Channels:      uint16(channels),
+		SampleRate:    uint32(sampleRate),
+		BitsPerSample: 16,
+	}
+}
+
+// BlockAlign returns the size of one frame (one sample for every channel)
+func (f Format) BlockAlign() int {
+	return int(f.Channels) * int(f.BitsPerSample) / 8
+}
+
+// ByteRate returns the number of bytes per second of audio
+func (f Format) ByteRate() int {
+	return int(f.SampleRate) * f.BlockAlign()
+}
+
+// Validate checks that the format describes playable audio
+func (f Format) Validate() error {
+	switch {
+	case f.AudioFormat != FormatPCM && f.AudioFormat != FormatIEEEFloat:
+		return fmt.Errorf("wav: unsupported audio format %d", f.AudioFormat)
+	case f.Channels == 0:
+		return fmt.Errorf("wav: zero channels")
+	case f.SampleRate == 0:
+		return fmt.Errorf("wav: zero sample rate")
+	case f.BitsPerSample == 0 || f.BitsPerSample%8 != 0:
+		return fmt.Errorf("wav: unsupported bits per sample %d", f.BitsPerSample)
+	}
+	return nil
+}
+
+// String returns a short description such as "PCM 24000Hz 16bit mono"
+func (f Format) String() string {
+	kind := "PCM"
+	if f.AudioFormat == FormatIEEEFloat {
+		kind = "float"
+	}
+	channels := fmt.Sprintf("%dch", f.Channels)
+	switch f.Channels {
+	case 1:
+		channels = "mono"
+	case 2:
+		channels = "stereo"
+	}
+	return fmt.Sprintf("%s %dHz %dbit %s", kind, f.SampleRate, f.BitsPerSample, channels)
+}
+
+// File is a decoded WAV file
+type File struct {
+	Format Format
+	Data   []byte // raw sample data, a whole number of frames
+}
+
+// Frames returns the number of frames in the data
+func (f *File) Frames() int {
+	return len(f.Data) / f.Format.BlockAlign()
+}
+
+// Decode parses a RIFF/WAVE file. Chunks other than fmt and data are
+// skipped. A data chunk whose size is 0xFFFFFFFF, as written by streaming
+// encoders that do not know the length up front, extends to the end of
+// the file. So does one of size 0, unless well-formed chunks follow it.
+func Decode(data []byte) (*File, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, ErrInvalid
+	}
+
+	var (
+		file    File
+		haveFmt bool
+	)
+
+	pos := 12
+	for pos+8 <= len(data) {
+		id := string(data[pos : pos+4])
+		size := binary.LittleEndian.Uint32(data[pos+4 : pos+8])
+		body := pos + 8
+
+		switch id {
+		case "fmt ":
+			if size < 16 || body+int(size) > len(data) {
+				return nil, fmt.Errorf("%w: short fmt chunk", ErrInvalid)
+			}
+			file.Format = parseFormat(data[body : body+int(size)])
+			if err := file.Format.Validate(); err != nil {
+				return nil, err
+			}
+			haveFmt = true
+
+		case "data":
+			if !haveFmt {
+				return nil, fmt.Errorf("%w: data chunk before fmt chunk", ErrInvalid)
+			}
+			end := body + int(size)
+			if size == 0xFFFFFFFF || size == 0 && !chunksToEnd(data[body:]) {
+				end = len(data)
+			} else if end > len(data) {
+				return nil, fmt.Errorf("%w: data chunk truncated", ErrInvalid)
+			}
+			// 丢掉不完整的最后一帧
+			n := end - body
+			n -= n % file.Format.BlockAlign()
+			file.Data = data[body : body+n]
+			return &file, nil
+		}
+
+		// 奇数长度的 chunk 后面有一个填充字节
+		pos = body + int(size) + int(size&1)
+	}
+
+	if !haveFmt {
+		return nil, fmt.Errorf("%w: missing fmt chunk", ErrInvalid)
+	}
+	return nil, fmt.Errorf("%w: missing data chunk", ErrInvalid)
+}
+
+// chunksToEnd reports whether b is a sequence of chunks ending exactly
+// at its end, as opposed to sample data
+func chunksToEnd(b []byte) bool {
+	pos := 0
+	for pos+8 <= len(b) {
+		for _, c := range b[pos : pos+4] {
+			if c < ' ' || c > '~' {
+				return false
+			}
+		}
+		size := int(binary.LittleEndian.Uint32(b[pos+4 : pos+8]))
+		pos += 8 + size
+		// 最后一个 chunk 的填充字节可以省略
+		if size&1 == 1 && pos < len(b) {
+			pos++
+		}
+	}
+	return pos == len(b)
+}
+
+// parseFormat reads a fmt chunk body of at least 16 bytes
+func parseFormat(b []byte) Format {
+	f := Format{
+		AudioFormat:   binary.LittleEndian.Uint16(b[0:2]),
+		Channels:      binary.LittleEndian.Uint16(b[2:4]),
+		SampleRate:    binary.LittleEndian.Uint32(b[4:8]),
+		BitsPerSample: binary.LittleEndian.Uint16(b[14:16]),
+	}
+	// WAVE_FORMAT_EXTENSIBLE 的真实格式在子格式 GUID 的前两个字节
+	if f.AudioFormat == formatExtensible && len(b) >= 26 {
+		f.AudioFormat = binary.LittleEndian.Uint16(b[24:26])
+	}
+	return f
+}
+
+// Encode wraps raw sample data in a canonical 44-byte WAV header
+func Encode(format Format, pcm []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(HeaderSize + len(pcm))
+	if err := Write(&buf, format, pcm); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Write writes a WAV file with the given format and sample data to w
+func Write(w io.Writer, format Format, pcm []byte) error {
+	if err := format.Validate(); err != nil {
+		return err
+	}
+	if len(pcm)%format.BlockAlign() != 0 {
+		return fmt.Errorf("wav: data length %d is not a multiple of frame size %d", len(pcm), format.BlockAlign())
+	}
+
+	header := make([]byte, HeaderSize)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(HeaderSize-8+len(pcm)))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], format.AudioFormat)
+	binary.LittleEndian.PutUint16(header[22:24], format.Channels)
+	binary.LittleEndian.PutUint32(header[24:28], format.SampleRate)
+	binary.LittleEndian.PutUint32(header[28:32], uint32(format.ByteRate()))
+	binary.LittleEndian.PutUint16(header[32:34], uint16(format.BlockAlign()))
+	binary.LittleEndian.PutUint16(header[34:36], format.BitsPerSample)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(len(pcm)))
+
+	if _, err := w.Write(header); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	if _, err := w.Write(pcm); err != nil {
+		return fmt.Errorf("write data: %w", err)
+	}
+	return nil
+}
+
+// WriteSizes fills in the sizes of a canonical header written by Write
+// for dataSize bytes of sample data, for files written before their
+// length was known
+func WriteSizes(w io.WriterAt, dataSize int64) error {
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(HeaderSize-8+dataSize))
+	if _, err := w.WriteAt(size[:], 4); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	binary.LittleEndian.PutUint32(size[:], uint32(dataSize))
+	if _, err := w.WriteAt(size[:], HeaderSize-4); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+	return nil
+}
+
+// Merge concatenates the audio of several WAV files into one valid file.
+// All inputs must share the same format, otherwise ErrFormatMismatch is
+// returned.
+func Merge(files ...[]byte) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("wav: nothing to merge")
+	}
+
+	var (
+		format Format
+		pcm    []byte
+	)
+	for i, data := range files {
+		file, err := Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("file %d: %w", i, err)
+		}
+		if i == 0 {
+			format = file.Format
+		} else if file.Format != format {
+			return nil, fmt.Errorf("%w: file %d is %s, expected %s", ErrFormatMismatch, i, file.Format, format)
+		}
+		pcm = append(pcm, file.Data...)
+	}
+
+	return Encode(format, pcm)
+}
@@ -0,0 +1,222 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	format := PCM16(24000, 1)
+	pcm := []byte{1, 0, 2, 0, 3, 0, 4, 0}
+
+	data, err := Encode(format, pcm)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(data) != HeaderSize+len(pcm) {
+		t.Fatalf("encoded length %d, want %d", len(data), HeaderSize+len(pcm))
+	}
+	if got := binary.LittleEndian.Uint32(data[4:8]); got != uint32(len(data)-8) {
+		t.Errorf("RIFF size %d, want %d", got, len(data)-8)
+	}
+
+	file, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if file.Format != format {
+		t.Errorf("format %s, want %s", file.Format, format)
+	}
+	if !bytes.Equal(file.Data, pcm) {
+		t.Errorf("data %v, want %v", file.Data, pcm)
+	}
+	if file.Frames() != 4 {
+		t.Errorf("frames %d, want 4", file.Frames())
+	}
+}
+
+func TestEncode_RejectsPartialFrame(t *testing.T) {
+	if _, err := Encode(PCM16(16000, 2), []byte{1, 2, 3}); err == nil {
+		t.Error("expected error for data that is not a whole number of frames")
+	}
+	if _, err := Encode(Format{AudioFormat: 2, Channels: 1, SampleRate: 8000, BitsPerSample: 16}, nil); err == nil {
+		t.Error("expected error for unsupported audio format")
+	}
+}
+
+// buildWAV writes a file with extra chunks around fmt and data
+func buildWAV(format Format, pcm []byte, dataSize uint32) []byte {
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+
+	// 奇数长度的 LIST chunk，后面带填充字节
+	body.WriteString("LIST")
+	binary.Write(&body, binary.LittleEndian, uint32(3))
+	body.Write([]byte{'a', 'b', 'c', 0})
+
+	body.WriteString("fmt ")
+	binary.Write(&body, binary.LittleEndian, uint32(18))
+	binary.Write(&body, binary.LittleEndian, format.AudioFormat)
+	binary.Write(&body, binary.LittleEndian, format.Channels)
+	binary.Write(&body, binary.LittleEndian, format.SampleRate)
+	binary.Write(&body, binary.LittleEndian, uint32(format.ByteRate()))
+	binary.Write(&body, binary.LittleEndian, uint16(format.BlockAlign()))
+	binary.Write(&body, binary.LittleEndian, format.BitsPerSample)
+	binary.Write(&body, binary.LittleEndian, uint16(0))
+
+	body.WriteString("data")
+	binary.Write(&body, binary.LittleEndian, dataSize)
+	body.Write(pcm)
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func TestDecode_SkipsUnknownChunks(t *testing.T) {
+	format := PCM16(24000, 1)
+	pcm := []byte{9, 0, 8, 0}
+
+	file, err := Decode(buildWAV(format, pcm, uint32(len(pcm))))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if file.Format != format || !bytes.Equal(file.Data, pcm) {
+		t.Errorf("got %s %v, want %s %v", file.Format, file.Data, format, pcm)
+	}
+}
+
+func TestDecode_StreamingSize(t *testing.T) {
+	// 流式编码器不知道长度时写 0xFFFFFFFF，最后半帧要丢掉
+	pcm := []byte{1, 0, 2, 0, 3}
+	file, err := Decode(buildWAV(PCM16(24000, 1), pcm, 0xFFFFFFFF))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !bytes.Equal(file.Data, pcm[:4]) {
+		t.Errorf("data %v, want %v", file.Data, pcm[:4])
+	}
+}
+
+func TestDecode_EmptyDataBeforeChunk(t *testing.T) {
+	// 空的 data chunk 后面跟着 LIST chunk，LIST 不能当作采样
+	data := buildWAV(PCM16(24000, 1), nil, 0)
+	data = append(data, "LIST"...)
+	data = binary.LittleEndian.AppendUint32(data, 4)
+	data = append(data, "INFO"...)
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(data)-8))
+
+	file, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(file.Data) != 0 {
+		t.Errorf("data %v, want none", file.Data)
+	}
+
+	// 没有后续 chunk 时，大小为 0 仍表示数据一直到文件末尾
+	pcm := []byte{1, 0, 2, 0}
+	file, err = Decode(buildWAV(PCM16(24000, 1), pcm, 0))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !bytes.Equal(file.Data, pcm) {
+		t.Errorf("data %v, want %v", file.Data, pcm)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	valid, _ := Encode(PCM16(8000, 1), []byte{0, 0, 0, 0})
+
+	tests := map[string][]byte{
+		"empty":       nil,
+		"not riff":    append([]byte("RIFX"), valid[4:]...),
+		"truncated":   valid[:HeaderSize-2],
+		"short data":  buildWAV(PCM16(8000, 1), []byte{0, 0}, 100),
+		"no fmt":      append([]byte("RIFF\x0c\x00\x00\x00WAVEdata"), 0, 0, 0, 0),
+		"unsupported": buildWAV(Format{AudioFormat: 0x55, Channels: 1, SampleRate: 8000, BitsPerSample: 16}, nil, 0),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(data); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	format := PCM16(24000, 1)
+	a, _ := Encode(format, []byte{1, 0, 2, 0})
+	b, _ := Encode(format, []byte{3, 0})
+	c := buildWAV(format, []byte{4, 0, 5, 0}, 4)
+
+	merged, err := Merge(a, b, c)
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	if n := bytes.Count(merged, []byte("RIFF")); n != 1 {
+		t.Errorf("merged file has %d RIFF headers, want 1", n)
+	}
+
+	file, err := Decode(merged)
+	if err != nil {
+		t.Fatalf("Decode merged failed: %v", err)
+	}
+	want := []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0}
+	if !bytes.Equal(file.Data, want) {
+		t.Errorf("merged data %v, want %v", file.Data, want)
+	}
+	if got := binary.LittleEndian.Uint32(merged[40:44]); got != uint32(len(want)) {
+		t.Errorf("data size %d, want %d", got, len(want))
+	}
+}
+
+func TestMerge_FormatMismatch(t *testing.T) {
+	a, _ := Encode(PCM16(24000, 1), []byte{1, 0})
+	b, _ := Encode(PCM16(16000, 1), []byte{1, 0})
+
+	if _, err := Merge(a, b); !errors.Is(err, ErrFormatMismatch) {
+		t.Errorf("expected ErrFormatMismatch, got %v", err)
+	}
+	if _, err := Merge(a, []byte("garbage")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+}
+
+// writerAt 是内存中的 io.WriterAt
+type writerAt []byte
+
+func (w writerAt) WriteAt(p []byte, off int64) (int, error) {
+	return copy(w[off:], p), nil
+}
+
+func TestWriteSizes(t *testing.T) {
+	// 先写空头，再追加数据并补上长度
+	var buf bytes.Buffer
+	if err := Write(&buf, PCM16(16000, 1), nil); err != nil {
+		t.Fatal(err)
+	}
+	pcm := []byte{1, 0, 2, 0, 3, 0}
+	buf.Write(pcm)
+	data := writerAt(buf.Bytes())
+	if err := WriteSizes(data, int64(len(pcm))); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Decode(data)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !bytes.Equal(file.Data, pcm) {
+		t.Errorf("data %v, want %v", file.Data, pcm)
+	}
+	if got := binary.LittleEndian.Uint32(data[4:8]); got != uint32(len(data)-8) {
+		t.Errorf("RIFF size %d, want %d", got, len(data)-8)
+	}
+}